@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/agent"
+	"github.com/DoraZa/mini-agent/internal/audit"
+	"github.com/DoraZa/mini-agent/internal/config"
+)
+
+// runAuditCommand 实现 `mini-agent audit verify [file]` 子命令。
+func runAuditCommand(args []string) {
+	if len(args) == 0 || args[0] != "verify" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "Usage: mini-agent audit verify [file]")
+		os.Exit(2)
+	}
+
+	path := ""
+	if len(args) == 2 {
+		path = args[1]
+	} else {
+		cfg, err := config.LoadLocalConfig()
+		if err != nil {
+			log.Fatalf("Error loading config: %v", err)
+		}
+		path = cfg.AuditLogPath()
+	}
+	report, err := audit.Verify(path)
+	if err != nil {
+		fmt.Printf("❌ Audit log %s failed verification: %v\n", path, err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Audit log %s is intact: %d entries.\n", path, report.Count)
+	if report.LastHash != "" {
+		fmt.Printf("   Last hash: %s\n", report.LastHash)
+	}
+	for _, line := range report.Torn {
+		fmt.Printf("⚠️  Line %d is an incomplete entry left by an interrupted write.\n", line)
+	}
+}
+
+// auditObservations 包装 callbacks.OnObservation，把每一次工具调用（包括没有执行的调用）追加到审计日志中。
+// prompt 返回触发当前任务的用户输入。
+func auditObservations(callbacks *agent.Callbacks, logger *audit.Logger, sessionID string, prompt func() string) {
+	onObservation := callbacks.OnObservation
+	callbacks.OnObservation = func(execution agent.ToolExecution) {
+		if onObservation != nil {
+			onObservation(execution)
+		}
+		entry := audit.Entry{
+			Time:         time.Now(),
+			SessionID:    sessionID,
+			Prompt:       prompt(),
+			Tool:         execution.Call.Function.Name,
+			CallID:       execution.Call.ID,
+			Arguments:    execution.Call.Function.Arguments,
+			RepairedFrom: execution.RepairedFrom,
+			Command:      execution.Command,
+			Approved:     execution.Approved,
+			ApprovedBy:   execution.ApprovedBy,
+			ExitCode:     execution.ExitCode,
+			DurationMS:   execution.Duration.Milliseconds(),
+			OutputSHA256: audit.HashOutput(execution.Observation),
+		}
+		// ToolSet 没有实现 CommandExecutor 时命令行为空，只能按是否出错记录：成功为 0，失败为 -1
+		if !execution.Approved || (execution.Command == "" && execution.Err != nil) {
+			entry.ExitCode = -1
+		}
+		if execution.Err != nil {
+			entry.Error = execution.Err.Error()
+		}
+		if err := logger.Record(entry); err != nil {
+			log.Printf("Warning: failed to write audit log: %v", err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/usage"
+)
+
+// cliCommands 处理以 "/" 开头的本地命令。
+type cliCommands struct {
+	usage      *usage.Tracker
+	history    *history.HistoryManager
+	summarizer history.Summarizer
+	keepRecent int
+}
+
+// handle 执行一条本地命令。
+func (c *cliCommands) handle(ctx context.Context, input string) {
+	switch strings.Fields(input)[0] {
+	case "/usage":
+		fmt.Printf("📊 Last task: %s\n", c.usage.Task())
+		fmt.Printf("📊 Session:   %s\n", c.usage.Session())
+	case "/compact":
+		fmt.Println("🗜️  Compacting conversation history...")
+		compaction, err := c.history.Compact(ctx, c.summarizer, c.keepRecent)
+		if err != nil {
+			fmt.Printf("❌ Compaction failed: %v\n", err)
+			return
+		}
+		printCompaction(compaction)
+	default:
+		fmt.Printf("Unknown command: %s (available: /usage, /compact)\n", input)
+	}
+}
+
+// printCompaction 打印一次压缩的结果，方便用户核对摘要是否遗漏了关键信息。
+func printCompaction(compaction *history.Compaction) {
+	fmt.Printf("🗜️  Compacted %d messages (~%d -> ~%d tokens). Summary:\n%s\n",
+		len(compaction.Replaced), compaction.TokensBefore, compaction.TokensAfter, compaction.Summary)
+}
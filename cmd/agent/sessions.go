@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/DoraZa/mini-agent/internal/agent"
+	"github.com/DoraZa/mini-agent/internal/config"
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/session"
+)
+
+// runSessionsCommand 实现 `mini-agent sessions <subcommand>` 子命令。
+func runSessionsCommand(args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintln(os.Stderr, "Usage: mini-agent sessions list")
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadLocalConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	sessions, corrupt, err := session.NewStore(cfg.SessionDir()).List()
+	if err != nil {
+		log.Fatalf("Error listing sessions: %v", err)
+	}
+	for _, err := range corrupt {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipped unreadable session: %v\n", err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tTITLE")
+	for _, s := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.ID, s.UpdatedAt.Format("2006-01-02 15:04"), s.Messages, s.Title)
+	}
+	w.Flush()
+}
+
+// openSession 根据命令行参数新建或恢复一个会话，返回填充好的历史记录以及用于持久化的 Writer。
+// resumeID 不为空时恢复指定会话；continueLast 为 true 时恢复最近一次会话。
+func openSession(store *session.Store, model, resumeID string, continueLast bool) (*history.HistoryManager, *session.Writer, error) {
+	if continueLast {
+		latest, err := store.Latest()
+		if errors.Is(err, session.ErrNotFound) {
+			fmt.Println("No previous session found, starting a new one.")
+		} else if err != nil {
+			return nil, nil, err
+		} else {
+			resumeID = latest.ID
+		}
+	}
+
+	histManager := history.NewHistoryManager()
+	if resumeID != "" {
+		writer, err := store.Resume(resumeID, histManager)
+		if err != nil {
+			return nil, nil, err
+		}
+		histManager.SetRecorder(writer)
+		info := writer.Info()
+		fmt.Printf("📂 Resumed session %s (%s), %d messages.\n", info.ID, info.Title, len(histManager.Messages))
+		return histManager, writer, nil
+	}
+
+	writer, err := store.Create(model)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 先设置 Recorder，再添加系统提示词，使会话文件包含完整的历史记录
+	histManager.SetRecorder(writer)
+	histManager.AddSystemMessage(agent.SystemPrompt)
+	fmt.Printf("📂 Session %s (resume later with --resume %s)\n", writer.Info().ID, writer.Info().ID)
+	return histManager, writer, nil
+}
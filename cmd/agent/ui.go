@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/DoraZa/mini-agent/internal/agent"
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/policy"
+)
+
+// llmErrorHint 针对无法通过重试恢复的 LLM 错误，给出下一步操作的提示。
+func llmErrorHint(err error) string {
+	switch llm.ClassifyError(err) {
+	case llm.ErrorKindAuth:
+		return "🔑 认证失败，请检查 AGENT_API_KEY 是否正确。"
+	case llm.ErrorKindQuota:
+		return "💳 账户额度已耗尽，请充值后重试。"
+	case llm.ErrorKindContextLength:
+		return "📏 对话已超出模型的上下文长度限制。"
+	case llm.ErrorKindRateLimit, llm.ErrorKindTransient:
+		return "⏳ 服务暂时不可用，多次重试后仍然失败，请稍后再试。你的问题已保留在对话历史中。"
+	default:
+		return ""
+	}
+}
+
+// cliDisplay 记录终端中流式输出的状态，以便在合适的位置换行。
+type cliDisplay struct {
+	streaming bool // 当前是否正处于一段流式输出的中间
+	streamed  bool // 最近一次 LLM 响应是否以流式方式打印过内容
+}
+
+// writeDelta 打印一段流式文本，首段前加上提示符。
+func (d *cliDisplay) writeDelta(delta string) {
+	if !d.streaming {
+		fmt.Print("📝 ")
+		d.streaming = true
+		d.streamed = true
+	}
+	fmt.Print(delta)
+}
+
+// endStream 结束当前的流式输出段落。
+func (d *cliDisplay) endStream() {
+	if d.streaming {
+		fmt.Println()
+		d.streaming = false
+	}
+}
+
+// newCLICallbacks 创建在终端中展示 ReAct 进度、并按批准策略确认工具调用的回调。
+func newCLICallbacks(scanner *bufio.Scanner, display *cliDisplay, approver *policy.Approver) agent.Callbacks {
+	return agent.Callbacks{
+		OnThinking: func() {
+			display.streamed = false
+			fmt.Println("🤔 Thinking...")
+		},
+		OnCompaction: func(compaction *history.Compaction, err error) {
+			if err != nil {
+				fmt.Printf("⚠️  Automatic compaction failed: %v\n", err)
+				return
+			}
+			printCompaction(compaction)
+		},
+		OnContentDelta: display.writeDelta,
+		OnThought: func(thought string) {
+			fmt.Printf("📝 Thought: %s\n", thought)
+		},
+		OnToolCall: func(toolCall llm.ToolCall) {
+			display.endStream()
+			fmt.Printf("🔧 Executing tool: %s(%s)\n", toolCall.Function.Name, toolCall.Function.Arguments)
+		},
+		ApproveBatch: func(toolCalls []llm.ToolCall) []agent.Approval {
+			return approveToolCalls(scanner, approver, toolCalls)
+		},
+		OnObservation: func(execution agent.ToolExecution) {
+			if execution.RepairedFrom != "" {
+				fmt.Printf("🩹 Repaired malformed arguments from the model: %s\n", execution.RepairedFrom)
+			}
+			if execution.Err != nil {
+				fmt.Printf("❌ Error executing tool '%s': %v\n", execution.Call.Function.Name, execution.Err)
+			}
+			if execution.Redactions.Total() > 0 {
+				fmt.Printf("🔒 Redacted secrets from the output before sending it to the model: %s\n", execution.Redactions)
+			}
+			// 打印观察结果，让用户了解发生了什么
+			fmt.Printf("🔭 Observation: %s\n", execution.Observation)
+		},
+	}
+}
+
+// approveToolCalls 按批准策略处理同一步中的所有工具调用：策略为 auto 的直接执行，为 deny 的直接拒绝，
+// 其余的调用一起询问用户。
+func approveToolCalls(scanner *bufio.Scanner, approver *policy.Approver, toolCalls []llm.ToolCall) []agent.Approval {
+	approvals := make([]agent.Approval, len(toolCalls))
+	var ask []int
+	for i, toolCall := range toolCalls {
+		decision := approver.Decide(toolCall)
+		switch decision.Mode {
+		case policy.ModeAuto:
+			fmt.Printf("✅ %s approved automatically (%s)\n", toolCall.Function.Name, decision.Source)
+			approvals[i] = agent.Approval{Approved: true, By: decision.Source}
+		case policy.ModeDeny:
+			fmt.Printf("🚫 %s denied (%s)\n", toolCall.Function.Name, decision.Source)
+			approvals[i] = agent.Approval{
+				By: decision.Source,
+				Reason: fmt.Sprintf("Denied by the approval policy (%s); the tool was not run. "+
+					"Do not retry the same call: try a different approach or ask the user.", decision.Source),
+			}
+		default:
+			ask = append(ask, i)
+		}
+	}
+	if len(ask) == 0 {
+		return approvals
+	}
+
+	selected, always := confirmToolCalls(scanner, toolCalls, ask)
+	for n, i := range ask {
+		approvals[i] = agent.Approval{Approved: selected[n], By: "user"}
+		if selected[n] && always {
+			approver.AllowForSession(toolCalls[i])
+		}
+	}
+	return approvals
+}
+
+// confirmToolCalls 交互式确认 ask 中的工具调用：输入 "y" 全部执行，输入 "a" 全部执行并在本次会话中
+// 不再询问完全相同的调用，输入编号（例如 "1,3"）只执行其中的一部分。直接按 Enter 或输入 "n" 全部取消。
+func confirmToolCalls(scanner *bufio.Scanner, toolCalls []llm.ToolCall, ask []int) (selected []bool, always bool) {
+	selected = make([]bool, len(ask))
+	if len(ask) == 1 {
+		fmt.Print("Do you want to execute this command? [y/N/a=always allow this exact call]: ")
+	} else {
+		names := make([]string, len(ask))
+		for n, i := range ask {
+			names[n] = fmt.Sprintf("%d) %s", n+1, toolCalls[i].Function.Name)
+		}
+		fmt.Printf("Execute these %d tool calls (%s)? [y/N/a=always allow these exact calls/numbers, e.g. 1,3]: ", len(ask), strings.Join(names, ", "))
+	}
+	if !scanner.Scan() {
+		log.Println("Scanner failed, cancelling execution.")
+		return selected, false
+	}
+
+	confirmation := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	switch confirmation {
+	case "y", "yes", "a", "always":
+		for n := range selected {
+			selected[n] = true
+		}
+		return selected, confirmation == "a" || confirmation == "always"
+	case "", "n", "no":
+		// 直接按 Enter 默认取消，避免误执行会修改系统的操作
+		fmt.Println("❌ Execution cancelled by user.")
+		return selected, false
+	}
+
+	for _, field := range strings.FieldsFunc(confirmation, func(r rune) bool { return r == ',' || r == ' ' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(ask) {
+			fmt.Printf("❌ Invalid selection %q, execution cancelled.\n", field)
+			return make([]bool, len(ask)), false
+		}
+		selected[n-1] = true
+	}
+	return selected, false
+}
@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/redact"
+	"github.com/DoraZa/mini-agent/internal/usage"
+)
+
+// ErrNoChoices 表示 LLM 的响应中没有任何可用的选项。
+var ErrNoChoices = errors.New("received no choices from LLM")
+
+// ErrMaxSteps 表示模型在 Runner.MaxSteps 步之内没有给出最终答案。
+var ErrMaxSteps = errors.New("reached the maximum number of steps without a final answer")
+
+// ToolSet 描述了 Runner 所依赖的工具集合：提供工具定义，并负责执行具体的工具调用。
+// 这样 Runner 就不需要关心工具是如何实现、如何做安全检查的。
+type ToolSet interface {
+	// Definitions 返回发送给 LLM 的工具定义。
+	Definitions() []llm.Tool
+	// Execute 执行一个工具调用并返回其输出。
+	Execute(ctx context.Context, toolCall llm.ToolCall) (string, error)
+}
+
+// Callbacks 是 Runner 在 ReAct 循环各个阶段触发的钩子。
+// 所有字段都是可选的；前端（例如 CLI）通过它们展示进度或与用户交互。
+type Callbacks struct {
+	// OnThinking 在每次请求 LLM 之前调用。
+	OnThinking func()
+	// OnCompaction 在自动压缩历史记录之后调用；压缩失败时 compaction 为 nil，err 为失败原因。
+	// 压缩失败不会中断任务，超出预算的历史仍会在发送前被裁剪。
+	OnCompaction func(compaction *history.Compaction, err error)
+	// OnContentDelta 在流式模式下每收到一段助手文本时调用。
+	OnContentDelta func(delta string)
+	// OnThought 在助手返回思考内容并附带工具调用时调用。
+	// 流式模式下思考内容已通过 OnContentDelta 实时下发，因此不会再调用它。
+	OnThought func(thought string)
+	// OnToolCall 在执行（或请求批准）某个工具调用之前调用。
+	OnToolCall func(toolCall llm.ToolCall)
+	// Approve 决定是否执行某个工具调用。为 nil 时默认全部批准。
+	Approve func(toolCall llm.ToolCall) bool
+	// ApproveBatch 一次性决定是否执行同一步中的多个工具调用，返回与 toolCalls 一一对应的结果。
+	// 设置后优先于 Approve，便于前端只展示一次确认提示，或者按策略自动批准、拒绝部分调用。
+	ApproveBatch func(toolCalls []llm.ToolCall) []Approval
+	// OnObservation 在得到工具调用的观察结果后调用。
+	OnObservation func(execution ToolExecution)
+}
+
+// Approval 是对一个工具调用的批准结果。
+type Approval struct {
+	Approved bool
+	// By 说明由谁做出了决定，例如 "user" 或某条批准策略，可以为空
+	By string
+	// Reason 是未获批准时反馈给模型的观察结果，为空时使用默认的说明
+	Reason string
+}
+
+// ToolExecution 记录了一次工具调用及其结果。
+type ToolExecution struct {
+	Call        llm.ToolCall // 模型请求的工具调用（参数被修复时为修复后的参数）
+	Approved    bool         // 是否获得了执行批准
+	ApprovedBy  string       // 由谁批准或拒绝了执行，见 Approval.By
+	Observation string       // 反馈给模型的观察结果
+	Err         error        // 工具执行出错时的错误
+
+	// RepairedFrom 在模型生成的参数不是合法 JSON、被修复后才执行时，是修复前的原始参数
+	RepairedFrom string
+
+	// 以下字段只在工具被执行时填写。Command 和 ExitCode 需要 ToolSet 实现 CommandExecutor
+	Command  string        // 实际执行的命令行
+	ExitCode int           // 退出码，0 表示成功
+	Duration time.Duration // 执行耗时
+
+	// Redactions 是观察结果在写入历史记录之前被替换的敏感信息的数量，见 Runner.Redactor
+	Redactions redact.Report
+}
+
+// Step 代表 ReAct 循环中的一步：一次 LLM 响应及其触发的工具调用。
+type Step struct {
+	Thought    string          // 助手在本步给出的思考内容
+	Executions []ToolExecution // 本步执行的工具调用
+	Usage      llm.Usage       // 本步 LLM 请求消耗的 token
+}
+
+// Result 是一次 Run 的结构化结果。
+type Result struct {
+	FinalAnswer string    // 模型给出的最终答案（已清理前缀）
+	Steps       []Step    // 得到最终答案前经历的所有步骤
+	Usage       llm.Usage // 本次任务中所有 LLM 请求消耗的 token（包括给出最终答案的请求）
+}
+
+// ToolCalls 返回本次运行中模型请求的全部工具调用。
+func (r *Result) ToolCalls() []llm.ToolCall {
+	var calls []llm.ToolCall
+	for _, step := range r.Steps {
+		for _, execution := range step.Executions {
+			calls = append(calls, execution.Call)
+		}
+	}
+	return calls
+}
+
+// Observations 返回本次运行中所有工具调用的观察结果，顺序与 ToolCalls 一致。
+func (r *Result) Observations() []string {
+	var observations []string
+	for _, step := range r.Steps {
+		for _, execution := range step.Executions {
+			observations = append(observations, execution.Observation)
+		}
+	}
+	return observations
+}
+
+// Runner 实现了 Thought -> Action -> Observation 的 ReAct 循环。
+// 它与具体的输入输出方式解耦，CLI 只是它的一个前端。
+type Runner struct {
+	llm     llm.LLM
+	model   string
+	tools   ToolSet
+	history *history.HistoryManager
+
+	// Callbacks 允许前端观察和干预循环的执行。
+	Callbacks Callbacks
+	// Stream 为 true 时使用流式接口请求 LLM，助手的文本会通过 OnContentDelta 实时下发。
+	Stream bool
+	// Usage 不为 nil 时，每次 LLM 请求的用量都会被记录到其中，用于按任务和会话统计费用。
+	Usage *usage.Tracker
+	// MaxParallelTools 是同时执行的只读工具调用数，0 表示使用 DefaultMaxParallelTools。
+	MaxParallelTools int
+	// MaxSteps 是一次 Run 中最多执行的工具调用步数，达到后返回 ErrMaxSteps，0 表示不限制。
+	MaxSteps int
+	// Redactor 不为 nil 时，观察结果中的密钥、密码等敏感信息会在写入历史记录（并发送给 LLM）之前被替换。
+	// 输出会被截断的 ToolSet 应当在截断之前使用同一个 Redactor 替换，见 CommandExecutor。
+	Redactor *redact.Redactor
+}
+
+// NewRunner 创建一个新的 Runner。
+// 历史记录管理器在多次 Run 之间共享，以实现多轮对话记忆；系统提示词由调用方自行添加。
+func NewRunner(client llm.LLM, model string, toolSet ToolSet, hist *history.HistoryManager) *Runner {
+	return &Runner{
+		llm:     client,
+		model:   model,
+		tools:   toolSet,
+		history: hist,
+	}
+}
+
+// History 返回 Runner 使用的历史记录管理器。
+func (r *Runner) History() *history.HistoryManager {
+	return r.history
+}
+
+// Run 将用户输入加入历史记录，并持续执行 ReAct 循环，
+// 直到 LLM 给出最终答案（不返回工具调用）或发生错误。
+// 出错时返回已完成的部分结果以及错误。
+func (r *Runner) Run(ctx context.Context, userInput string) (*Result, error) {
+	r.history.AddUserMessage(userInput)
+	if r.Usage != nil {
+		r.Usage.StartTask()
+	}
+
+	result := &Result{}
+	toolDefs := r.tools.Definitions()
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		// 历史记录超过阈值时，先将较早的对话压缩为摘要
+		compaction, err := r.history.CompactIfNeeded(ctx)
+		if (compaction != nil || err != nil) && r.Callbacks.OnCompaction != nil {
+			r.Callbacks.OnCompaction(compaction, err)
+		}
+
+		// 准备发送给 LLM 的请求
+		request := llm.ChatRequest{
+			Model:    r.model,
+			Messages: r.history.GetHistory(),
+			Tools:    toolDefs,
+		}
+
+		if r.Callbacks.OnThinking != nil {
+			r.Callbacks.OnThinking()
+		}
+		response, err := r.complete(ctx, request)
+		if err != nil {
+			return result, fmt.Errorf("error from LLM: %w", err)
+		}
+		result.Usage = result.Usage.Add(response.Usage)
+		if r.Usage != nil {
+			r.Usage.Record(r.model, response.Usage)
+		}
+		if len(response.Choices) == 0 {
+			return result, ErrNoChoices
+		}
+
+		// 提取助手的消息并将其添加到历史记录中。
+		// 先修复格式错误的工具调用参数，使历史记录中保存的是实际执行的参数
+		assistantMessage := response.Choices[0].Message
+		repairs := repairToolCalls(assistantMessage.ToolCalls)
+		r.history.AddAssistantMessage(assistantMessage)
+
+		var content string
+		if assistantMessage.Content != nil {
+			content = *assistantMessage.Content
+		}
+
+		// 没有工具调用意味着 ReAct 循环结束：这是最终答案
+		if len(assistantMessage.ToolCalls) == 0 {
+			result.FinalAnswer = cleanFinalAnswer(content)
+			return result, nil
+		}
+
+		// ReAct 循环中的一步：思考 -> 行动 -> 观察
+		step := Step{Thought: content, Usage: response.Usage}
+		if content != "" && !r.Stream && r.Callbacks.OnThought != nil {
+			r.Callbacks.OnThought(content)
+		}
+		step.Executions = r.executeToolCalls(ctx, assistantMessage.ToolCalls, repairs)
+		// 将工具执行的观察结果按调用顺序添加到历史记录中
+		for _, execution := range step.Executions {
+			r.history.AddToolObservation(execution.Call.ID, execution.Observation)
+		}
+		result.Steps = append(result.Steps, step)
+		if r.MaxSteps > 0 && len(result.Steps) >= r.MaxSteps {
+			return result, ErrMaxSteps
+		}
+	}
+}
+
+// complete 根据 Stream 设置，以阻塞或流式的方式请求 LLM，并返回完整的响应。
+func (r *Runner) complete(ctx context.Context, request llm.ChatRequest) (*llm.ChatResponse, error) {
+	if !r.Stream {
+		return r.llm.ChatCompletion(ctx, request)
+	}
+
+	stream, err := r.llm.ChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return llm.CollectStream(stream, func(delta llm.StreamDelta) {
+		if delta.Content != "" && r.Callbacks.OnContentDelta != nil {
+			r.Callbacks.OnContentDelta(delta.Content)
+		}
+	})
+}
+
+// cleanFinalAnswer 清理模型可能返回的不必要的前缀。
+// 使用文本协议的模型会在 "Final Answer:" 之前写出思考过程，此时只保留其后的内容。
+func cleanFinalAnswer(content string) string {
+	if _, answer, ok := strings.Cut(content, "Final Answer:"); ok {
+		content = answer
+	}
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "Thought:")
+	content = strings.TrimPrefix(content, "Final Answer:")
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,235 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// scriptedLLM 依次返回预先准备好的响应，并记录收到的请求。
+type scriptedLLM struct {
+	mu        sync.Mutex
+	responses []*llm.ChatResponse
+	requests  []llm.ChatRequest
+}
+
+func (s *scriptedLLM) ChatCompletion(ctx context.Context, request llm.ChatRequest) (*llm.ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+	if len(s.responses) == 0 {
+		return nil, errors.New("no more scripted responses")
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	return response, nil
+}
+
+func (s *scriptedLLM) ChatCompletionStream(ctx context.Context, request llm.ChatRequest) (llm.ChatStream, error) {
+	return nil, errors.New("streaming is not scripted")
+}
+
+// toolCall 创建一个工具调用。
+func toolCall(id, name, arguments string) llm.ToolCall {
+	return llm.ToolCall{ID: id, Type: "function", Function: llm.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+// callResponse 创建一个请求调用工具的响应。
+func callResponse(thought string, usage llm.Usage, calls ...llm.ToolCall) *llm.ChatResponse {
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: &thought, ToolCalls: calls}}},
+		Usage:   usage,
+	}
+}
+
+// answerResponse 创建一个给出最终答案的响应。
+func answerResponse(answer string, usage llm.Usage) *llm.ChatResponse {
+	return &llm.ChatResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: &answer}}},
+		Usage:   usage,
+	}
+}
+
+// fakeTools 是测试用的 ToolSet：execute 为 nil 时返回 "output of <工具名>"，
+// readOnly 中的工具被视为只读。它记录每次执行的调用 ID。
+type fakeTools struct {
+	execute  func(ctx context.Context, toolCall llm.ToolCall) (string, error)
+	readOnly map[string]bool
+
+	mu       sync.Mutex
+	executed []string
+}
+
+func (f *fakeTools) Definitions() []llm.Tool {
+	return []llm.Tool{{Type: "function", Function: llm.Function{Name: "ps"}}}
+}
+
+func (f *fakeTools) Execute(ctx context.Context, toolCall llm.ToolCall) (string, error) {
+	f.mu.Lock()
+	f.executed = append(f.executed, toolCall.ID)
+	f.mu.Unlock()
+	if f.execute != nil {
+		return f.execute(ctx, toolCall)
+	}
+	return "output of " + toolCall.Function.Name, nil
+}
+
+func (f *fakeTools) ReadOnly(toolCall llm.ToolCall) bool {
+	return f.readOnly[toolCall.Function.Name]
+}
+
+// toolMessages 返回历史记录中的工具观察结果，格式为 "<调用 ID>: <内容>"。
+func toolMessages(h *history.HistoryManager) []string {
+	var messages []string
+	for _, m := range h.Messages {
+		if m.Role == "tool" {
+			messages = append(messages, fmt.Sprintf("%s: %s", m.ToolCallID, *m.Content))
+		}
+	}
+	return messages
+}
+
+func TestRunFinalAnswer(t *testing.T) {
+	client := &scriptedLLM{responses: []*llm.ChatResponse{
+		callResponse("check the processes", llm.Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}, toolCall("call_1", "ps", `{"name":"nginx"}`)),
+		answerResponse("Final Answer: nginx is running", llm.Usage{PromptTokens: 150, CompletionTokens: 5, TotalTokens: 155}),
+	}}
+	tools := &fakeTools{}
+	runner := NewRunner(client, "test-model", tools, history.NewHistoryManager())
+
+	result, err := runner.Run(context.Background(), "is nginx running?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FinalAnswer != "nginx is running" {
+		t.Errorf("FinalAnswer = %q, want %q", result.FinalAnswer, "nginx is running")
+	}
+	if len(result.Steps) != 1 || result.Steps[0].Thought != "check the processes" {
+		t.Fatalf("Steps = %+v, want one step with the thought", result.Steps)
+	}
+	if calls := result.ToolCalls(); len(calls) != 1 || calls[0].ID != "call_1" {
+		t.Errorf("ToolCalls() = %+v, want call_1", calls)
+	}
+	if observations := result.Observations(); !slices.Equal(observations, []string{"output of ps"}) {
+		t.Errorf("Observations() = %q", observations)
+	}
+	if want := (llm.Usage{PromptTokens: 250, CompletionTokens: 15, TotalTokens: 265}); result.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", result.Usage, want)
+	}
+	// 第二次请求中必须带有工具的观察结果
+	if len(client.requests) != 2 {
+		t.Fatalf("LLM was called %d times, want 2", len(client.requests))
+	}
+	second := client.requests[1].Messages
+	if last := second[len(second)-1]; last.Role != "tool" || last.ToolCallID != "call_1" || *last.Content != "output of ps" {
+		t.Errorf("last message of the second request = %+v, want the observation of call_1", last)
+	}
+	if client.requests[0].Model != "test-model" || len(client.requests[0].Tools) != 1 {
+		t.Errorf("first request = %+v, want the model and the tool definitions", client.requests[0])
+	}
+}
+
+func TestRunMaxSteps(t *testing.T) {
+	var responses []*llm.ChatResponse
+	for i := 1; i <= 5; i++ {
+		responses = append(responses, callResponse("", llm.Usage{}, toolCall(fmt.Sprintf("call_%d", i), "ps", `{}`)))
+	}
+	client := &scriptedLLM{responses: responses}
+	runner := NewRunner(client, "test-model", &fakeTools{}, history.NewHistoryManager())
+	runner.MaxSteps = 2
+
+	result, err := runner.Run(context.Background(), "loop forever")
+	if !errors.Is(err, ErrMaxSteps) {
+		t.Fatalf("Run() error = %v, want ErrMaxSteps", err)
+	}
+	if len(result.Steps) != 2 || len(client.requests) != 2 {
+		t.Errorf("got %d steps and %d LLM requests, want 2 of each", len(result.Steps), len(client.requests))
+	}
+	// 每个已执行的调用都有观察结果，历史记录可以继续使用
+	if got := toolMessages(runner.History()); !slices.Equal(got, []string{"call_1: output of ps", "call_2: output of ps"}) {
+		t.Errorf("tool messages = %q", got)
+	}
+}
+
+func TestRunToolErrorBecomesObservation(t *testing.T) {
+	client := &scriptedLLM{responses: []*llm.ChatResponse{
+		callResponse("", llm.Usage{}, toolCall("call_1", "lsof", `{"port":22}`)),
+		answerResponse("cannot inspect port 22", llm.Usage{}),
+	}}
+	tools := &fakeTools{execute: func(ctx context.Context, toolCall llm.ToolCall) (string, error) {
+		return "", errors.New("permission denied")
+	}}
+	runner := NewRunner(client, "test-model", tools, history.NewHistoryManager())
+
+	result, err := runner.Run(context.Background(), "who uses port 22?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	execution := result.Steps[0].Executions[0]
+	if execution.Err == nil || execution.Observation != "Error: permission denied" || !execution.Approved {
+		t.Errorf("execution = %+v, want the error as the observation", execution)
+	}
+	if got := toolMessages(runner.History()); !slices.Equal(got, []string{"call_1: Error: permission denied"}) {
+		t.Errorf("tool messages = %q", got)
+	}
+	if result.FinalAnswer != "cannot inspect port 22" {
+		t.Errorf("FinalAnswer = %q", result.FinalAnswer)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	t.Run("before the first request", func(t *testing.T) {
+		client := &scriptedLLM{responses: []*llm.ChatResponse{answerResponse("done", llm.Usage{})}}
+		runner := NewRunner(client, "test-model", &fakeTools{}, history.NewHistoryManager())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := runner.Run(ctx, "hi"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+		if len(client.requests) != 0 {
+			t.Errorf("LLM was called %d times after cancellation", len(client.requests))
+		}
+	})
+
+	t.Run("while running tools", func(t *testing.T) {
+		client := &scriptedLLM{responses: []*llm.ChatResponse{
+			callResponse("", llm.Usage{}, toolCall("call_1", "wget", `{}`), toolCall("call_2", "wget", `{}`)),
+			answerResponse("done", llm.Usage{}),
+		}}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		tools := &fakeTools{execute: func(ctx context.Context, toolCall llm.ToolCall) (string, error) {
+			cancel() // 用户在第一个调用执行时按下了 Ctrl-C
+			return "", ctx.Err()
+		}}
+		runner := NewRunner(client, "test-model", tools, history.NewHistoryManager())
+
+		result, err := runner.Run(ctx, "download two files")
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Run() error = %v, want context.Canceled", err)
+		}
+		if !slices.Equal(tools.executed, []string{"call_1"}) {
+			t.Errorf("executed = %v, want only call_1", tools.executed)
+		}
+		if len(client.requests) != 1 {
+			t.Errorf("LLM was called %d times, want 1", len(client.requests))
+		}
+		// 被跳过的调用也有观察结果，历史记录保持完整
+		want := []string{"call_1: Error: context canceled", "call_2: Execution skipped: the task was cancelled by the user."}
+		if got := toolMessages(runner.History()); !slices.Equal(got, want) {
+			t.Errorf("tool messages = %q, want %q", got, want)
+		}
+		if len(result.Steps) != 1 {
+			t.Errorf("Steps = %d, want the partial step", len(result.Steps))
+		}
+	})
+}
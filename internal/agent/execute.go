@@ -0,0 +1,240 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/redact"
+)
+
+// DefaultMaxParallelTools 是未设置 Runner.MaxParallelTools 时同时执行的只读工具调用数。
+const DefaultMaxParallelTools = 4
+
+// ReadOnlyChecker 可以由 ToolSet 额外实现，用于声明哪些工具调用不会修改系统状态。
+// 同一步中相邻的只读调用会并发执行；未实现该接口时所有调用都按顺序执行。
+type ReadOnlyChecker interface {
+	ReadOnly(toolCall llm.ToolCall) bool
+}
+
+// PolicyChecker 可以由 ToolSet 额外实现，用于在请求批准之前检查工具调用是否被安全策略允许、参数是否合法。
+// 被拒绝的调用不会再询问用户。
+type PolicyChecker interface {
+	Check(toolCall llm.ToolCall) error
+}
+
+// CommandExecutor 可以由 ToolSet 额外实现。它与 Execute 相同，但同时报告工具实际执行的命令行和退出码，
+// 它们会被记录在 ToolExecution 中，例如用于审计。
+// 输出在 ToolSet 中被截断之前已经替换过敏感信息时，redactions 是替换的数量，会计入 ToolExecution.Redactions。
+type CommandExecutor interface {
+	ExecuteCommand(ctx context.Context, toolCall llm.ToolCall) (output, command string, exitCode int, redactions redact.Report, err error)
+}
+
+// argumentRepair 记录对一个工具调用参数的修复尝试。
+type argumentRepair struct {
+	original string // 修复前的参数，未修复时为空
+	err      error  // 参数无法修复时的原因
+}
+
+// repairToolCalls 就地修复不是合法 JSON 的工具调用参数，返回与 toolCalls 一一对应的修复记录。
+func repairToolCalls(toolCalls []llm.ToolCall) []argumentRepair {
+	repairs := make([]argumentRepair, len(toolCalls))
+	for i := range toolCalls {
+		arguments := toolCalls[i].Function.Arguments
+		fixed, repaired, err := llm.RepairArguments(arguments)
+		switch {
+		case err != nil:
+			repairs[i].err = err
+		case repaired:
+			repairs[i].original = arguments
+			toolCalls[i].Function.Arguments = fixed
+		}
+	}
+	return repairs
+}
+
+// executeToolCalls 执行一步中的所有工具调用，返回与 toolCalls 顺序一致的执行结果。
+// 所有调用先一次性请求批准；获得批准的调用中，相邻的只读调用并发执行，
+// 其他调用按顺序执行并作为分界，保证它们与前后调用之间的先后顺序不变。
+func (r *Runner) executeToolCalls(ctx context.Context, toolCalls []llm.ToolCall, repairs []argumentRepair) []ToolExecution {
+	executions := make([]ToolExecution, len(toolCalls))
+	var pending []int // 等待批准的调用
+	for i, toolCall := range toolCalls {
+		if r.Callbacks.OnToolCall != nil {
+			r.Callbacks.OnToolCall(toolCall)
+		}
+		executions[i] = ToolExecution{Call: toolCall, RepairedFrom: repairs[i].original}
+		policyErr := r.checkPolicy(toolCall)
+		switch {
+		case repairs[i].err != nil:
+			// 参数无法安全地修复：不执行工具，请模型重新发送这次调用
+			executions[i].Err = fmt.Errorf("malformed arguments: %w", repairs[i].err)
+			executions[i].Observation = fmt.Sprintf("Error: the arguments of this tool call are not valid JSON and could not be repaired (%v). "+
+				"The tool was not run. Please send the call again with the arguments as a single, complete JSON object.", repairs[i].err)
+		case policyErr != nil:
+			// 被安全策略拒绝：不执行工具，也不再询问用户
+			executions[i].ApprovedBy = "security policy"
+			executions[i].Err = policyErr
+			executions[i].Observation = fmt.Sprintf("Error: %v", policyErr)
+		case ctx.Err() != nil:
+			// 任务已被取消：不再请求批准，但仍要为该调用补上观察结果，保持历史记录完整
+			executions[i].Err = ctx.Err()
+			executions[i].Observation = "Execution skipped: the task was cancelled by the user."
+		default:
+			pending = append(pending, i)
+		}
+	}
+
+	approvals := r.approve(toolCalls, pending)
+	var approved, skipped []int
+	for n, i := range pending {
+		executions[i].ApprovedBy = approvals[n].By
+		switch {
+		case approvals[n].Approved:
+			executions[i].Approved = true
+			approved = append(approved, i)
+		case approvals[n].Reason != "":
+			executions[i].Observation = approvals[n].Reason
+		default:
+			executions[i].Observation = "User cancelled the execution of this tool."
+		}
+	}
+	// 没有执行的调用（参数无法修复、被策略拒绝、任务被取消或被用户拒绝）先反馈观察结果
+	for i := range executions {
+		if !executions[i].Approved {
+			skipped = append(skipped, i)
+		}
+	}
+	r.notifyObservations(executions, skipped)
+
+	var batch []int // 等待并发执行的相邻只读调用
+	flush := func() {
+		r.runParallel(ctx, executions, batch)
+		r.notifyObservations(executions, batch)
+		batch = nil
+	}
+	for _, i := range approved {
+		if r.readOnly(toolCalls[i]) {
+			batch = append(batch, i)
+			continue
+		}
+		flush()
+		r.run(ctx, &executions[i])
+		r.notifyObservations(executions, []int{i})
+	}
+	flush()
+	return executions
+}
+
+// approve 请求批准 pending 中的工具调用，返回与 pending 一一对应的结果。
+// 优先使用 ApproveBatch 一次性请求批准，其次逐个调用 Approve，两者都为 nil 时全部批准。
+func (r *Runner) approve(toolCalls []llm.ToolCall, pending []int) []Approval {
+	approvals := make([]Approval, len(pending))
+	switch {
+	case len(pending) == 0:
+	case r.Callbacks.ApproveBatch != nil:
+		calls := make([]llm.ToolCall, len(pending))
+		for n, i := range pending {
+			calls[n] = toolCalls[i]
+		}
+		copy(approvals, r.Callbacks.ApproveBatch(calls))
+	case r.Callbacks.Approve != nil:
+		for n, i := range pending {
+			approvals[n] = Approval{Approved: r.Callbacks.Approve(toolCalls[i])}
+		}
+	default:
+		for n := range approvals {
+			approvals[n] = Approval{Approved: true}
+		}
+	}
+	return approvals
+}
+
+// checkPolicy 在 ToolSet 实现了 PolicyChecker 时检查工具调用是否被允许。
+func (r *Runner) checkPolicy(toolCall llm.ToolCall) error {
+	if checker, ok := r.tools.(PolicyChecker); ok {
+		return checker.Check(toolCall)
+	}
+	return nil
+}
+
+// readOnly 判断一个工具调用是否可以与其他只读调用并发执行。
+func (r *Runner) readOnly(toolCall llm.ToolCall) bool {
+	checker, ok := r.tools.(ReadOnlyChecker)
+	return ok && checker.ReadOnly(toolCall)
+}
+
+// runParallel 使用容量为 MaxParallelTools 的工作池并发执行 indexes 对应的调用。
+func (r *Runner) runParallel(ctx context.Context, executions []ToolExecution, indexes []int) {
+	if len(indexes) == 0 {
+		return
+	}
+	if len(indexes) == 1 {
+		r.run(ctx, &executions[indexes[0]])
+		return
+	}
+	workers := r.MaxParallelTools
+	if workers <= 0 {
+		workers = DefaultMaxParallelTools
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, i := range indexes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(execution *ToolExecution) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.run(ctx, execution)
+		}(&executions[i])
+	}
+	wg.Wait()
+}
+
+// run 执行一个已获得批准的工具调用，并把结果整理成观察结果。
+func (r *Runner) run(ctx context.Context, execution *ToolExecution) {
+	if err := ctx.Err(); err != nil {
+		execution.Err = err
+		execution.Observation = "Execution skipped: the task was cancelled by the user."
+		return
+	}
+	start := time.Now()
+	var observation string
+	var err error
+	if executor, ok := r.tools.(CommandExecutor); ok {
+		observation, execution.Command, execution.ExitCode, execution.Redactions, err = executor.ExecuteCommand(ctx, execution.Call)
+	} else {
+		observation, err = r.tools.Execute(ctx, execution.Call)
+	}
+	execution.Duration = time.Since(start)
+	if err != nil {
+		// 如果工具执行失败，将错误信息作为观察结果。
+		// 这允许 LLM "看到"错误并据此决定下一步行动。
+		execution.Err = err
+		observation = fmt.Sprintf("Error: %v", err)
+	}
+	execution.Observation = observation
+}
+
+// notifyObservations 补全 indexes 对应调用的观察结果、替换其中的敏感信息，并按顺序触发 OnObservation。
+func (r *Runner) notifyObservations(executions []ToolExecution, indexes []int) {
+	for _, i := range indexes {
+		execution := &executions[i]
+		// ToolSet 已经替换过的内容不会被重复计数，这里替换的是错误信息等其余部分
+		var redactions redact.Report
+		execution.Observation, redactions = r.Redactor.Redact(execution.Observation)
+		execution.Redactions = execution.Redactions.Merge(redactions)
+		// 确保 observation 永不为空
+		if execution.Observation == "" {
+			execution.Observation = "(No output)"
+		}
+		if execution.RepairedFrom != "" {
+			execution.Observation += "\n(Note: the arguments of this call were not valid JSON and were repaired before running; please emit plain JSON arguments.)"
+		}
+		if r.Callbacks.OnObservation != nil {
+			r.Callbacks.OnObservation(*execution)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// timeline 记录工具调用开始和结束的顺序，以及同时执行的调用数的最大值。
+type timeline struct {
+	mu        sync.Mutex
+	events    []string
+	active    int
+	maxActive int
+}
+
+func (tl *timeline) start(id string) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.events = append(tl.events, "start "+id)
+	tl.active++
+	tl.maxActive = max(tl.maxActive, tl.active)
+}
+
+func (tl *timeline) end(id string) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.events = append(tl.events, "end "+id)
+	tl.active--
+}
+
+// index 返回事件的位置，事件不存在时测试失败。
+func (tl *timeline) index(t *testing.T, event string) int {
+	t.Helper()
+	i := slices.Index(tl.events, event)
+	if i < 0 {
+		t.Fatalf("event %q did not happen: %q", event, tl.events)
+	}
+	return i
+}
+
+// readOnlyCall 和 mutatingCall 创建 fakeTools 中只读和会修改系统状态的调用。
+func readOnlyCall(id string) llm.ToolCall { return toolCall(id, "ps", `{}`) }
+func mutatingCall(id string) llm.ToolCall { return toolCall(id, "wget", `{}`) }
+
+// executeAll 使用 runner 执行一步中的工具调用，并按触发顺序返回 OnObservation 收到的调用 ID。
+func executeAll(ctx context.Context, runner *Runner, calls []llm.ToolCall) ([]ToolExecution, []string) {
+	var mu sync.Mutex
+	var observed []string
+	runner.Callbacks.OnObservation = func(execution ToolExecution) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, execution.Call.ID)
+	}
+	executions := runner.executeToolCalls(ctx, calls, repairToolCalls(calls))
+	return executions, observed
+}
+
+func TestExecuteToolCallsOrderAndBarriers(t *testing.T) {
+	tl := &timeline{}
+	// r1、r2、r3 必须同时执行才能通过这个集合点；r1 最后结束，使完成顺序与调用顺序不同
+	var rendezvous sync.WaitGroup
+	rendezvous.Add(3)
+	tools := &fakeTools{
+		readOnly: map[string]bool{"ps": true},
+		execute: func(ctx context.Context, call llm.ToolCall) (string, error) {
+			tl.start(call.ID)
+			defer tl.end(call.ID)
+			switch call.ID {
+			case "r1", "r2", "r3":
+				rendezvous.Done()
+				done := make(chan struct{})
+				go func() { rendezvous.Wait(); close(done) }()
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					return "", fmt.Errorf("%s did not run in parallel with the other read-only calls", call.ID)
+				}
+				if call.ID == "r1" {
+					time.Sleep(20 * time.Millisecond)
+				}
+			}
+			return "output of " + call.ID, nil
+		},
+	}
+	runner := NewRunner(&scriptedLLM{}, "test-model", tools, history.NewHistoryManager())
+	calls := []llm.ToolCall{
+		readOnlyCall("r1"), readOnlyCall("r2"), readOnlyCall("r3"),
+		mutatingCall("w1"),
+		readOnlyCall("r4"), readOnlyCall("r5"),
+		mutatingCall("w2"),
+	}
+
+	executions, observed := executeAll(context.Background(), runner, calls)
+	want := []string{"r1", "r2", "r3", "w1", "r4", "r5", "w2"}
+	for i, execution := range executions {
+		if execution.Call.ID != want[i] || execution.Observation != "output of "+want[i] || execution.Err != nil {
+			t.Errorf("executions[%d] = %+v, want the result of %s", i, execution, want[i])
+		}
+	}
+	if !slices.Equal(observed, want) {
+		t.Errorf("observations in order %q, want the call order %q", observed, want)
+	}
+	if tl.index(t, "end r2") > tl.index(t, "end r1") || tl.index(t, "end r3") > tl.index(t, "end r1") {
+		t.Errorf("r1 did not finish last: %q", tl.events)
+	}
+
+	// 修改系统状态的调用是分界：它在之前的调用都结束之后才开始，之后的调用在它结束之后才开始
+	for _, before := range []string{"r1", "r2", "r3"} {
+		if tl.index(t, "end "+before) > tl.index(t, "start w1") {
+			t.Errorf("w1 started before %s finished: %q", before, tl.events)
+		}
+	}
+	for _, after := range []string{"r4", "r5"} {
+		if tl.index(t, "start "+after) < tl.index(t, "end w1") {
+			t.Errorf("%s started before w1 finished: %q", after, tl.events)
+		}
+		if tl.index(t, "end "+after) > tl.index(t, "start w2") {
+			t.Errorf("w2 started before %s finished: %q", after, tl.events)
+		}
+	}
+}
+
+func TestRunParallelLimit(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxParallelTools int
+		want             int
+	}{
+		{name: "configured", maxParallelTools: 3, want: 3},
+		{name: "default", maxParallelTools: 0, want: DefaultMaxParallelTools},
+		{name: "sequential", maxParallelTools: 1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := &timeline{}
+			tools := &fakeTools{
+				readOnly: map[string]bool{"ps": true},
+				execute: func(ctx context.Context, call llm.ToolCall) (string, error) {
+					tl.start(call.ID)
+					defer tl.end(call.ID)
+					time.Sleep(20 * time.Millisecond)
+					return "ok", nil
+				},
+			}
+			runner := NewRunner(&scriptedLLM{}, "test-model", tools, history.NewHistoryManager())
+			runner.MaxParallelTools = tt.maxParallelTools
+			var calls []llm.ToolCall
+			for i := range 10 {
+				calls = append(calls, readOnlyCall(fmt.Sprintf("r%d", i)))
+			}
+
+			executions, observed := executeAll(context.Background(), runner, calls)
+			if tl.maxActive != tt.want {
+				t.Errorf("at most %d calls ran at the same time, want %d", tl.maxActive, tt.want)
+			}
+			if len(tools.executed) != len(calls) || len(observed) != len(calls) {
+				t.Errorf("executed %d calls and observed %d, want %d", len(tools.executed), len(observed), len(calls))
+			}
+			for i, execution := range executions {
+				if execution.Call.ID != calls[i].ID || execution.Observation != "ok" {
+					t.Errorf("executions[%d] = %+v", i, execution)
+				}
+			}
+		})
+	}
+}
+
+func TestApproveBatchShortResult(t *testing.T) {
+	tools := &fakeTools{readOnly: map[string]bool{"ps": true}}
+	runner := NewRunner(&scriptedLLM{}, "test-model", tools, history.NewHistoryManager())
+	var asked []string
+	runner.Callbacks.ApproveBatch = func(calls []llm.ToolCall) []Approval {
+		for _, call := range calls {
+			asked = append(asked, call.ID)
+		}
+		// 只回答了前两个调用
+		return []Approval{
+			{Approved: true, By: "rule allow-ps"},
+			{Approved: false, By: "rule deny-wget", Reason: "Denied by rule deny-wget."},
+		}
+	}
+	calls := []llm.ToolCall{readOnlyCall("c1"), mutatingCall("c2"), mutatingCall("c3"), readOnlyCall("c4")}
+
+	executions, observed := executeAll(context.Background(), runner, calls)
+	if !slices.Equal(asked, []string{"c1", "c2", "c3", "c4"}) {
+		t.Errorf("ApproveBatch was asked about %q, want every call", asked)
+	}
+	if !slices.Equal(tools.executed, []string{"c1"}) {
+		t.Errorf("executed %q, want only c1", tools.executed)
+	}
+	tests := []struct {
+		approved    bool
+		by          string
+		observation string
+	}{
+		{true, "rule allow-ps", "output of ps"},
+		{false, "rule deny-wget", "Denied by rule deny-wget."},
+		{false, "", "User cancelled the execution of this tool."},
+		{false, "", "User cancelled the execution of this tool."},
+	}
+	for i, want := range tests {
+		got := executions[i]
+		if got.Approved != want.approved || got.ApprovedBy != want.by || got.Observation != want.observation {
+			t.Errorf("executions[%d] = approved %v by %q, observation %q; want %v by %q, %q",
+				i, got.Approved, got.ApprovedBy, got.Observation, want.approved, want.by, want.observation)
+		}
+	}
+	// 未执行的调用先得到观察结果
+	if want := []string{"c2", "c3", "c4", "c1"}; !slices.Equal(observed, want) {
+		t.Errorf("observations in order %q, want %q", observed, want)
+	}
+}
+
+func TestExecuteToolCallsCancelledMidBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r2Started := make(chan struct{})
+	tools := &fakeTools{
+		readOnly: map[string]bool{"ps": true},
+		execute: func(ctx context.Context, call llm.ToolCall) (string, error) {
+			switch call.ID {
+			case "r1":
+				// 用户在 r1 和 r2 执行时按下了 Ctrl-C
+				<-r2Started
+				cancel()
+				return "partial output", ctx.Err()
+			case "r2":
+				// r2 与 r1 同时执行，等到任务被取消才返回
+				close(r2Started)
+				<-ctx.Done()
+				return "", ctx.Err()
+			}
+			return "output of " + call.ID, nil
+		},
+	}
+	runner := NewRunner(&scriptedLLM{}, "test-model", tools, history.NewHistoryManager())
+	runner.MaxParallelTools = 2
+	calls := []llm.ToolCall{readOnlyCall("r1"), readOnlyCall("r2"), readOnlyCall("r3"), readOnlyCall("r4"), mutatingCall("w1")}
+
+	executions, observed := executeAll(ctx, runner, calls)
+	executed := slices.Sorted(slices.Values(tools.executed))
+	if !slices.Equal(executed, []string{"r1", "r2"}) {
+		t.Errorf("executed %q, want only r1 and r2", executed)
+	}
+	for i, execution := range executions {
+		if execution.Err == nil || execution.Observation == "" {
+			t.Errorf("executions[%d] = %+v, want an error and an observation", i, execution)
+		}
+		if i >= 2 && !strings.Contains(execution.Observation, "cancelled") {
+			t.Errorf("executions[%d] observation = %q, want it skipped", i, execution.Observation)
+		}
+	}
+	// 每个调用都有且只有一次观察结果，历史记录可以保持完整
+	if want := []string{"r1", "r2", "r3", "r4", "w1"}; !slices.Equal(observed, want) {
+		t.Errorf("observations in order %q, want %q", observed, want)
+	}
+}
@@ -0,0 +1,287 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry 是审计日志中的一条记录，对应一次工具调用（包括被拒绝、没有执行的调用）。
+type Entry struct {
+	Seq          int       `json:"seq"` // 从 1 开始的序号，由 Logger 填写
+	Time         time.Time `json:"time"`
+	SessionID    string    `json:"session_id"`
+	Prompt       string    `json:"prompt"` // 触发这次调用的用户输入
+	Tool         string    `json:"tool"`
+	CallID       string    `json:"call_id,omitempty"`
+	Arguments    string    `json:"arguments"`               // 模型给出的原始参数（参数被修复时为修复后的参数）
+	RepairedFrom string    `json:"repaired_from,omitempty"` // 参数被修复时，修复前的参数
+	Command      string    `json:"command,omitempty"`       // 实际执行的命令行
+	Approved     bool      `json:"approved"`
+	ApprovedBy   string    `json:"approved_by,omitempty"` // 由谁批准或拒绝了执行
+	ExitCode     int       `json:"exit_code"`             // 工具没有执行，或者 ToolSet 不报告退出码且执行失败时为 -1
+	DurationMS   int64     `json:"duration_ms"`
+	Error        string    `json:"error,omitempty"`
+	// OutputSHA256 是反馈给模型的观察结果的 SHA-256，与历史记录中的内容一致：
+	// 敏感信息已被替换，超出限制的输出是截断后的内容，而不是 read_output 可以读取的完整输出。
+	OutputSHA256 string `json:"output_sha256"`
+
+	// PrevHash 是上一条记录的 Hash，第一条记录为空。
+	// Hash 是 PrevHash 与本条记录其余内容的 SHA-256，由 Logger 填写。
+	// 修改、插入或删除任何一条记录都会使之后的哈希链无法通过校验。
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash,omitempty"`
+
+	// AfterTorn 表示本条记录之前有被中断的写入留下的不完整记录。
+	// 本条记录开始一个新的哈希链片段，它的 PrevHash 仍然是最后一条完整记录的 Hash。
+	AfterTorn bool `json:"after_torn,omitempty"`
+}
+
+const (
+	maxEntrySize  = 64 * 1024 * 1024 // 单条记录的最大长度
+	tailChunkSize = 64 * 1024        // 从文件末尾向前读取时每次读取的字节数
+)
+
+// HashOutput 返回观察结果的 SHA-256，用于填写 Entry.OutputSHA256。
+func HashOutput(output string) string {
+	sum := sha256.Sum256([]byte(output))
+	return hex.EncodeToString(sum[:])
+}
+
+// Logger 以只追加的 JSONL 文件记录审计日志。它可以在多个 goroutine 中安全使用，
+// 多个进程也可以同时写入同一个日志：每次追加都持有文件的排他锁，并在锁内重新读取末尾的记录以延续哈希链。
+type Logger struct {
+	mu       sync.Mutex
+	file     *os.File
+	tornTail bool
+}
+
+// Open 打开（或创建）path 处的审计日志。已有的日志不会被校验，校验请使用 Verify。
+func Open(path string) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("error creating audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %w", err)
+	}
+	l := &Logger{file: file}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error locking audit log: %w", err)
+	}
+	_, l.tornTail, _, err = l.tail()
+	unlockFile(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading audit log: %w", err)
+	}
+	return l, nil
+}
+
+// TornTail 报告打开日志时其末尾是否有被中断的写入留下的不完整记录。
+// 这样的记录会被保留，之后追加的第一条记录设置 AfterTorn，开始一个新的哈希链片段。
+func (l *Logger) TornTail() bool {
+	return l.tornTail
+}
+
+// Record 为 entry 填写序号和哈希，并把它追加到日志中。entry.Time 为空时使用当前时间。
+func (l *Logger) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := lockFile(l.file); err != nil {
+		return fmt.Errorf("error locking audit log: %w", err)
+	}
+	defer unlockFile(l.file)
+
+	// 其他进程可能已经追加了记录，因此每次都在持有锁时重新读取最后一条记录
+	last, torn, newline, err := l.tail()
+	if err != nil {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Seq = last.Seq + 1
+	entry.PrevHash = last.Hash
+	entry.AfterTorn = torn
+	entry.Hash = ""
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	if entry.Hash, err = chainHash(line); err != nil {
+		return err
+	}
+	if line, err = json.Marshal(entry); err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	line = append(line, '\n')
+	if !newline {
+		// 结束被中断的那一行，使新记录从新的一行开始
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	return nil
+}
+
+// tail 从文件末尾向前读取，返回最后一条完整的记录（日志为空时为零值）。
+// torn 表示这条记录之后还有无法解码的内容，newline 表示文件是否以换行结尾。
+func (l *Logger) tail() (last Entry, torn, newline bool, err error) {
+	first := true
+	newline = true
+	err = lastLines(l.file, func(line []byte) bool {
+		if first {
+			first = false
+			if len(line) == 0 {
+				return true
+			}
+			newline = false
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.Hash == "" {
+			torn = true
+			return true
+		}
+		last = entry
+		return false
+	})
+	return last, torn, newline, err
+}
+
+// lastLines 从文件末尾向前依次把每一行（不含换行符）传给 fn，直到 fn 返回 false 或读到文件开头。
+// 文件以换行结尾时，传给 fn 的第一行为空。fn 不能保留 line。
+func lastLines(f *os.File, fn func(line []byte) bool) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+	if offset == 0 {
+		return nil
+	}
+	var buf []byte // 文件中从 offset 开始、尚未交给 fn 的内容
+	for {
+		for {
+			i := bytes.LastIndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+			line := buf[i+1:]
+			buf = buf[:i]
+			if !fn(line) {
+				return nil
+			}
+		}
+		if offset == 0 {
+			fn(buf)
+			return nil
+		}
+		n := min(offset, tailChunkSize)
+		offset -= n
+		chunk := make([]byte, n, n+int64(len(buf)))
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return err
+		}
+		buf = append(chunk, buf...)
+	}
+}
+
+// Close 关闭日志文件。
+func (l *Logger) Close() error {
+	return l.file.Close()
+}
+
+// Report 是 Verify 的校验结果。
+type Report struct {
+	Count    int    // 校验通过的记录数
+	LastHash string // 最后一条记录的哈希
+	Torn     []int  // 被中断的写入留下的不完整记录所在的行号
+}
+
+// Verify 校验 path 处审计日志的哈希链。
+// 任何一条记录被修改、插入、删除或调换顺序时都会返回描述第一处问题的错误。
+// 无法解码的行只有在位于日志末尾，或者紧接着一条设置了 AfterTorn 的记录时，才被视为被中断的写入，
+// 记录在 Report.Torn 中而不是返回错误。
+// 末尾的记录被整体截掉时哈希链仍然完整，需要与此前保存的最后一个哈希比较才能发现。
+func Verify(path string) (report Report, err error) {
+	var torn []int // 上一条记录之后的不完整行
+	err = readLines(path, func(line int, raw []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			report.Torn = append(report.Torn, line)
+			torn = append(torn, line)
+			return nil
+		}
+		if len(torn) > 0 && !entry.AfterTorn {
+			return fmt.Errorf("line %d: error decoding audit entry", torn[0])
+		}
+		if len(torn) == 0 && entry.AfterTorn {
+			return fmt.Errorf("line %d: after_torn is set, but no incomplete entry precedes it", line)
+		}
+		torn = nil
+		if entry.Seq != report.Count+1 {
+			return fmt.Errorf("line %d: expected seq %d, found %d (entries missing or reordered)", line, report.Count+1, entry.Seq)
+		}
+		if entry.PrevHash != report.LastHash {
+			return fmt.Errorf("line %d: prev_hash does not match the hash of the previous entry", line)
+		}
+		hash, err := chainHash(raw)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("line %d: hash mismatch, the entry has been modified", line)
+		}
+		report.Count, report.LastHash = entry.Seq, entry.Hash
+		return nil
+	})
+	return report, err
+}
+
+// chainHash 计算一条记录的哈希：对除 hash 以外的所有字段（按键排序、值保持原样）序列化后取 SHA-256。
+// 记录中包含 prev_hash，因此每条记录的哈希都依赖于之前的所有记录。
+// 直接使用原始字段而不是 Entry 结构体，使得被额外添加的字段同样无法通过校验。
+func chainHash(raw []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", fmt.Errorf("error decoding audit entry: %w", err)
+	}
+	delete(fields, "hash")
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readLines 按顺序读取日志中的每一行。
+func readLines(path string, fn func(line int, raw []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEntrySize)
+	for line := 1; scanner.Scan(); line++ {
+		if err := fn(line, scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeLog 用 Logger 写入 n 条记录，返回日志路径。
+func writeLog(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	for i := 0; i < n; i++ {
+		if err := logger.Record(Entry{Tool: "find", Arguments: `{"path":"/var/log"}`, Approved: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func readLogLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func writeLogLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := writeLog(t, 3)
+	report, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count != 3 || report.LastHash == "" || len(report.Torn) != 0 {
+		t.Errorf("Verify() = %+v, want 3 intact entries", report)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(lines []string) []string
+		wantErr string
+	}{
+		{
+			name:    "modified field",
+			tamper:  func(l []string) []string { l[1] = strings.Replace(l[1], `"tool":"find"`, `"tool":"grep"`, 1); return l },
+			wantErr: "line 2: hash mismatch",
+		},
+		{
+			name:    "added field",
+			tamper:  func(l []string) []string { l[1] = strings.Replace(l[1], `{`, `{"extra":1,`, 1); return l },
+			wantErr: "line 2: hash mismatch",
+		},
+		{
+			name:    "reordered",
+			tamper:  func(l []string) []string { l[0], l[1] = l[1], l[0]; return l },
+			wantErr: "line 1: expected seq 1, found 2",
+		},
+		{
+			name:    "deleted",
+			tamper:  func(l []string) []string { return slices.Delete(l, 1, 2) },
+			wantErr: "line 2: expected seq 2, found 3",
+		},
+		{
+			name:    "first entry deleted",
+			tamper:  func(l []string) []string { return l[1:] },
+			wantErr: "line 1: expected seq 1, found 2",
+		},
+		{
+			name:    "duplicated",
+			tamper:  func(l []string) []string { return slices.Insert(l, 1, l[0]) },
+			wantErr: "line 2: expected seq 2, found 1",
+		},
+		{
+			name:    "garbage in the middle",
+			tamper:  func(l []string) []string { return slices.Insert(l, 1, `{"seq":`) },
+			wantErr: "line 2: error decoding audit entry",
+		},
+		{
+			name: "after_torn without a torn entry",
+			tamper: func(l []string) []string {
+				l[1] = strings.Replace(l[1], `"prev_hash"`, `"after_torn":true,"prev_hash"`, 1)
+				return l
+			},
+			wantErr: "line 2: after_torn is set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, 3)
+			writeLogLines(t, path, tt.tamper(readLogLines(t, path)))
+			_, err := Verify(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTornTailStartsNewSegment(t *testing.T) {
+	path := writeLog(t, 2)
+	// 模拟写入第 3 条记录时进程崩溃，只留下了半行
+	lines := readLogLines(t, path)
+	torn := lines[1][:len(lines[1])/2]
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(torn); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	report, err := Verify(path)
+	if err != nil {
+		t.Fatalf("Verify() with a torn tail = %v, want it reported only", err)
+	}
+	if report.Count != 2 || !slices.Equal(report.Torn, []int{3}) {
+		t.Errorf("Verify() = %+v, want 2 entries and line 3 torn", report)
+	}
+
+	logger, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !logger.TornTail() {
+		t.Error("TornTail() = false, want true")
+	}
+	for i := 0; i < 2; i++ {
+		if err := logger.Record(Entry{Tool: "ps"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger.Close()
+
+	report, err = Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count != 4 || !slices.Equal(report.Torn, []int{3}) {
+		t.Errorf("Verify() = %+v, want 4 entries and line 3 torn", report)
+	}
+	if lines := readLogLines(t, path); len(lines) != 5 || lines[2] != torn {
+		t.Errorf("log lines = %q, want the torn line kept on its own line", lines)
+	}
+}
+
+func TestConcurrentLoggers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// 两个 Logger 相当于两个进程，各自打开同一个日志
+	var loggers []*Logger
+	for i := 0; i < 2; i++ {
+		logger, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logger.Close()
+		loggers = append(loggers, logger)
+	}
+
+	const perLogger = 50
+	var wg sync.WaitGroup
+	for _, logger := range loggers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perLogger; i++ {
+				if err := logger.Record(Entry{Tool: "ss"}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	report, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count != 2*perLogger {
+		t.Errorf("Verify() count = %d, want %d", report.Count, 2*perLogger)
+	}
+}
+
+func TestRecordEntriesLargerThanTailChunk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	// 最后一条记录跨越多次从末尾向前的读取
+	for _, size := range []int{10, 3 * tailChunkSize, tailChunkSize - 1, 10} {
+		if err := logger.Record(Entry{Tool: "grep", Prompt: strings.Repeat("x", size)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count != 4 {
+		t.Errorf("Verify() count = %d, want 4", report.Count)
+	}
+}
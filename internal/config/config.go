@@ -2,8 +2,14 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/DoraZa/mini-agent/internal/policy"
+	"github.com/DoraZa/mini-agent/internal/redact"
+	"github.com/DoraZa/mini-agent/internal/usage"
 	"github.com/spf13/viper"
 )
 
@@ -14,18 +20,336 @@ type Config struct {
 	BaseURL      string   `mapstructure:"base_url"`      // LLM API 端点
 	AllowedTools []string `mapstructure:"allowed_tools"` // 允许使用的工具列表
 	DeniedTools  []string `mapstructure:"denied_tools"`  // 禁止使用的工具列表
+
+	ToolRules []ToolRuleConfig `mapstructure:"tool_rules"` // 按参数限制工具调用的规则
+	Sandbox   SandboxConfig    `mapstructure:"sandbox"`    // 工具可以读写的文件系统路径
+
+	ToolTimeout  time.Duration            `mapstructure:"tool_timeout"`  // 工具执行的默认超时，0 表示不限制
+	ToolTimeouts map[string]time.Duration `mapstructure:"tool_timeouts"` // 按工具名称覆盖的执行超时
+	Stream       bool                     `mapstructure:"stream"`        // 是否以流式方式输出 LLM 的响应
+
+	MaxParallelTools int            `mapstructure:"max_parallel_tools"` // 同一步中最多同时执行的只读工具调用数
+	Approval         ApprovalConfig `mapstructure:"approval"`           // 工具调用的批准策略
+
+	MaxObservationBytes int `mapstructure:"max_observation_bytes"` // 单次工具输出的最大字节数，超出时截断，0 表示不限制
+	MaxObservationLines int `mapstructure:"max_observation_lines"` // 单次工具输出的最大行数，超出时截断，0 表示不限制
+
+	DownloadDir      string `mapstructure:"download_dir"`       // wget 工具允许写入的目录
+	MaxDownloadBytes int64  `mapstructure:"max_download_bytes"` // wget 工具单次下载的最大字节数
+
+	Retry             RetryConfig `mapstructure:"retry"`               // LLM 请求的重试策略
+	RequestsPerMinute int         `mapstructure:"requests_per_minute"` // 每分钟最多发出的 LLM 请求数，0 表示不限制
+
+	Models        []ModelConfig `mapstructure:"models"`         // 按模型划分的配置，例如计费标准
+	ContextWindow int           `mapstructure:"context_window"` // 未单独配置的模型使用的上下文窗口大小，0 表示不裁剪历史
+
+	Compaction CompactionConfig `mapstructure:"compaction"` // 对话历史的自动压缩
+
+	DataDir string `mapstructure:"data_dir"` // 保存会话等数据的目录
+
+	Audit     AuditConfig     `mapstructure:"audit"`     // 工具调用的审计日志
+	Redaction RedactionConfig `mapstructure:"redaction"` // 发送给 LLM 之前对工具输出的脱敏
+}
+
+// SessionDir 返回保存会话记录的目录
+func (c *Config) SessionDir() string {
+	return filepath.Join(c.DataDir, "sessions")
+}
+
+// AuditConfig 定义了记录每次工具调用的审计日志
+type AuditConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 是否记录审计日志
+	Path    string `mapstructure:"path"`    // 日志文件路径，为空时使用数据目录下的 audit.jsonl
+}
+
+// AuditLogPath 返回审计日志的路径
+func (c *Config) AuditLogPath() string {
+	if c.Audit.Path != "" {
+		return c.Audit.Path
+	}
+	return filepath.Join(c.DataDir, "audit.jsonl")
+}
+
+// CompactionConfig 定义了使用 LLM 将较早的对话压缩为摘要的策略
+type CompactionConfig struct {
+	Enabled    bool    `mapstructure:"enabled"`     // 是否自动压缩
+	Threshold  float64 `mapstructure:"threshold"`   // 历史记录占上下文预算的比例超过该值时触发压缩
+	KeepRecent int     `mapstructure:"keep_recent"` // 压缩时保留的最近消息组数量
+}
+
+// defaultMaxOutputTokens 是未配置 max_output_tokens 时为模型输出预留的 token 数
+const defaultMaxOutputTokens = 4096
+
+// minObservationBytes 是 max_observation_bytes 的最小值，过小的限制会让截断说明和分页页眉挤占全部输出
+const minObservationBytes = 1024
+
+// ModelConfig 返回指定模型的配置，未配置时返回 false
+func (c *Config) ModelConfig(name string) (ModelConfig, bool) {
+	for _, m := range c.Models {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return ModelConfig{Name: name}, false
+}
+
+// HistoryTokenBudget 返回指定模型可用于对话历史的 token 数：
+// 上下文窗口减去为输出预留的部分。返回 0 表示不限制。
+func (c *Config) HistoryTokenBudget(model string) int {
+	m, _ := c.ModelConfig(model)
+	window := m.ContextWindow
+	if window == 0 {
+		window = c.ContextWindow
+	}
+	if window == 0 {
+		return 0
+	}
+	reserved := m.MaxOutputTokens
+	if reserved == 0 {
+		reserved = defaultMaxOutputTokens
+	}
+	return max(window-reserved, 1)
+}
+
+// ModelConfig 定义了单个模型的配置。
+// 价格的单位是每百万 token 的美元价格，用于统计会话费用。
+type ModelConfig struct {
+	Name             string  `mapstructure:"name"`               // 模型名称，与 model 配置项对应
+	InputPrice       float64 `mapstructure:"input_price"`        // 输入 token 单价
+	CachedInputPrice float64 `mapstructure:"cached_input_price"` // 命中缓存的输入 token 单价，为 0 时按 input_price 计费
+	OutputPrice      float64 `mapstructure:"output_price"`       // 输出 token 单价
+	ContextWindow    int     `mapstructure:"context_window"`     // 上下文窗口大小（token），为 0 时使用全局的 context_window
+	MaxOutputTokens  int     `mapstructure:"max_output_tokens"`  // 为模型输出预留的 token 数，为 0 时使用默认值
+	ToolMode         string  `mapstructure:"tool_mode"`          // 工具调用方式：native（原生函数调用，默认）或 text（文本 ReAct 协议）
+}
+
+// 工具调用方式
+const (
+	ToolModeNative = "native" // 通过请求中的 tools 参数使用模型原生的函数调用
+	ToolModeText   = "text"   // 将工具定义写入系统提示词，从回复文本中解析 Action 块
+)
+
+// ToolMode 返回指定模型的工具调用方式，未配置时为 ToolModeNative
+func (c *Config) ToolMode(model string) string {
+	m, _ := c.ModelConfig(model)
+	if m.ToolMode == "" {
+		return ToolModeNative
+	}
+	return m.ToolMode
+}
+
+// ApprovalConfig 定义了工具调用的批准策略：auto 直接执行，ask 询问用户，deny 直接拒绝
+type ApprovalConfig struct {
+	Default string               `mapstructure:"default"` // 没有单独配置的工具使用的批准方式
+	Tools   map[string]string    `mapstructure:"tools"`   // 按工具名称配置的批准方式
+	Rules   []ApprovalRuleConfig `mapstructure:"rules"`   // 按参数匹配的规则，按顺序匹配，优先于 tools
+}
+
+// ApprovalRuleConfig 定义了一条按参数匹配的批准规则
+type ApprovalRuleConfig struct {
+	Name string                        `mapstructure:"name"` // 规则名称，会显示在拒绝的原因中
+	Tool string                        `mapstructure:"tool"` // 工具名称，"*" 表示所有工具
+	Args map[string]ArgConditionConfig `mapstructure:"args"` // 按参数名称配置的约束，所有约束都满足时规则才匹配
+	Mode string                        `mapstructure:"mode"` // 规则匹配时的批准方式
+}
+
+// ArgConditionConfig 定义了对一个参数的约束。除 flags 外，参数是数组时每个元素都需要满足
+type ArgConditionConfig struct {
+	Under   []string `mapstructure:"under"`   // 路径位于其中某个目录之内
+	Equals  []string `mapstructure:"equals"`  // 值等于其中之一
+	Matches []string `mapstructure:"matches"` // 值匹配其中一个通配符
+	Hosts   []string `mapstructure:"hosts"`   // URL 的主机名匹配其中一个通配符
+	Ports   string   `mapstructure:"ports"`   // 端口号位于这些范围之内，例如 "22,8000-8999"
+	Flags   []string `mapstructure:"flags"`   // 值（或数组中的某个元素）是其中一个选项
+}
+
+// ToolRuleConfig 定义了一条按参数限制工具调用的规则：
+// deny 规则匹配的调用被拒绝；工具存在 allow 规则时，调用至少需要匹配其中一条
+type ToolRuleConfig struct {
+	Name   string                        `mapstructure:"name"`   // 规则名称，会显示在拒绝的原因中
+	Tool   string                        `mapstructure:"tool"`   // 工具名称，"*" 表示所有工具
+	Effect string                        `mapstructure:"effect"` // allow 或 deny
+	Args   map[string]ArgConditionConfig `mapstructure:"args"`   // 按参数名称配置的约束，所有约束都满足时规则才匹配
+}
+
+// RuleSet 根据 tool_rules 配置创建工具调用的参数规则
+func (c *Config) RuleSet() (*policy.RuleSet, error) {
+	rules := make([]policy.ToolRule, len(c.ToolRules))
+	for i, r := range c.ToolRules {
+		rules[i] = policy.ToolRule{
+			Name:   r.Name,
+			Tool:   r.Tool,
+			Effect: policy.Effect(r.Effect),
+			Args:   conditions(r.Args),
+		}
+	}
+	ruleSet, err := policy.NewRuleSet(rules)
+	if err != nil {
+		return nil, fmt.Errorf("工具规则配置无效: %w", err)
+	}
+	return ruleSet, nil
+}
+
+// Approver 根据 approval 配置创建工具调用的批准策略
+func (c *Config) Approver() (*policy.Approver, error) {
+	tools := make(map[string]policy.Mode, len(c.Approval.Tools))
+	for name, mode := range c.Approval.Tools {
+		tools[name] = policy.Mode(mode)
+	}
+	rules := make([]policy.ApprovalRule, len(c.Approval.Rules))
+	for i, r := range c.Approval.Rules {
+		rules[i] = policy.ApprovalRule{
+			Name: r.Name,
+			Tool: r.Tool,
+			Args: conditions(r.Args),
+			Mode: policy.Mode(r.Mode),
+		}
+	}
+	approver, err := policy.NewApprover(policy.Mode(c.Approval.Default), tools, rules)
+	if err != nil {
+		return nil, fmt.Errorf("批准策略配置无效: %w", err)
+	}
+	return approver, nil
+}
+
+func conditions(args map[string]ArgConditionConfig) policy.Conditions {
+	conditions := make(policy.Conditions, len(args))
+	for name, arg := range args {
+		conditions[name] = policy.Condition{
+			Under:   arg.Under,
+			Equals:  arg.Equals,
+			Matches: arg.Matches,
+			Hosts:   arg.Hosts,
+			Ports:   arg.Ports,
+			Flags:   arg.Flags,
+		}
+	}
+	return conditions
+}
+
+// SandboxConfig 定义了工具可以访问的文件系统目录，为空时不限制对应的访问
+type SandboxConfig struct {
+	ReadRoots  []string `mapstructure:"read_roots"`  // find、grep、lsof 等工具只能读取这些目录之内的路径
+	WriteRoots []string `mapstructure:"write_roots"` // wget 等工具只能写入这些目录之内的路径
+}
+
+// FileSandbox 根据 sandbox 配置创建限制工具读写路径的沙箱
+func (c *Config) FileSandbox() *policy.Sandbox {
+	return policy.NewSandbox(c.Sandbox.ReadRoots, c.Sandbox.WriteRoots)
+}
+
+// RedactionConfig 定义了工具输出在发送给 LLM 之前的脱敏规则
+type RedactionConfig struct {
+	Enabled  bool                     `mapstructure:"enabled"`  // 是否脱敏，开启时总是使用内置的检测规则
+	Patterns []RedactionPatternConfig `mapstructure:"patterns"` // 自定义的检测规则
+}
+
+// RedactionPatternConfig 定义了一条自定义的检测规则
+type RedactionPatternConfig struct {
+	Name  string `mapstructure:"name"`  // 敏感信息的种类，会显示在替换后的文本中
+	Regex string `mapstructure:"regex"` // 正则表达式，有名为 secret 的分组时只替换该分组
+}
+
+// Redactor 根据 redaction 配置创建脱敏器，未开启时返回 nil
+func (c *Config) Redactor() (*redact.Redactor, error) {
+	if !c.Redaction.Enabled {
+		return nil, nil
+	}
+	patterns := make([]redact.Pattern, len(c.Redaction.Patterns))
+	for i, p := range c.Redaction.Patterns {
+		patterns[i] = redact.Pattern{Name: p.Name, Regex: p.Regex}
+	}
+	redactor, err := redact.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("脱敏规则配置无效: %w", err)
+	}
+	return redactor, nil
+}
+
+// RetryConfig 定义了 LLM 请求遇到临时错误（限流、5xx、网络错误）时的重试策略
+type RetryConfig struct {
+	MaxRetries     int           `mapstructure:"max_retries"`     // 最大重试次数
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"` // 首次重试前的等待时间
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`     // 单次等待时间的上限
+}
+
+// PriceTable 根据 models 配置构建模型价格表
+func (c *Config) PriceTable() usage.PriceTable {
+	prices := make(usage.PriceTable, len(c.Models))
+	for _, m := range c.Models {
+		prices[m.Name] = usage.Price{
+			Input:       m.InputPrice,
+			CachedInput: m.CachedInputPrice,
+			Output:      m.OutputPrice,
+		}
+	}
+	return prices
 }
 
 // LoadConfig 从配置文件和环境变量加载配置
 // 优先级: 环境变量 > 配置文件 > 默认值
 func LoadConfig() (*Config, error) {
+	cfg, err := LoadLocalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// 验证必要配置
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("未设置 AGENT_API_KEY 环境变量或配置值")
+	}
+	if cfg.MaxObservationBytes < 0 || cfg.MaxObservationBytes > 0 && cfg.MaxObservationBytes < minObservationBytes {
+		return nil, fmt.Errorf("max_observation_bytes 配置无效: %d，必须为 0（不限制）或不小于 %d", cfg.MaxObservationBytes, minObservationBytes)
+	}
+	if cfg.MaxObservationLines < 0 {
+		return nil, fmt.Errorf("max_observation_lines 配置无效: %d，必须为 0（不限制）或正数", cfg.MaxObservationLines)
+	}
+	for _, m := range cfg.Models {
+		if m.ToolMode != "" && m.ToolMode != ToolModeNative && m.ToolMode != ToolModeText {
+			return nil, fmt.Errorf("模型 %s 的 tool_mode 配置无效: %q，可选值为 native 或 text", m.Name, m.ToolMode)
+		}
+	}
+
+	return cfg, nil
+}
+
+// LoadLocalConfig 与 LoadConfig 相同，但不校验访问 LLM 所需的配置项，
+// 供 sessions list 等不需要访问 LLM 的子命令使用
+func LoadLocalConfig() (*Config, error) {
 	v := viper.New()
 
 	// 设置默认配置
 	v.SetDefault("model", "deepseek-coder")
 	v.SetDefault("base_url", "https://api.deepseek.com/v1")
-	v.SetDefault("allowed_tools", []string{"ps", "find", "grep", "wget", "ss", "lsof"})
+	v.SetDefault("allowed_tools", []string{"ps", "find", "grep", "wget", "ss", "lsof", "read_output"})
 	v.SetDefault("denied_tools", []string{})
+	v.SetDefault("sandbox.read_roots", []string{})
+	v.SetDefault("sandbox.write_roots", []string{})
+	v.SetDefault("stream", true)
+	v.SetDefault("tool_timeout", "60s")
+	v.SetDefault("tool_timeouts", map[string]string{})
+	v.SetDefault("max_parallel_tools", 4)
+	v.SetDefault("approval.default", "ask")
+	v.SetDefault("approval.tools", map[string]string{
+		"ps": "auto", "find": "auto", "grep": "auto", "ss": "auto", "lsof": "auto", "read_output": "auto",
+		"wget": "ask",
+	})
+	v.SetDefault("max_observation_bytes", 16384)
+	v.SetDefault("max_observation_lines", 400)
+	v.SetDefault("download_dir", ".")
+	v.SetDefault("max_download_bytes", 100<<20)
+	v.SetDefault("retry.max_retries", 3)
+	v.SetDefault("retry.initial_backoff", "1s")
+	v.SetDefault("retry.max_backoff", "30s")
+	v.SetDefault("requests_per_minute", 0)
+	v.SetDefault("context_window", 65536)
+	v.SetDefault("compaction.enabled", true)
+	v.SetDefault("compaction.threshold", 0.75)
+	v.SetDefault("compaction.keep_recent", 4)
+	v.SetDefault("data_dir", defaultDataDir())
+	v.SetDefault("audit.enabled", true)
+	v.SetDefault("audit.path", "")
+	v.SetDefault("redaction.enabled", true)
 
 	// 配置 Viper
 	v.SetConfigName("config")
@@ -51,10 +375,14 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("解析配置失败: %w", err)
 	}
 
-	// 验证必要配置
-	if cfg.APIKey == "" {
-		return nil, fmt.Errorf("未设置 AGENT_API_KEY 环境变量或配置值")
-	}
-
 	return &cfg, nil
 }
+
+// defaultDataDir 返回默认的数据目录 ~/.mini-agent，无法确定用户主目录时使用当前目录下的 .mini-agent
+func defaultDataDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".mini-agent"
+	}
+	return filepath.Join(home, ".mini-agent")
+}
@@ -0,0 +1,177 @@
+package history
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+const (
+	// messageOverheadTokens 是每条消息在角色、分隔符等结构上的大致开销。
+	messageOverheadTokens = 4
+	// elidedObservationKeep 是省略旧观察结果时保留的开头字符数。
+	elidedObservationKeep = 200
+)
+
+// TokenEstimator 估算一条消息会占用多少 token。
+type TokenEstimator func(message llm.Message) int
+
+// EstimateTokens 用启发式规则估算一段文本的 token 数：
+// ASCII 字符大约每 4 个算一个 token，其他字符（如中文）每个算一个 token。
+// 它不依赖具体模型的分词器，结果偏保守，足以用于上下文窗口预算。
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateMessageTokens 是默认的 TokenEstimator，基于 EstimateTokens 估算消息及其工具调用的大小。
+func EstimateMessageTokens(message llm.Message) int {
+	tokens := messageOverheadTokens
+	if message.Content != nil {
+		tokens += EstimateTokens(*message.Content)
+	}
+	for _, call := range message.ToolCalls {
+		tokens += EstimateTokens(call.ID) + EstimateTokens(call.Function.Name) + EstimateTokens(call.Function.Arguments)
+	}
+	return tokens
+}
+
+// unit 是裁剪历史记录时不可分割的最小单位：
+// 一条用户消息、一条普通助手消息，或者一条带 tool_calls 的助手消息连同其后的工具观察结果。
+// 保证它们成组保留或丢弃，可以避免出现缺少对应 tool_call 的工具消息，导致 API 报错。
+type unit struct {
+	messages []llm.Message
+	tokens   int
+}
+
+// trimToBudget 在不修改原始消息的前提下，返回一份不超过 budget 个 token 的历史视图。
+// 裁剪策略依次为：
+//  1. 始终保留开头的系统消息、最近一条用户消息以及最新的一组消息；
+//  2. 先将较早的工具观察结果省略为简短摘要；
+//  3. 仍然超出预算时，从最早的消息组开始整组丢弃，并在开头的系统消息中加上一条说明。
+func trimToBudget(messages []llm.Message, budget int, estimate TokenEstimator) []llm.Message {
+	// 拆分开头的系统消息与其余的消息组
+	var system []llm.Message
+	i := 0
+	for ; i < len(messages) && messages[i].Role == "system"; i++ {
+		system = append(system, messages[i])
+	}
+	units := splitUnits(messages[i:], estimate)
+
+	total := 0
+	for _, m := range system {
+		total += estimate(m)
+	}
+	for _, u := range units {
+		total += u.tokens
+	}
+	if total <= budget || len(units) == 0 {
+		return messages
+	}
+
+	// 最新的消息组以及最近一条用户消息所在的组不会被省略或丢弃
+	protected := make([]bool, len(units))
+	protected[len(units)-1] = true
+	for j := len(units) - 1; j >= 0; j-- {
+		if units[j].messages[0].Role == "user" {
+			protected[j] = true
+			break
+		}
+	}
+
+	// 第一阶段：从最早的消息组开始，把较长的工具观察结果替换为简短摘要
+	for j := 0; j < len(units) && total > budget; j++ {
+		if protected[j] {
+			continue
+		}
+		elided := elideObservations(units[j], estimate)
+		total -= units[j].tokens - elided.tokens
+		units[j] = elided
+	}
+
+	// 第二阶段：从最早的消息组开始整组丢弃
+	dropped, droppedMessages := make([]bool, len(units)), 0
+	notice := omittedNotice(0)
+	for j := 0; j < len(units) && total > budget; j++ {
+		if protected[j] {
+			continue
+		}
+		if droppedMessages == 0 {
+			total += estimate(notice)
+		}
+		dropped[j] = true
+		droppedMessages += len(units[j].messages)
+		total -= units[j].tokens
+	}
+
+	trimmed := append([]llm.Message(nil), system...)
+	if droppedMessages > 0 {
+		trimmed = withOmittedNotice(trimmed, droppedMessages)
+	}
+	for j, u := range units {
+		if !dropped[j] {
+			trimmed = append(trimmed, u.messages...)
+		}
+	}
+	return trimmed
+}
+
+// splitUnits 将系统消息之后的消息拆分为不可分割的消息组。
+func splitUnits(messages []llm.Message, estimate TokenEstimator) []unit {
+	var units []unit
+	for _, m := range messages {
+		// 工具观察结果归属于它前面那条发起调用的助手消息
+		if m.Role == "tool" && len(units) > 0 {
+			last := &units[len(units)-1]
+			last.messages = append(last.messages, m)
+			last.tokens += estimate(m)
+			continue
+		}
+		units = append(units, unit{messages: []llm.Message{m}, tokens: estimate(m)})
+	}
+	return units
+}
+
+// elideObservations 返回一份将较长工具观察结果截短后的消息组副本。
+func elideObservations(u unit, estimate TokenEstimator) unit {
+	elided := unit{messages: make([]llm.Message, len(u.messages))}
+	for i, m := range u.messages {
+		if m.Role == "tool" && m.Content != nil {
+			if runes := []rune(*m.Content); len(runes) > elidedObservationKeep {
+				content := fmt.Sprintf("%s\n... [observation elided to save context: %d characters omitted]",
+					string(runes[:elidedObservationKeep]), len(runes)-elidedObservationKeep)
+				m.Content = &content
+			}
+		}
+		elided.messages[i] = m
+		elided.tokens += estimate(m)
+	}
+	return elided
+}
+
+// omittedNotice 生成一条说明，告知模型有多少条较早的消息因上下文限制被省略。
+func omittedNotice(count int) llm.Message {
+	content := fmt.Sprintf("[%d earlier messages were omitted to fit the model's context window.]", count)
+	return llm.Message{Role: "system", Content: &content}
+}
+
+// withOmittedNotice 把省略说明追加到开头的最后一条系统消息中（不修改原消息），没有系统消息时把说明作为第一条消息。
+// 许多兼容 OpenAI 的服务不接受出现在对话中间的系统消息，因此说明不能单独插入到被保留的消息之前。
+func withOmittedNotice(system []llm.Message, count int) []llm.Message {
+	notice := omittedNotice(count)
+	last := len(system) - 1
+	if last < 0 || system[last].Content == nil {
+		return append(system, notice)
+	}
+	content := *system[last].Content + "\n\n" + *notice.Content
+	system[last].Content = &content
+	return system
+}
@@ -0,0 +1,172 @@
+package history
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+func message(role, content string) llm.Message {
+	return llm.Message{Role: role, Content: &content}
+}
+
+// callMessage 创建一条发起工具调用的助手消息。
+func callMessage(ids ...string) llm.Message {
+	m := message("assistant", "")
+	for _, id := range ids {
+		m.ToolCalls = append(m.ToolCalls, llm.ToolCall{ID: id, Type: "function", Function: llm.FunctionCall{Name: "ps"}})
+	}
+	return m
+}
+
+func observation(id, content string) llm.Message {
+	m := message("tool", content)
+	m.ToolCallID = id
+	return m
+}
+
+// testEstimate 让每条消息占 10 个 token，内容每 10 个字节再多占 1 个 token，便于计算预算。
+func testEstimate(m llm.Message) int {
+	tokens := 10
+	if m.Content != nil {
+		tokens += len(*m.Content) / 10
+	}
+	return tokens
+}
+
+// summarize 把消息概括为 "<角色>:<内容>"，发起工具调用的消息概括为 "assistant->id1,id2"。
+func summarize(messages []llm.Message) []string {
+	summary := make([]string, len(messages))
+	for i, m := range messages {
+		switch {
+		case len(m.ToolCalls) > 0:
+			ids := make([]string, len(m.ToolCalls))
+			for j, call := range m.ToolCalls {
+				ids[j] = call.ID
+			}
+			summary[i] = "assistant->" + strings.Join(ids, ",")
+		case m.Role == "tool":
+			summary[i] = "tool:" + m.ToolCallID
+		default:
+			summary[i] = m.Role + ":" + *m.Content
+		}
+	}
+	return summary
+}
+
+func notice(count int) string {
+	return fmt.Sprintf("\n\n[%d earlier messages were omitted to fit the model's context window.]", count)
+}
+
+func TestTrimToBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []llm.Message
+		budget   int
+		want     []string
+	}{
+		{
+			name:     "within budget",
+			messages: []llm.Message{message("system", "S"), message("user", "u1"), message("assistant", "a1")},
+			budget:   30,
+			want:     []string{"system:S", "user:u1", "assistant:a1"},
+		},
+		{
+			name: "oldest units dropped first",
+			messages: []llm.Message{message("system", "S"), message("user", "u1"), message("assistant", "a1"),
+				message("user", "u2"), message("assistant", "a2"), message("user", "u3")},
+			budget: 50,
+			want:   []string{"system:S" + notice(3), "assistant:a2", "user:u3"},
+		},
+		{
+			name: "tool calls dropped together with their observations",
+			messages: []llm.Message{message("system", "S"), message("user", "u1"), callMessage("c1", "c2"),
+				observation("c1", "o1"), observation("c2", "o2"), message("assistant", "a1"), message("user", "u2")},
+			budget: 50,
+			want:   []string{"system:S" + notice(4), "assistant:a1", "user:u2"},
+		},
+		{
+			name: "latest unit and last user message are kept over budget",
+			messages: []llm.Message{message("system", "S"), message("user", "u1"), message("assistant", "a1"),
+				message("user", "u2"), callMessage("c1"), observation("c1", "o1")},
+			budget: 1,
+			want:   []string{"system:S" + notice(2), "user:u2", "assistant->c1", "tool:c1"},
+		},
+		{
+			name: "notice goes into the last leading system message",
+			messages: []llm.Message{message("system", "S1"), message("system", "S2"), message("user", "u1"),
+				message("assistant", "a1"), message("user", "u2")},
+			budget: 40,
+			want:   []string{"system:S1", "system:S2" + notice(2), "user:u2"},
+		},
+		{
+			name:     "no system message",
+			messages: []llm.Message{message("user", "u1"), message("assistant", "a1"), message("user", "u2")},
+			budget:   15,
+			want:     []string{"system:" + strings.TrimPrefix(notice(2), "\n\n"), "user:u2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := summarize(tt.messages)
+			trimmed := trimToBudget(tt.messages, tt.budget, testEstimate)
+			if got := summarize(trimmed); !slices.Equal(got, tt.want) {
+				t.Errorf("trimToBudget() = %q, want %q", got, tt.want)
+			}
+			checkToolPairs(t, trimmed)
+			if got := summarize(tt.messages); !slices.Equal(got, original) {
+				t.Errorf("trimToBudget() modified the messages: %q", got)
+			}
+		})
+	}
+}
+
+func TestTrimToBudgetElidesObservationsBeforeDropping(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	messages := []llm.Message{message("system", "S"), message("user", "u1"), callMessage("c1"),
+		observation("c1", long), message("assistant", "a1"), message("user", "u2")}
+
+	trimmed := trimToBudget(messages, 100, testEstimate)
+	if got, want := summarize(trimmed), summarize(messages); !slices.Equal(got, want) {
+		t.Fatalf("trimToBudget() = %q, want every message kept", got)
+	}
+	content := *trimmed[3].Content
+	if !strings.HasPrefix(content, strings.Repeat("x", elidedObservationKeep)) || !strings.Contains(content, "800 characters omitted") {
+		t.Errorf("observation = %q, want it elided", content)
+	}
+	if *messages[3].Content != long {
+		t.Error("trimToBudget() modified the original observation")
+	}
+}
+
+// checkToolPairs 检查每条工具消息都紧跟在发起该调用的助手消息（及其其他观察结果）之后，
+// 且每个工具调用都有对应的观察结果。
+func checkToolPairs(t *testing.T, messages []llm.Message) {
+	t.Helper()
+	var open []string // 最近一条发起调用的助手消息中还没有观察结果的调用
+	for i, m := range messages {
+		switch {
+		case m.Role == "tool":
+			j := slices.Index(open, m.ToolCallID)
+			if j < 0 {
+				t.Errorf("message %d: observation of %s without its tool call", i, m.ToolCallID)
+				continue
+			}
+			open = slices.Delete(open, j, j+1)
+		default:
+			if len(open) > 0 {
+				t.Errorf("message %d: tool calls %v have no observations", i, open)
+			}
+			open = nil
+			for _, call := range m.ToolCalls {
+				open = append(open, call.ID)
+			}
+		}
+	}
+	if len(open) > 0 {
+		t.Errorf("tool calls %v have no observations", open)
+	}
+}
@@ -0,0 +1,233 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/usage"
+)
+
+// ErrNothingToCompact 表示历史记录中没有足够旧的消息可以被压缩。
+var ErrNothingToCompact = errors.New("nothing to compact")
+
+// summaryPrefix 标记由压缩生成的摘要消息。
+const summaryPrefix = "[Summary of earlier conversation]"
+
+// maxSummarizedMessageChars 是渲染给摘要模型的单条消息的最大字符数，避免摘要请求本身超出上下文。
+const maxSummarizedMessageChars = 4000
+
+// summarizePrompt 指导模型如何压缩对话。重点是保留后续排查仍然需要的具体事实。
+const summarizePrompt = `You are compacting the transcript of a command-line troubleshooting agent so it can keep working within its context window.
+Write a concise summary of the conversation below. You MUST preserve, verbatim where possible:
+- the user's goals and any open questions or pending tasks;
+- concrete facts discovered: file paths, process names and PIDs, ports, users, hosts, URLs, versions, error messages;
+- commands/tools that were run and their key results, including what did NOT work;
+- any conclusions already reached.
+Omit greetings, repeated output and raw listings that have already been interpreted. Use short bullet points.`
+
+// Summarizer 将一段对话压缩为一条摘要文本。
+type Summarizer interface {
+	Summarize(ctx context.Context, messages []llm.Message) (string, error)
+}
+
+// LLMSummarizer 使用 LLM 生成对话摘要。
+type LLMSummarizer struct {
+	LLM   llm.LLM
+	Model string
+	// Usage 不为 nil 时，摘要请求消耗的 token 会被记录到其中。
+	Usage *usage.Tracker
+}
+
+// Summarize 实现了 Summarizer 接口。
+func (s *LLMSummarizer) Summarize(ctx context.Context, messages []llm.Message) (string, error) {
+	prompt := summarizePrompt
+	transcript := renderTranscript(messages)
+	request := llm.ChatRequest{
+		Model: s.Model,
+		Messages: []llm.Message{
+			{Role: "system", Content: &prompt},
+			{Role: "user", Content: &transcript},
+		},
+	}
+
+	response, err := s.LLM.ChatCompletion(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("error summarizing conversation: %w", err)
+	}
+	if s.Usage != nil {
+		s.Usage.Record(s.Model, response.Usage)
+	}
+	if len(response.Choices) == 0 || response.Choices[0].Message.Content == nil {
+		return "", errors.New("error summarizing conversation: empty response from LLM")
+	}
+	return strings.TrimSpace(*response.Choices[0].Message.Content), nil
+}
+
+// renderTranscript 将消息渲染为纯文本对话记录，供摘要模型阅读。
+func renderTranscript(messages []llm.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		var content string
+		if m.Content != nil {
+			content = *m.Content
+		}
+		if runes := []rune(content); len(runes) > maxSummarizedMessageChars {
+			content = string(runes[:maxSummarizedMessageChars]) + "\n... [truncated]"
+		}
+
+		switch m.Role {
+		case "tool":
+			fmt.Fprintf(&b, "[tool result %s]\n%s\n\n", m.ToolCallID, content)
+		default:
+			fmt.Fprintf(&b, "[%s]\n", m.Role)
+			if content != "" {
+				fmt.Fprintf(&b, "%s\n", content)
+			}
+			for _, call := range m.ToolCalls {
+				fmt.Fprintf(&b, "-> call %s %s(%s)\n", call.ID, call.Function.Name, call.Function.Arguments)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Compaction 是一次压缩的审计记录：哪些消息被替换成了什么摘要。
+type Compaction struct {
+	Time         time.Time     `json:"time"`
+	Start        int           `json:"start"`          // 被替换的消息在历史记录中的起始位置
+	Message      llm.Message   `json:"message"`        // 插入到 Start 位置、替代原消息的摘要消息
+	Summary      string        `json:"summary"`        // 生成的摘要
+	Replaced     []llm.Message `json:"replaced"`       // 被摘要替换掉的原始消息
+	Kept         []llm.Message `json:"kept,omitempty"` // 区间内原样保留、紧跟在摘要之后的消息（当前任务的用户消息）
+	TokensBefore int           `json:"tokens_before"`  // 压缩前历史记录的估算 token 数
+	TokensAfter  int           `json:"tokens_after"`   // 压缩后历史记录的估算 token 数
+	Automatic    bool          `json:"automatic"`      // 是否由阈值自动触发
+}
+
+// AutoCompactConfig 配置自动压缩。
+type AutoCompactConfig struct {
+	Summarizer Summarizer
+	Threshold  int // 完整历史记录的估算 token 数超过该值时触发压缩
+	KeepRecent int // 压缩时保留的最近消息组数量
+}
+
+// SetAutoCompact 启用自动压缩。传入 nil 的 Summarizer 或非正的 Threshold 会禁用它。
+func (h *HistoryManager) SetAutoCompact(cfg AutoCompactConfig) {
+	if cfg.Summarizer == nil || cfg.Threshold <= 0 {
+		h.autoCompact = nil
+		return
+	}
+	h.autoCompact = &cfg
+}
+
+// CompactIfNeeded 在启用了自动压缩且历史记录超过阈值时执行压缩。
+// 未达到阈值或没有可压缩的消息时返回 nil, nil。
+func (h *HistoryManager) CompactIfNeeded(ctx context.Context) (*Compaction, error) {
+	if h.autoCompact == nil || h.EstimateTokens() <= h.autoCompact.Threshold {
+		return nil, nil
+	}
+	compaction, err := h.compact(ctx, h.autoCompact.Summarizer, h.autoCompact.KeepRecent, true)
+	if errors.Is(err, ErrNothingToCompact) {
+		return nil, nil
+	}
+	return compaction, err
+}
+
+// Compact 使用 summarizer 把较早的对话替换为一条摘要消息，只保留系统提示词和最近 keepRecent 个消息组。
+// 当前任务本身很长时，压缩区间可以越过最近一条用户消息，在其后的助手消息/工具观察结果组的边界处截断，
+// 此时这条用户消息会被原样保留在摘要之后。
+// 每次压缩都会记录在 Compactions 中，以便审计被替换掉的内容。
+func (h *HistoryManager) Compact(ctx context.Context, summarizer Summarizer, keepRecent int) (*Compaction, error) {
+	return h.compact(ctx, summarizer, keepRecent, false)
+}
+
+func (h *HistoryManager) compact(ctx context.Context, summarizer Summarizer, keepRecent int, automatic bool) (*Compaction, error) {
+	// 开头的系统消息（系统提示词）始终保留
+	start := 0
+	for start < len(h.Messages) && h.Messages[start].Role == "system" && !isSummary(h.Messages[start]) {
+		start++
+	}
+
+	// 计算压缩区间的终点：保留最近 keepRecent 个消息组，区间只在消息组的边界处截断，
+	// 不会把助手的 tool_calls 与对应的工具观察结果拆开。
+	units := splitUnits(h.Messages[start:], h.estimator)
+	end := max(len(units)-max(keepRecent, 0), 0)
+	// 区间越过最近一条用户消息时，这条消息原样保留，正在进行的任务不会失去原始的用户指令
+	var kept []llm.Message
+	for j := len(units) - 1; j >= 0; j-- {
+		if units[j].messages[0].Role == "user" {
+			if j < end {
+				kept = units[j].messages
+			}
+			break
+		}
+	}
+	count := 0
+	for j := 0; j < end; j++ {
+		count += len(units[j].messages)
+	}
+	// 只有一条（通常是上一次的摘要）时压缩没有意义
+	if count-len(kept) < 2 {
+		return nil, ErrNothingToCompact
+	}
+
+	replaced := append([]llm.Message(nil), h.Messages[start:start+count]...)
+	summary, err := summarizer.Summarize(ctx, replaced)
+	if err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("%s\nThe following summarizes %d earlier messages that were compacted to save context:\n%s",
+		summaryPrefix, len(replaced), summary)
+	if len(kept) > 0 {
+		content += "\n\nThe summary includes progress already made on the current request, which is repeated below."
+	}
+	compaction := &Compaction{
+		Time:         time.Now(),
+		Start:        start,
+		Message:      llm.Message{Role: "system", Content: &content},
+		Summary:      summary,
+		Replaced:     replaced,
+		Kept:         append([]llm.Message(nil), kept...),
+		TokensBefore: h.EstimateTokens(),
+		Automatic:    automatic,
+	}
+	h.applyCompaction(compaction)
+	compaction.TokensAfter = h.EstimateTokens()
+
+	h.Compactions = append(h.Compactions, *compaction)
+	if h.recorder != nil {
+		if err := h.recorder.RecordCompaction(*compaction); err != nil {
+			h.recordErr = err
+		}
+	}
+	return compaction, nil
+}
+
+// ReplayCompaction 将一条已记录的压缩重新应用到历史记录上，用于从持久化的会话中恢复。
+// 它不会通知 Recorder。
+func (h *HistoryManager) ReplayCompaction(compaction Compaction) {
+	h.applyCompaction(&compaction)
+	h.Compactions = append(h.Compactions, compaction)
+}
+
+// applyCompaction 用摘要消息以及 Kept 中的消息替换 [Start, Start+len(Replaced)) 区间内的消息。
+func (h *HistoryManager) applyCompaction(compaction *Compaction) {
+	end := min(compaction.Start+len(compaction.Replaced), len(h.Messages))
+	start := min(compaction.Start, end)
+	messages := append([]llm.Message(nil), h.Messages[:start]...)
+	messages = append(messages, compaction.Message)
+	messages = append(messages, compaction.Kept...)
+	messages = append(messages, h.Messages[end:]...)
+	h.Messages = messages
+}
+
+// isSummary 判断一条消息是否是由压缩生成的摘要。
+func isSummary(message llm.Message) bool {
+	return message.Role == "system" && message.Content != nil && strings.HasPrefix(*message.Content, summaryPrefix)
+}
@@ -0,0 +1,201 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// countingSummarizer 返回 "summary <序号>"，并记录每次收到的消息。
+type countingSummarizer struct {
+	calls [][]llm.Message
+}
+
+func (s *countingSummarizer) Summarize(ctx context.Context, messages []llm.Message) (string, error) {
+	s.calls = append(s.calls, messages)
+	return fmt.Sprintf("summary %d", len(s.calls)), nil
+}
+
+// eventRecorder 按顺序记录消息和压缩，并像会话文件一样经过 JSON 编码。
+type eventRecorder struct {
+	events []json.RawMessage
+}
+
+type recordedEvent struct {
+	Message    *llm.Message `json:"message,omitempty"`
+	Compaction *Compaction  `json:"compaction,omitempty"`
+}
+
+func (r *eventRecorder) record(event recordedEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	r.events = append(r.events, data)
+	return nil
+}
+
+func (r *eventRecorder) RecordMessage(message llm.Message) error {
+	return r.record(recordedEvent{Message: &message})
+}
+
+func (r *eventRecorder) RecordCompaction(compaction Compaction) error {
+	return r.record(recordedEvent{Compaction: &compaction})
+}
+
+// replay 用记录的事件重建历史记录，与从会话文件恢复的方式相同。
+func (r *eventRecorder) replay(t *testing.T) *HistoryManager {
+	t.Helper()
+	h := NewHistoryManager()
+	for _, data := range r.events {
+		var event recordedEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Message != nil {
+			h.Messages = append(h.Messages, *event.Message)
+		} else {
+			h.ReplayCompaction(*event.Compaction)
+		}
+	}
+	return h
+}
+
+// add 按角色向历史记录中添加消息，发起工具调用的助手消息用 callMessage 创建。
+func add(h *HistoryManager, messages ...llm.Message) {
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			h.AddSystemMessage(*m.Content)
+		case "user":
+			h.AddUserMessage(*m.Content)
+		case "tool":
+			h.AddToolObservation(m.ToolCallID, *m.Content)
+		default:
+			h.AddAssistantMessage(m)
+		}
+	}
+}
+
+// summarizeHistory 与 summarize 相同，但把摘要消息概括为 "summary:<摘要>"。
+func summarizeHistory(messages []llm.Message) []string {
+	summary := summarize(messages)
+	for i, m := range messages {
+		if isSummary(m) {
+			_, text, _ := strings.Cut(*m.Content, "compacted to save context:\n")
+			text, _, _ = strings.Cut(text, "\n")
+			summary[i] = "summary:" + text
+		}
+	}
+	return summary
+}
+
+func TestCompactAndReplay(t *testing.T) {
+	recorder := &eventRecorder{}
+	h := NewHistoryManager()
+	h.SetRecorder(recorder)
+	summarizer := &countingSummarizer{}
+
+	add(h, message("system", "S"), message("user", "u1"), message("assistant", "a1"),
+		message("user", "u2"), callMessage("c1"), observation("c1", "o1"), message("assistant", "a2"))
+
+	// 第一次压缩：区间越过最近一条用户消息 u2，u2 原样保留在摘要之后
+	compaction, err := h.Compact(context.Background(), summarizer, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compaction.Start != 1 || len(compaction.Replaced) != 3 || !slices.Equal(summarize(compaction.Kept), []string{"user:u2"}) {
+		t.Errorf("compaction = start %d, %d replaced, kept %q; want start 1, 3 replaced, kept u2",
+			compaction.Start, len(compaction.Replaced), summarize(compaction.Kept))
+	}
+	if !strings.Contains(*compaction.Message.Content, "repeated below") {
+		t.Errorf("summary message = %q, want the note about the repeated request", *compaction.Message.Content)
+	}
+	want := []string{"system:S", "summary:summary 1", "user:u2", "assistant->c1", "tool:c1", "assistant:a2"}
+	if got := summarizeHistory(h.Messages); !slices.Equal(got, want) {
+		t.Fatalf("after the first compaction = %q, want %q", got, want)
+	}
+
+	// 第二次压缩：上一次的摘要也被压缩进新的摘要
+	add(h, message("user", "u3"), message("assistant", "a3"), message("user", "u4"), message("assistant", "a4"))
+	compaction, err = h.Compact(context.Background(), summarizer, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compaction.Kept) != 0 || strings.Contains(*compaction.Message.Content, "repeated below") {
+		t.Errorf("second compaction kept %q, want nothing kept", summarize(compaction.Kept))
+	}
+	if got := summarizeHistory(summarizer.calls[1]); got[0] != "summary:summary 1" || got[len(got)-1] != "assistant:a3" {
+		t.Errorf("second summarizer input = %q, want it to start with the first summary and end before u4", got)
+	}
+	want = []string{"system:S", "summary:summary 2", "user:u4", "assistant:a4"}
+	if got := summarizeHistory(h.Messages); !slices.Equal(got, want) {
+		t.Fatalf("after the second compaction = %q, want %q", got, want)
+	}
+
+	replayed := recorder.replay(t)
+	if !reflect.DeepEqual(summarize(replayed.Messages), summarize(h.Messages)) ||
+		*replayed.Messages[1].Content != *h.Messages[1].Content {
+		t.Errorf("replayed history = %q, want %q", summarizeHistory(replayed.Messages), summarizeHistory(h.Messages))
+	}
+	if len(replayed.Compactions) != 2 {
+		t.Errorf("replayed %d compactions, want 2", len(replayed.Compactions))
+	}
+}
+
+func TestCompactCurrentTask(t *testing.T) {
+	// 整段历史都属于当前任务：压缩助手消息和观察结果，保留用户的原始指令
+	h := NewHistoryManager()
+	add(h, message("system", "S"), message("user", "u1"), callMessage("c1"), observation("c1", "o1"),
+		callMessage("c2"), observation("c2", "o2"))
+
+	compaction, err := h.Compact(context.Background(), &countingSummarizer{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system:S", "summary:summary 1", "user:u1", "assistant->c2", "tool:c2"}
+	if got := summarizeHistory(h.Messages); !slices.Equal(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+
+	replayed := NewHistoryManager()
+	add(replayed, message("system", "S"), message("user", "u1"), callMessage("c1"), observation("c1", "o1"),
+		callMessage("c2"), observation("c2", "o2"))
+	replayed.ReplayCompaction(*compaction)
+	if got := summarizeHistory(replayed.Messages); !slices.Equal(got, want) {
+		t.Errorf("replayed history = %q, want %q", got, want)
+	}
+}
+
+func TestCompactNothingToCompact(t *testing.T) {
+	tests := []struct {
+		name       string
+		messages   []llm.Message
+		keepRecent int
+	}{
+		{name: "empty", keepRecent: 0},
+		{name: "only the request", messages: []llm.Message{message("system", "S"), message("user", "u1")}, keepRecent: 0},
+		{name: "everything recent", messages: []llm.Message{message("user", "u1"), message("assistant", "a1")}, keepRecent: 2},
+		{name: "only a summary", messages: []llm.Message{message("system", summaryPrefix+" old"), message("user", "u1"), message("assistant", "a1")}, keepRecent: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistoryManager()
+			add(h, tt.messages...)
+			summarizer := &countingSummarizer{}
+			if _, err := h.Compact(context.Background(), summarizer, tt.keepRecent); !errors.Is(err, ErrNothingToCompact) {
+				t.Errorf("Compact() = %v, want ErrNothingToCompact", err)
+			}
+			if len(summarizer.calls) != 0 {
+				t.Error("the summarizer was called")
+			}
+		})
+	}
+}
@@ -2,21 +2,69 @@ package history
 
 import "github.com/DoraZa/mini-agent/internal/llm"
 
+// Recorder 接收历史记录的每一次变化，例如将其持久化到磁盘。
+type Recorder interface {
+	RecordMessage(message llm.Message) error
+	RecordCompaction(compaction Compaction) error
+}
+
 // HistoryManager 管理对话历史记录
 type HistoryManager struct {
 	Messages []llm.Message
+	// Compactions 按时间顺序记录了每一次压缩，用于审计哪些消息被摘要替换。
+	Compactions []Compaction
+
+	tokenBudget int            // 发送给模型的历史记录的 token 上限，0 表示不限制
+	estimator   TokenEstimator // 估算消息大小的方法
+	autoCompact *AutoCompactConfig
+	recorder    Recorder
+	recordErr   error // 最近一次记录失败的错误
 }
 
 // NewHistoryManager 创建一个新的历史记录管理器
 func NewHistoryManager() *HistoryManager {
 	return &HistoryManager{
-		Messages: make([]llm.Message, 0),
+		Messages:  make([]llm.Message, 0),
+		estimator: EstimateMessageTokens,
+	}
+}
+
+// SetRecorder 设置接收后续历史记录变化的 Recorder。已有的消息不会被重新记录。
+func (h *HistoryManager) SetRecorder(recorder Recorder) {
+	h.recorder = recorder
+}
+
+// RecordErr 返回并清除最近一次记录失败的错误。
+// 记录失败不会影响内存中的历史记录，由调用方决定如何提示用户。
+func (h *HistoryManager) RecordErr() error {
+	err := h.recordErr
+	h.recordErr = nil
+	return err
+}
+
+// SetTokenBudget 设置 GetHistory 返回的历史记录的 token 上限，0 或负数表示不限制。
+// 通常取模型的上下文窗口减去为输出和工具定义预留的空间。
+func (h *HistoryManager) SetTokenBudget(budget int) {
+	h.tokenBudget = max(budget, 0)
+}
+
+// SetTokenEstimator 替换默认的启发式 token 估算方法，例如换成基于真实分词器的实现。
+func (h *HistoryManager) SetTokenEstimator(estimator TokenEstimator) {
+	h.estimator = estimator
+}
+
+// EstimateTokens 估算完整历史记录（未裁剪）占用的 token 数。
+func (h *HistoryManager) EstimateTokens() int {
+	total := 0
+	for _, m := range h.Messages {
+		total += h.estimator(m)
 	}
+	return total
 }
 
 // AddUserMessage 添加一条用户消息到历史记录
 func (h *HistoryManager) AddUserMessage(content string) {
-	h.Messages = append(h.Messages, llm.Message{
+	h.append(llm.Message{
 		Role:    "user",
 		Content: &content,
 	})
@@ -24,7 +72,7 @@ func (h *HistoryManager) AddUserMessage(content string) {
 
 // AddSystemMessage 添加一条系统消息到历史记录
 func (h *HistoryManager) AddSystemMessage(content string) {
-	h.Messages = append(h.Messages, llm.Message{
+	h.append(llm.Message{
 		Role:    "system",
 		Content: &content,
 	})
@@ -35,19 +83,33 @@ func (h *HistoryManager) AddSystemMessage(content string) {
 func (h *HistoryManager) AddAssistantMessage(message llm.Message) {
 	// 确保角色是 assistant，以防万一
 	message.Role = "assistant"
-	h.Messages = append(h.Messages, message)
+	h.append(message)
 }
 
 // AddToolObservation 添加一条工具执行结果（观察）到历史记录
 func (h *HistoryManager) AddToolObservation(toolCallID, content string) {
-	h.Messages = append(h.Messages, llm.Message{
+	h.append(llm.Message{
 		Role:       "tool",
 		ToolCallID: toolCallID,
 		Content:    &content,
 	})
 }
 
-// GetHistory 返回当前所有的消息历史
+// GetHistory 返回要发送给模型的消息历史。
+// 设置了 token 上限时，返回的是裁剪后的视图（参见 trimToBudget），完整的历史仍保存在 Messages 中。
 func (h *HistoryManager) GetHistory() []llm.Message {
-	return h.Messages
+	if h.tokenBudget == 0 {
+		return h.Messages
+	}
+	return trimToBudget(h.Messages, h.tokenBudget, h.estimator)
+}
+
+// append 添加一条消息并通知 Recorder。
+func (h *HistoryManager) append(message llm.Message) {
+	h.Messages = append(h.Messages, message)
+	if h.recorder != nil {
+		if err := h.recorder.RecordMessage(message); err != nil {
+			h.recordErr = err
+		}
+	}
 }
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrorKind 是对 LLM 调用错误的分类，用于决定是否值得重试以及如何提示用户。
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown 表示无法识别的错误，不会被重试。
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindTransient 表示网络抖动或服务端 5xx 等临时错误，可以重试。
+	ErrorKindTransient
+	// ErrorKindRateLimit 表示触发了服务端的限流（HTTP 429），可以在等待后重试。
+	ErrorKindRateLimit
+	// ErrorKindQuota 表示账户额度已耗尽，重试没有意义。
+	ErrorKindQuota
+	// ErrorKindAuth 表示认证或授权失败，例如 API Key 无效。
+	ErrorKindAuth
+	// ErrorKindContextLength 表示请求超出了模型的上下文长度限制。
+	ErrorKindContextLength
+	// ErrorKindInvalidRequest 表示其他由请求本身导致的 4xx 错误。
+	ErrorKindInvalidRequest
+	// ErrorKindCanceled 表示调用方取消了请求或请求超时。
+	ErrorKindCanceled
+)
+
+// String 返回错误分类的可读名称。
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindTransient:
+		return "transient"
+	case ErrorKindRateLimit:
+		return "rate_limit"
+	case ErrorKindQuota:
+		return "quota"
+	case ErrorKindAuth:
+		return "auth"
+	case ErrorKindContextLength:
+		return "context_length"
+	case ErrorKindInvalidRequest:
+		return "invalid_request"
+	case ErrorKindCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
+// Retryable 报告该类错误是否值得重试。
+func (k ErrorKind) Retryable() bool {
+	return k == ErrorKindTransient || k == ErrorKindRateLimit
+}
+
+// ClassifyError 对 LLM 客户端返回的错误进行分类。
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindCanceled
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return classifyAPIError(apiErr.HTTPStatusCode, apiErr.Type, apiErr.Code, apiErr.Message)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return classifyStatus(reqErr.HTTPStatusCode, reqErr.Body)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorKindTransient
+	}
+	return ErrorKindUnknown
+}
+
+// IsRetryable 报告一个错误是否值得重试。
+func IsRetryable(err error) bool {
+	return ClassifyError(err).Retryable()
+}
+
+// classifyStatus 根据 HTTP 状态码和响应体对错误进行分类。
+func classifyStatus(status int, body []byte) ErrorKind {
+	var errResp openai.ErrorResponse
+	if len(body) > 0 && json.Unmarshal(body, &errResp) == nil && errResp.Error != nil {
+		return classifyAPIError(status, errResp.Error.Type, errResp.Error.Code, errResp.Error.Message)
+	}
+	return classifyAPIError(status, "", nil, string(body))
+}
+
+// classifyAPIError 结合状态码与错误体中的 type/code/message 字段进行分类。
+// 不同的 OpenAI 兼容服务返回的字段不尽相同，因此同时检查多个字段。
+func classifyAPIError(status int, errType string, code any, message string) ErrorKind {
+	codeStr, _ := code.(string)
+	lowerMessage := strings.ToLower(message)
+
+	switch {
+	case errType == "insufficient_quota" || codeStr == "insufficient_quota":
+		return ErrorKindQuota
+	case codeStr == "context_length_exceeded" ||
+		strings.Contains(lowerMessage, "maximum context length") ||
+		strings.Contains(lowerMessage, "context length"):
+		return ErrorKindContextLength
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorKindAuth
+	case status == http.StatusTooManyRequests:
+		return ErrorKindRateLimit
+	case status == http.StatusRequestTimeout || status == http.StatusConflict || status >= 500:
+		return ErrorKindTransient
+	case status >= 400:
+		return ErrorKindInvalidRequest
+	default:
+		return ErrorKindUnknown
+	}
+}
@@ -9,4 +9,16 @@ type LLM interface {
 	// 它接收一个上下文和一个包含消息历史与可用工具的请求，
 	// 然后返回 LLM 的响应。
 	ChatCompletion(ctx context.Context, request ChatRequest) (*ChatResponse, error)
+
+	// ChatCompletionStream 以流式方式与 LLM 对话。
+	// 返回的 ChatStream 会逐个下发增量片段，调用方负责在使用完毕后关闭它。
+	ChatCompletionStream(ctx context.Context, request ChatRequest) (ChatStream, error)
+}
+
+// ChatStream 是一个流式响应的迭代器。
+type ChatStream interface {
+	// Recv 返回下一个增量片段。流正常结束时返回 io.EOF。
+	Recv() (StreamDelta, error)
+	// Close 释放流占用的连接等资源。
+	Close() error
 }
@@ -2,6 +2,9 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"sync"
 
 	"github.com/sashabaranov/go-openai"
 )
@@ -10,12 +13,64 @@ import (
 // 但在底层使用 go-openai SDK 来与兼容 OpenAI 的 API（如 DeepSeek）通信。
 type OpenAIClient struct {
 	client *openai.Client
+
+	// noStreamUsage 记录拒绝 stream_options 参数的模型（模型名称 -> true），之后对这些模型不再发送该参数
+	noStreamUsage sync.Map
+}
+
+// ClientOption 用于定制 OpenAIClient 的行为。
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	httpClient        openai.HTTPDoer
+	retryPolicy       RetryPolicy
+	requestsPerMinute int
+}
+
+// WithRetryPolicy 设置遇到临时错误（429、5xx、网络错误）时的重试策略。
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithRateLimit 限制客户端每分钟最多发出的请求数，0 表示不限制。
+func WithRateLimit(requestsPerMinute int) ClientOption {
+	return func(o *clientOptions) {
+		o.requestsPerMinute = requestsPerMinute
+	}
+}
+
+// WithHTTPClient 替换底层用于发送请求的 HTTP 客户端。
+func WithHTTPClient(httpClient openai.HTTPDoer) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = httpClient
+	}
 }
 
 // NewOpenAIClient 创建一个新的 OpenAI 适配器客户端。
-func NewOpenAIClient(apiKey, baseURL string) *OpenAIClient {
+// 默认启用 DefaultRetryPolicy 描述的重试策略，不限制请求速率。
+func NewOpenAIClient(apiKey, baseURL string, opts ...ClientOption) *OpenAIClient {
+	options := clientOptions{
+		httpClient:  &http.Client{},
+		retryPolicy: DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	doer := &retryingDoer{
+		doer:   options.httpClient,
+		policy: options.retryPolicy,
+		sleep:  sleepContext,
+	}
+	if options.requestsPerMinute > 0 {
+		doer.limiter = newRateLimiter(options.requestsPerMinute)
+	}
+
 	config := openai.DefaultConfig(apiKey)
 	config.BaseURL = baseURL // 使用传入的 BaseURL
+	config.HTTPClient = doer
 	return &OpenAIClient{
 		client: openai.NewClientWithConfig(config),
 	}
@@ -37,6 +92,120 @@ func (c *OpenAIClient) ChatCompletion(ctx context.Context, request ChatRequest)
 	return &response, nil
 }
 
+// ChatCompletionStream 实现了 LLM 接口的流式部分，底层使用 SDK 的 CreateChatCompletionStream。
+func (c *OpenAIClient) ChatCompletionStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	sdkRequest := toOpenAIRequest(request)
+	sdkRequest.Stream = true
+	_, noUsage := c.noStreamUsage.Load(request.Model)
+	if !noUsage {
+		// 请求服务端在最后一个片段中附带整个请求的 token 用量
+		sdkRequest.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
+	sdkStream, err := c.client.CreateChatCompletionStream(ctx, sdkRequest)
+	if err != nil && !noUsage && isBadRequest(err) {
+		// 部分兼容 OpenAI 的服务不支持 stream_options，返回 400：去掉该参数重试一次，并记住这个模型。
+		// 此时流中不会包含用量，费用统计会缺少这部分请求。
+		sdkRequest.StreamOptions = nil
+		sdkStream, err = c.client.CreateChatCompletionStream(ctx, sdkRequest)
+		if err == nil {
+			c.noStreamUsage.Store(request.Model, true)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &openAIStream{stream: sdkStream}, nil
+}
+
+// isBadRequest 判断错误是否是 HTTP 400。
+func isBadRequest(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusBadRequest
+	}
+	var reqErr *openai.RequestError
+	return errors.As(err, &reqErr) && reqErr.HTTPStatusCode == http.StatusBadRequest
+}
+
+// openAIStream 将 SDK 的流式响应适配为内部的 ChatStream 接口。
+type openAIStream struct {
+	stream *openai.ChatCompletionStream
+	calls  toolCallIndexer
+}
+
+// Recv 读取下一个 SDK 片段并将其转换为 StreamDelta。流结束时返回 io.EOF。
+func (s *openAIStream) Recv() (StreamDelta, error) {
+	for {
+		sdkResponse, err := s.stream.Recv()
+		if err != nil {
+			return StreamDelta{}, err
+		}
+		// 携带用量的最后一个片段不包含任何选项
+		if len(sdkResponse.Choices) == 0 {
+			if sdkResponse.Usage != nil {
+				usage := fromOpenAIUsage(*sdkResponse.Usage)
+				return StreamDelta{Usage: &usage}, nil
+			}
+			// 其他只携带元数据的片段，跳过即可
+			continue
+		}
+		delta := fromOpenAIStreamChoice(sdkResponse.Choices[0], &s.calls)
+		if sdkResponse.Usage != nil {
+			usage := fromOpenAIUsage(*sdkResponse.Usage)
+			delta.Usage = &usage
+		}
+		return delta, nil
+	}
+}
+
+// Close 关闭底层的 HTTP 连接。
+func (s *openAIStream) Close() error {
+	return s.stream.Close()
+}
+
+// fromOpenAIStreamChoice 将 SDK 的流式选项转换为内部的 StreamDelta。
+func fromOpenAIStreamChoice(choice openai.ChatCompletionStreamChoice, calls *toolCallIndexer) StreamDelta {
+	delta := StreamDelta{
+		Content:      choice.Delta.Content,
+		FinishReason: string(choice.FinishReason),
+	}
+	for _, sdkCall := range choice.Delta.ToolCalls {
+		delta.ToolCalls = append(delta.ToolCalls, ToolCallDelta{
+			Index:     calls.index(sdkCall.Index, sdkCall.ID),
+			ID:        sdkCall.ID,
+			Type:      string(sdkCall.Type),
+			Name:      sdkCall.Function.Name,
+			Arguments: sdkCall.Function.Arguments,
+		})
+	}
+	return delta
+}
+
+// toolCallIndexer 为流式响应中的工具调用片段确定 Index。
+// 部分兼容 OpenAI 的服务不返回 index，而每个片段通常只携带一个工具调用，
+// 因此不能使用片段内的位置：此时带有新 ID 的片段开始一个新的工具调用，没有 ID 的片段属于最近的工具调用。
+type toolCallIndexer struct {
+	count   int    // 目前为止出现过的工具调用数
+	current int    // 最近一个工具调用的 Index
+	lastID  string // 最近一个工具调用的 ID
+}
+
+func (c *toolCallIndexer) index(sdkIndex *int, id string) int {
+	switch {
+	case sdkIndex != nil:
+		c.current = *sdkIndex
+		c.count = max(c.count, *sdkIndex+1)
+	case c.count == 0 || (id != "" && id != c.lastID):
+		c.current = c.count
+		c.count++
+	}
+	if id != "" {
+		c.lastID = id
+	}
+	return c.current
+}
+
 // toOpenAIRequest 将我们的内部 ChatRequest 转换为 go-openai 的类型。
 func toOpenAIRequest(req ChatRequest) openai.ChatCompletionRequest {
 	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
@@ -113,7 +282,21 @@ func fromOpenAIResponse(resp openai.ChatCompletionResponse) ChatResponse {
 		ID:      resp.ID,
 		Model:   resp.Model,
 		Choices: choices,
+		Usage:   fromOpenAIUsage(resp.Usage),
+	}
+}
+
+// fromOpenAIUsage 将 go-openai 的用量统计转换为内部的 Usage。
+func fromOpenAIUsage(sdkUsage openai.Usage) Usage {
+	usage := Usage{
+		PromptTokens:     sdkUsage.PromptTokens,
+		CompletionTokens: sdkUsage.CompletionTokens,
+		TotalTokens:      sdkUsage.TotalTokens,
+	}
+	if sdkUsage.PromptTokensDetails != nil {
+		usage.CachedTokens = sdkUsage.PromptTokensDetails.CachedTokens
 	}
+	return usage
 }
 
 func fromOpenAIToolCalls(sdkCalls []openai.ToolCall) []ToolCall {
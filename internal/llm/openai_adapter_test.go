@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestStreamToolCallsWithoutIndex(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	tests := []struct {
+		name   string
+		chunks []openai.ToolCall
+		want   []ToolCall
+	}{
+		{
+			name: "indexes reported",
+			chunks: []openai.ToolCall{
+				{Index: intPtr(0), ID: "a", Function: openai.FunctionCall{Name: "ps", Arguments: `{"na`}},
+				{Index: intPtr(1), ID: "b", Function: openai.FunctionCall{Name: "ss", Arguments: `{}`}},
+				{Index: intPtr(0), Function: openai.FunctionCall{Arguments: `me":"x"}`}},
+			},
+			want: []ToolCall{
+				{ID: "a", Type: "function", Function: FunctionCall{Name: "ps", Arguments: `{"name":"x"}`}},
+				{ID: "b", Type: "function", Function: FunctionCall{Name: "ss", Arguments: `{}`}},
+			},
+		},
+		{
+			name: "no index, a new id starts a new call",
+			chunks: []openai.ToolCall{
+				{ID: "a", Function: openai.FunctionCall{Name: "ps", Arguments: `{"na`}},
+				{Function: openai.FunctionCall{Arguments: `me":"x"}`}},
+				{ID: "b", Function: openai.FunctionCall{Name: "find", Arguments: `{}`}},
+				{ID: "b", Function: openai.FunctionCall{Arguments: ``}},
+			},
+			want: []ToolCall{
+				{ID: "a", Type: "function", Function: FunctionCall{Name: "ps", Arguments: `{"name":"x"}`}},
+				{ID: "b", Type: "function", Function: FunctionCall{Name: "find", Arguments: `{}`}},
+			},
+		},
+		{
+			name: "no index and no id",
+			chunks: []openai.ToolCall{
+				{Function: openai.FunctionCall{Name: "ps", Arguments: `{`}},
+				{Function: openai.FunctionCall{Arguments: `}`}},
+			},
+			want: []ToolCall{
+				{Type: "function", Function: FunctionCall{Name: "ps", Arguments: `{}`}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls toolCallIndexer
+			acc := NewStreamAccumulator()
+			for _, chunk := range tt.chunks {
+				choice := openai.ChatCompletionStreamChoice{Delta: openai.ChatCompletionStreamChoiceDelta{ToolCalls: []openai.ToolCall{chunk}}}
+				acc.Add(fromOpenAIStreamChoice(choice, &calls))
+			}
+			got := acc.Message().ToolCalls
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d tool calls %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("tool call %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamRetriesWithoutStreamOptions(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		if strings.Contains(string(body), "stream_options") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"unknown field: stream_options","type":"invalid_request_error"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	client := NewOpenAIClient("key", srv.URL, WithRetryPolicy(RetryPolicy{}))
+	for range 2 {
+		stream, err := client.ChatCompletionStream(context.Background(), ChatRequest{Model: "m"})
+		if err != nil {
+			t.Fatalf("ChatCompletionStream: %v", err)
+		}
+		message, err := CollectStream(stream, nil)
+		stream.Close()
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		if message.Choices[0].Message.Content == nil || *message.Choices[0].Message.Content != "hi" {
+			t.Fatalf("unexpected message %+v", message)
+		}
+	}
+	// 第一次请求被拒绝后去掉 stream_options 重试；之后对同一模型不再发送该参数
+	if len(requests) != 3 || !strings.Contains(requests[0], "stream_options") ||
+		strings.Contains(requests[1], "stream_options") || strings.Contains(requests[2], "stream_options") {
+		t.Errorf("unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+}
@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RepairArguments 尝试修复模型生成的、不是合法 JSON 的工具调用参数。
+// 能力较弱的模型经常输出带有尾随逗号、单引号字符串、未加引号的键、Markdown 代码块、
+// Python 风格的 True/False/None，或者在最后一个值之后被截断、缺少右括号的参数。
+//
+// 修复只做不改变含义的改写：在字符串中间被截断、括号不匹配、或者包含多个 JSON 对象的参数
+// 不会被猜测，而是返回错误，由调用方要求模型重新发送。截断处的最后一个值是数字或未写完的字面量时，
+// 无法判断它是否完整（例如 100 被截断成 10），同样返回错误。
+// 参数本来就是合法 JSON（或为空）时原样返回，repaired 为 false。
+func RepairArguments(arguments string) (fixed string, repaired bool, err error) {
+	trimmed := strings.TrimSpace(arguments)
+	if trimmed == "" || json.Valid([]byte(trimmed)) {
+		return arguments, false, nil
+	}
+
+	text := stripCodeFence(trimmed)
+	if json.Valid([]byte(text)) {
+		return text, true, nil
+	}
+	// 跳过对象之前的说明文字，例如 "Arguments: {...}"
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return "", false, errors.New("arguments do not contain a JSON object")
+	}
+	fixed, err = rewriteJSON(text[start:])
+	if err != nil {
+		return "", false, err
+	}
+	if !json.Valid([]byte(fixed)) {
+		return "", false, errors.New("arguments are not valid JSON even after repair")
+	}
+	return fixed, true, nil
+}
+
+// stripCodeFence 去掉包裹参数的 Markdown 代码块，例如 ```json ... ```。
+func stripCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	// 去掉代码块的语言标记
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 && !strings.ContainsAny(text[:newline], "{[") {
+		text = text[newline+1:]
+	} else {
+		text = strings.TrimPrefix(text, "json")
+	}
+	text = strings.TrimSpace(text)
+	return strings.TrimSpace(strings.TrimSuffix(text, "```"))
+}
+
+// rewriteJSON 逐个字符改写一个 JSON 对象：将单引号字符串转换为双引号字符串，为未加引号的键加上引号，
+// 转换 Python 风格的字面量，删除尾随逗号，并在末尾补齐缺少的右括号。
+// 只有截断处的最后一个值是完整的字符串、对象、数组或字面量（或者其后已经跟着逗号）时才补齐右括号。
+// 对象结束后只允许出现多余的右花括号。
+func rewriteJSON(text string) (string, error) {
+	var b strings.Builder
+	var closers []byte // 尚未闭合的括号对应的右括号
+	complete := false  // 已读到的最后一个值是否确定是完整的
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == '"' || c == '\'':
+			s, n, err := readQuoted(text[i:])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+			i += n
+			complete = true
+		case c == '{' || c == '[':
+			if c == '{' {
+				closers = append(closers, '}')
+			} else {
+				closers = append(closers, ']')
+			}
+			b.WriteByte(c)
+			i++
+			complete = false
+		case c == '}' || c == ']':
+			if len(closers) == 0 || closers[len(closers)-1] != c {
+				return "", fmt.Errorf("unexpected '%c' at offset %d", c, i)
+			}
+			trimTrailingComma(&b)
+			b.WriteByte(c)
+			closers = closers[:len(closers)-1]
+			i++
+			complete = true
+			if len(closers) == 0 {
+				if rest := strings.TrimSpace(text[i:]); strings.Trim(rest, "}") != "" {
+					return "", fmt.Errorf("unexpected text after the arguments object: %q", truncateText(rest, 40))
+				}
+				return b.String(), nil
+			}
+		case isIdentByte(c):
+			j := i
+			for j < len(text) && (isIdentByte(text[j]) || text[j] >= '0' && text[j] <= '9') {
+				j++
+			}
+			word := text[i:j]
+			// 只有完整的字面量才是完整的值，键之后还会读到冒号
+			complete = false
+			switch {
+			case strings.HasPrefix(strings.TrimLeft(text[j:], " \t\r\n"), ":"):
+				// 未加引号的键
+				b.WriteString(quote(word))
+			case word == "True" || word == "true":
+				b.WriteString("true")
+				complete = true
+			case word == "False" || word == "false":
+				b.WriteString("false")
+				complete = true
+			case word == "None" || word == "null":
+				b.WriteString("null")
+				complete = true
+			default:
+				b.WriteString(word)
+			}
+			i = j
+		default:
+			// 逗号表示之前的值已经结束；数字等其他内容则可能只写了一部分
+			switch c {
+			case ',':
+				complete = true
+			case ' ', '\t', '\r', '\n':
+			default:
+				complete = false
+			}
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	// 参数在最后一个完整的值之后被截断：删除悬空的逗号，补齐右括号
+	trimTrailingComma(&b)
+	if strings.HasSuffix(strings.TrimSpace(b.String()), ":") {
+		return "", errors.New("arguments are truncated after a property name")
+	}
+	if !complete {
+		return "", errors.New("arguments are truncated and the last value may be incomplete")
+	}
+	for k := len(closers) - 1; k >= 0; k-- {
+		b.WriteByte(closers[k])
+	}
+	return b.String(), nil
+}
+
+// readQuoted 读取一个以单引号或双引号开头的字符串，返回等价的 JSON 字符串和消耗的字节数。
+// 转义序列原样保留，字符串中的双引号和换行等控制字符会被转义。
+func readQuoted(text string) (string, int, error) {
+	delim := text[0]
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == delim:
+			b.WriteByte('"')
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(text):
+			i++
+			if text[i] == '\'' {
+				// JSON 中没有 \' 转义
+				b.WriteByte('\'')
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(text[i])
+			}
+		case c == '"':
+			b.WriteString(`\"`)
+		case c < 0x20:
+			b.WriteString(strings.Trim(quote(string(c)), `"`))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("arguments are truncated inside a string")
+}
+
+// trimTrailingComma 删除已写入内容末尾的逗号（以及其后的空白）。
+func trimTrailingComma(b *strings.Builder) {
+	s := strings.TrimRight(b.String(), " \t\r\n")
+	if trimmed, ok := strings.CutSuffix(s, ","); ok {
+		b.Reset()
+		b.WriteString(trimmed)
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
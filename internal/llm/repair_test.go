@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRepairArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		want      string // 修复后的参数，按 JSON 语义比较
+		repaired  bool
+		wantErr   string
+	}{
+		{name: "valid", arguments: `{"path": "/tmp"}`, want: `{"path": "/tmp"}`},
+		{name: "empty", arguments: ``, want: ``},
+		{name: "whitespace only", arguments: "  \n", want: "  \n"},
+		{name: "trailing comma", arguments: `{"path": "/tmp", "depth": 2,}`, want: `{"path":"/tmp","depth":2}`, repaired: true},
+		{name: "trailing comma in array", arguments: `{"exclude": ["a", "b",]}`, want: `{"exclude":["a","b"]}`, repaired: true},
+		{name: "single quotes", arguments: `{'path': '/tmp'}`, want: `{"path":"/tmp"}`, repaired: true},
+		{name: "single quotes with double quote inside", arguments: `{'pattern': 'say "hi"'}`, want: `{"pattern":"say \"hi\""}`, repaired: true},
+		{name: "escaped single quote", arguments: `{'pattern': 'it\'s'}`, want: `{"pattern":"it's"}`, repaired: true},
+		{name: "unquoted keys", arguments: `{path: "/tmp", max_depth: 2}`, want: `{"path":"/tmp","max_depth":2}`, repaired: true},
+		{name: "python literals", arguments: `{"follow": True, "deleted": False, "user": None}`, want: `{"follow":true,"deleted":false,"user":null}`, repaired: true},
+		{name: "literal inside string untouched", arguments: `{'name': 'True None'}`, want: `{"name":"True None"}`, repaired: true},
+		{name: "code fence with language", arguments: "```json\n{\"path\": \"/tmp\"}\n```", want: `{"path":"/tmp"}`, repaired: true},
+		{name: "code fence without language", arguments: "```\n{'path': '/tmp'}\n```", want: `{"path":"/tmp"}`, repaired: true},
+		{name: "leading prose", arguments: `Arguments: {"path": "/tmp"}`, want: `{"path":"/tmp"}`, repaired: true},
+		{name: "missing closing braces", arguments: `{"path": "/tmp", "opts": {"a": [1, 2]`, want: `{"path":"/tmp","opts":{"a":[1,2]}}`, repaired: true},
+		{name: "truncated after comma", arguments: `{"path": "/tmp",`, want: `{"path":"/tmp"}`, repaired: true},
+		{name: "truncated after a number and comma", arguments: `{"opts": {"depth": 10, `, want: `{"opts":{"depth":10}}`, repaired: true},
+		{name: "truncated after a literal", arguments: `{"follow": True`, want: `{"follow":true}`, repaired: true},
+		{name: "truncated after a string in an array", arguments: `{"exclude": ["a", "b"`, want: `{"exclude":["a","b"]}`, repaired: true},
+		{name: "truncated number", arguments: `{"max_results": 10`, wantErr: "last value may be incomplete"},
+		{name: "truncated number in array", arguments: `{"a": [1, 2`, wantErr: "last value may be incomplete"},
+		{name: "truncated negative number", arguments: `{"offset": -`, wantErr: "last value may be incomplete"},
+		{name: "truncated literal", arguments: `{"follow": tr`, wantErr: "last value may be incomplete"},
+		{name: "truncated after an opening bracket", arguments: `{"path": "/tmp", "opts": {`, wantErr: "last value may be incomplete"},
+		{name: "truncated after an opening brace", arguments: `{`, wantErr: "last value may be incomplete"},
+		{name: "extra closing brace", arguments: `{"path": "/tmp"}}`, want: `{"path":"/tmp"}`, repaired: true},
+		{name: "newline in string", arguments: "{'text': 'a\nb'}", want: `{"text":"a\nb"}`, repaired: true},
+		{name: "truncated inside string", arguments: `{"path": "/tm`, wantErr: "truncated inside a string"},
+		{name: "truncated after property name", arguments: `{"path":`, wantErr: "truncated after a property name"},
+		{name: "mismatched bracket", arguments: `{"exclude": ["a"}`, wantErr: "unexpected '}'"},
+		{name: "two objects", arguments: `{"a": 1} {"b": 2}`, wantErr: "unexpected text after the arguments object"},
+		{name: "no object", arguments: `path=/tmp`, wantErr: "do not contain a JSON object"},
+		{name: "still invalid", arguments: `{"a" 1,}`, wantErr: "not valid JSON even after repair"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed, repaired, err := RepairArguments(tt.arguments)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RepairArguments(%q) = %q, %v; want an error containing %q", tt.arguments, fixed, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RepairArguments(%q) returned error: %v", tt.arguments, err)
+			}
+			if repaired != tt.repaired {
+				t.Errorf("RepairArguments(%q) repaired = %v, want %v", tt.arguments, repaired, tt.repaired)
+			}
+			if !tt.repaired {
+				if fixed != tt.want {
+					t.Errorf("RepairArguments(%q) = %q, want it unchanged", tt.arguments, fixed)
+				}
+				return
+			}
+			if !jsonEquivalent(t, fixed, tt.want) {
+				t.Errorf("RepairArguments(%q) = %s, want %s", tt.arguments, fixed, tt.want)
+			}
+		})
+	}
+}
+
+// jsonEquivalent 判断两段 JSON 解码后是否相同。
+func jsonEquivalent(t *testing.T, got, want string) bool {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal([]byte(got), &g); err != nil {
+		t.Errorf("repaired arguments are not valid JSON: %v", err)
+		return false
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("invalid expectation %s: %v", want, err)
+	}
+	return reflect.DeepEqual(g, w)
+}
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxRetryAfter 是愿意遵从的 Retry-After 上限。服务端要求等待更久时直接放弃重试，
+// 避免用户在终端前长时间无响应。
+const maxRetryAfter = 2 * time.Minute
+
+// maxErrorBodyPeek 是为了分类错误而读取的响应体上限。
+const maxErrorBodyPeek = 64 * 1024
+
+// RetryPolicy 配置 OpenAIClient 在遇到临时错误时的重试行为。
+type RetryPolicy struct {
+	MaxRetries     int           // 最大重试次数（不含首次请求），0 表示不重试
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 单次等待时间的上限
+	Jitter         float64       // 抖动比例（0~1），避免多个客户端同时重试
+}
+
+// DefaultRetryPolicy 返回默认的重试策略。
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+// backoff 返回第 attempt 次重试（从 1 开始）前应等待的时间：指数退避并叠加随机抖动。
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		// 在 [1-Jitter, 1+Jitter] 区间内随机缩放
+		wait *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
+
+// rateLimiter 是一个简单的请求速率限制器，保证相邻两次请求之间至少间隔 interval。
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter 创建一个每分钟最多允许 requestsPerMinute 次请求的限制器。
+func newRateLimiter(requestsPerMinute int) *rateLimiter {
+	return &rateLimiter{interval: time.Minute / time.Duration(requestsPerMinute)}
+}
+
+// Wait 阻塞直到允许发出下一次请求，或 ctx 被取消。
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, wait)
+}
+
+// retryingDoer 包装了一个 openai.HTTPDoer，为每个 HTTP 请求加上限流和重试。
+// 在 HTTP 层重试意味着阻塞与流式请求都能受益，而流式响应一旦开始下发就不会再被重试。
+type retryingDoer struct {
+	doer    openai.HTTPDoer
+	policy  RetryPolicy
+	limiter *rateLimiter
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// Do 发送请求，并在遇到可重试的错误时按策略重试。
+func (d *retryingDoer) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if d.limiter != nil {
+			if err := d.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := d.doer.Do(req)
+		retry, wait := d.shouldRetry(resp, err, attempt+1)
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			// 丢弃本次响应，以便复用连接
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := d.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry 判断第 attempt 次重试是否应该进行，以及需要等待多久。
+func (d *retryingDoer) shouldRetry(resp *http.Response, err error, attempt int) (bool, time.Duration) {
+	if attempt > d.policy.MaxRetries {
+		return false, 0
+	}
+	// 请求本身（可能因网络问题）失败
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false, 0
+		}
+		return true, d.policy.backoff(attempt)
+	}
+	if resp.StatusCode < 400 {
+		return false, 0
+	}
+
+	// 读取部分响应体用于分类，然后将其还原，保证 SDK 仍能解析出完整的错误信息
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyPeek))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+
+	if !classifyStatus(resp.StatusCode, body).Retryable() {
+		return false, 0
+	}
+
+	wait := d.policy.backoff(attempt)
+	if retryAfter, ok := parseRetryAfter(resp.Header); ok {
+		if retryAfter > maxRetryAfter {
+			return false, 0
+		}
+		wait = retryAfter
+	}
+	return true, wait
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期）以及 OpenAI 使用的 retry-after-ms 响应头。
+func parseRetryAfter(header http.Header) (time.Duration, bool) {
+	if ms := header.Get("Retry-After-Ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v >= 0 {
+			return time.Duration(v * float64(time.Millisecond)), true
+		}
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// sleepContext 等待 d 时间，除非 ctx 提前被取消。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
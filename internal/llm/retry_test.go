@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedServer 按顺序返回 statuses 中的状态码，用完后一直返回 200。
+func scriptedServer(t *testing.T, statuses []int, header http.Header, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(hits.Add(1))
+		status := http.StatusOK
+		if n <= len(statuses) {
+			status = statuses[n-1]
+		}
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// newTestDoer 创建一个不真正等待、而是记录每次等待时间的 retryingDoer。
+func newTestDoer(srv *httptest.Server, maxRetries int) (*retryingDoer, *[]time.Duration) {
+	var sleeps []time.Duration
+	return &retryingDoer{
+		doer:   srv.Client(),
+		policy: RetryPolicy{MaxRetries: maxRetries, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second},
+		sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return ctx.Err()
+		},
+	}, &sleeps
+}
+
+func doPost(t *testing.T, doer *retryingDoer, ctx context.Context, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(`{"model":"m"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doer.Do(req)
+}
+
+func TestRetryingDoer(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		header     http.Header
+		body       string
+		maxRetries int
+		wantStatus int
+		wantHits   int32
+		wantSleeps []time.Duration // 为 nil 时只检查次数
+		wantCount  int
+	}{
+		{
+			name:       "429 honours Retry-After",
+			statuses:   []int{429},
+			header:     http.Header{"Retry-After": {"3"}},
+			maxRetries: 3,
+			wantStatus: 200,
+			wantHits:   2,
+			wantSleeps: []time.Duration{3 * time.Second},
+		},
+		{
+			name:       "retry-after-ms takes precedence",
+			statuses:   []int{429},
+			header:     http.Header{"Retry-After": {"3"}, "Retry-After-Ms": {"250"}},
+			maxRetries: 3,
+			wantStatus: 200,
+			wantHits:   2,
+			wantSleeps: []time.Duration{250 * time.Millisecond},
+		},
+		{
+			name:       "Retry-After above the limit is not honoured",
+			statuses:   []int{429},
+			header:     http.Header{"Retry-After": {"3600"}},
+			maxRetries: 3,
+			wantStatus: 429,
+			wantHits:   1,
+		},
+		{
+			name:       "5xx then success",
+			statuses:   []int{500, 503},
+			maxRetries: 3,
+			wantStatus: 200,
+			wantHits:   3,
+			wantCount:  2,
+		},
+		{
+			name:       "gives up after MaxRetries",
+			statuses:   []int{502, 502, 502, 502, 502},
+			maxRetries: 2,
+			wantStatus: 502,
+			wantHits:   3,
+			wantCount:  2,
+		},
+		{
+			name:       "no retry on 401",
+			statuses:   []int{401},
+			body:       `{"error":{"message":"invalid api key","type":"invalid_request_error"}}`,
+			maxRetries: 3,
+			wantStatus: 401,
+			wantHits:   1,
+		},
+		{
+			name:       "no retry on 400",
+			statuses:   []int{400},
+			body:       `{"error":{"message":"bad request","type":"invalid_request_error"}}`,
+			maxRetries: 3,
+			wantStatus: 400,
+			wantHits:   1,
+		},
+		{
+			name:       "no retry on insufficient quota",
+			statuses:   []int{429},
+			body:       `{"error":{"message":"quota","type":"insufficient_quota"}}`,
+			maxRetries: 3,
+			wantStatus: 429,
+			wantHits:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := scriptedServer(t, tt.statuses, tt.header, tt.body)
+			doer, sleeps := newTestDoer(srv, tt.maxRetries)
+			resp, err := doPost(t, doer, context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("server hits = %d, want %d", got, tt.wantHits)
+			}
+			if tt.wantSleeps != nil {
+				if len(*sleeps) != len(tt.wantSleeps) || (*sleeps)[0] != tt.wantSleeps[0] {
+					t.Errorf("sleeps = %v, want %v", *sleeps, tt.wantSleeps)
+				}
+			} else if len(*sleeps) != tt.wantCount {
+				t.Errorf("sleeps = %v, want %d of them", *sleeps, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestRetryingDoerPreservesErrorBody(t *testing.T) {
+	body := `{"error":{"message":"invalid api key","type":"invalid_request_error"}}`
+	srv, _ := scriptedServer(t, []int{401}, nil, body)
+	client := NewOpenAIClient("key", srv.URL, WithRetryPolicy(RetryPolicy{MaxRetries: 3}))
+	_, err := client.ChatCompletion(context.Background(), ChatRequest{Model: "m"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if kind := ClassifyError(err); kind != ErrorKindAuth {
+		t.Errorf("ClassifyError = %v, want auth (err: %v)", kind, err)
+	}
+	if !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("error %q lost the response body", err)
+	}
+}
+
+func TestRetryingDoerCancelledDuringBackoff(t *testing.T) {
+	srv, hits := scriptedServer(t, []int{503, 503}, nil, "")
+	doer := &retryingDoer{
+		doer:   srv.Client(),
+		policy: RetryPolicy{MaxRetries: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute},
+		sleep:  sleepContext,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := doPost(t, doer, ctx, srv.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("cancellation took %s", elapsed)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hits = %d, want 1", got)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   ErrorKind
+	}{
+		{429, `{"error":{"message":"slow down","type":"rate_limit_error"}}`, ErrorKindRateLimit},
+		{429, `{"error":{"message":"quota","type":"insufficient_quota"}}`, ErrorKindQuota},
+		{500, `oops`, ErrorKindTransient},
+		{503, ``, ErrorKindTransient},
+		{401, `{"error":{"message":"bad key"}}`, ErrorKindAuth},
+		{403, `{"error":{"message":"forbidden"}}`, ErrorKindAuth},
+		{400, `{"error":{"message":"This model's maximum context length is 8192 tokens","code":"context_length_exceeded"}}`, ErrorKindContextLength},
+		{400, `{"error":{"message":"bad request"}}`, ErrorKindInvalidRequest},
+	}
+	for _, tt := range tests {
+		if got := classifyStatus(tt.status, []byte(tt.body)); got != tt.want {
+			t.Errorf("classifyStatus(%d, %s) = %v, want %v", tt.status, tt.body, got, tt.want)
+		}
+	}
+	if got := ClassifyError(context.Canceled); got != ErrorKindCanceled {
+		t.Errorf("ClassifyError(context.Canceled) = %v", got)
+	}
+}
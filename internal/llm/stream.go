@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// StreamAccumulator 将流式下发的增量片段组装成一条完整的助手消息。
+// 文本内容直接拼接；工具调用按 Index 归并，参数 JSON 片段依次追加。
+type StreamAccumulator struct {
+	content      strings.Builder
+	toolCalls    []ToolCall
+	indexes      map[int]int // 增量片段的 Index -> toolCalls 中的位置
+	finishReason string
+	usage        Usage
+}
+
+// NewStreamAccumulator 创建一个空的累加器。
+func NewStreamAccumulator() *StreamAccumulator {
+	return &StreamAccumulator{
+		indexes: make(map[int]int),
+	}
+}
+
+// Add 将一个增量片段合并到累加器中。
+func (a *StreamAccumulator) Add(delta StreamDelta) {
+	a.content.WriteString(delta.Content)
+	for _, tc := range delta.ToolCalls {
+		pos, ok := a.indexes[tc.Index]
+		if !ok {
+			pos = len(a.toolCalls)
+			a.indexes[tc.Index] = pos
+			a.toolCalls = append(a.toolCalls, ToolCall{Type: "function"})
+		}
+		call := &a.toolCalls[pos]
+		if tc.ID != "" {
+			call.ID = tc.ID
+		}
+		if tc.Type != "" {
+			call.Type = tc.Type
+		}
+		if tc.Name != "" {
+			call.Function.Name += tc.Name
+		}
+		call.Function.Arguments += tc.Arguments
+	}
+	if delta.FinishReason != "" {
+		a.finishReason = delta.FinishReason
+	}
+	if delta.Usage != nil {
+		a.usage = *delta.Usage
+	}
+}
+
+// Usage 返回流中报告的 token 用量。服务端未报告时为零值。
+func (a *StreamAccumulator) Usage() Usage {
+	return a.usage
+}
+
+// Message 返回目前为止组装出的助手消息。
+func (a *StreamAccumulator) Message() Message {
+	message := Message{Role: "assistant"}
+	if a.content.Len() > 0 {
+		content := a.content.String()
+		message.Content = &content
+	}
+	if len(a.toolCalls) > 0 {
+		message.ToolCalls = append([]ToolCall(nil), a.toolCalls...)
+	}
+	return message
+}
+
+// CollectStream 读取整个流并将其组装为一个 ChatResponse。
+// 每收到一个片段都会调用 onDelta（可以为 nil），用于实时展示生成的内容。
+// 无论成功与否，流都会在返回前被关闭。
+func CollectStream(stream ChatStream, onDelta func(StreamDelta)) (*ChatResponse, error) {
+	defer stream.Close()
+
+	acc := NewStreamAccumulator()
+	for {
+		delta, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		acc.Add(delta)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+
+	return &ChatResponse{
+		Choices: []Choice{{Index: 0, Message: acc.Message()}},
+		Usage:   acc.Usage(),
+	}, nil
+}
@@ -0,0 +1,313 @@
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// textToolsPrompt 是文本模式下追加到系统提示词中的工具调用说明，%s 为工具列表。
+const textToolsPrompt = `
+
+**工具调用格式（文本模式）：**
+当前模型不支持原生的 tool_calls，请改为在回复中使用以下纯文本格式调用工具：
+
+Thought: <你的思考过程>
+Action: <工具名称>
+Action Input: <一个 JSON 对象形式的参数>
+
+- Action 必须是下面列出的工具之一，Action Input 必须是单个合法的 JSON 对象，不要用代码块包裹。
+- 需要同时调用多个工具时，可以重复多组 Action / Action Input。
+- 写完 Action Input 后立即停止输出，不要自己编写 Observation，工具的执行结果会以 "Observation:" 开头的消息发送给你。
+- 任务完成时，不要输出 Action，而是输出 "Final Answer: <最终回答>"。
+
+可用的工具（参数以 JSON Schema 描述）：
+%s`
+
+// TextToolClient 为不支持原生函数调用（请求中的 tools 参数）的模型实现 ReAct 的文本协议。
+// 它把工具定义渲染进系统提示词，把历史中的工具调用和工具结果改写为普通文本，
+// 再从模型回复中解析出 "Action: <tool>" / "Action Input: {...}" 块并转换为 ToolCall，
+// 因此 Runner 不需要知道底层模型使用的是哪一种方式。
+type TextToolClient struct {
+	inner LLM
+}
+
+// NewTextToolClient 用文本协议包装一个 LLM 客户端。
+func NewTextToolClient(inner LLM) *TextToolClient {
+	return &TextToolClient{inner: inner}
+}
+
+// ChatCompletion 实现了 LLM 接口。
+func (c *TextToolClient) ChatCompletion(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	response, err := c.inner.ChatCompletion(ctx, toTextRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	for i := range response.Choices {
+		message := &response.Choices[i].Message
+		if message.Content == nil || len(message.ToolCalls) > 0 {
+			continue
+		}
+		content, toolCalls := ParseTextActions(*message.Content)
+		message.Content = &content
+		message.ToolCalls = toolCalls
+	}
+	return response, nil
+}
+
+// ChatCompletionStream 实现了 LLM 接口。
+// 思考内容会实时下发；从第一行 "Action:" 开始的部分被暂存，流结束后解析为工具调用片段。
+func (c *TextToolClient) ChatCompletionStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	stream, err := c.inner.ChatCompletionStream(ctx, toTextRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	return &textToolStream{inner: stream}, nil
+}
+
+// toTextRequest 把请求转换为不带 tools 参数的纯文本请求。
+// 没有工具定义时，历史中的工具调用同样需要改写，否则模型无法理解 role 为 tool 的消息。
+func toTextRequest(request ChatRequest) ChatRequest {
+	messages := make([]Message, 0, len(request.Messages)+1)
+	if len(request.Tools) > 0 {
+		prompt := fmt.Sprintf(textToolsPrompt, renderTools(request.Tools))
+		if len(request.Messages) > 0 && request.Messages[0].Role == "system" && request.Messages[0].Content != nil {
+			content := *request.Messages[0].Content + prompt
+			messages = append(messages, Message{Role: "system", Content: &content})
+			request.Messages = request.Messages[1:]
+		} else {
+			content := strings.TrimSpace(prompt)
+			messages = append(messages, Message{Role: "system", Content: &content})
+		}
+	}
+
+	toolNames := make(map[string]string) // 工具调用 ID -> 工具名称
+	for _, msg := range request.Messages {
+		switch {
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			var b strings.Builder
+			if msg.Content != nil {
+				b.WriteString(strings.TrimSpace(*msg.Content))
+			}
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				fmt.Fprintf(&b, "\nAction: %s\nAction Input: %s", call.Function.Name, call.Function.Arguments)
+			}
+			content := strings.TrimSpace(b.String())
+			messages = append(messages, Message{Role: "assistant", Content: &content})
+		case msg.Role == "tool":
+			var observation string
+			if msg.Content != nil {
+				observation = *msg.Content
+			}
+			text := fmt.Sprintf("Observation (%s): %s", toolNames[msg.ToolCallID], observation)
+			// 同一步的多个工具结果合并为一条用户消息，保持 user / assistant 交替
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "user" && messages[last].Content != nil &&
+				strings.HasPrefix(*messages[last].Content, "Observation (") {
+				text = *messages[last].Content + "\n\n" + text
+				messages[last].Content = &text
+				continue
+			}
+			messages = append(messages, Message{Role: "user", Content: &text})
+		default:
+			messages = append(messages, msg)
+		}
+	}
+	return ChatRequest{Model: request.Model, Messages: messages}
+}
+
+// renderTools 把工具定义渲染为提示词中的列表。
+func renderTools(tools []Tool) string {
+	var b strings.Builder
+	for _, tool := range tools {
+		parameters, err := json.Marshal(tool.Function.Parameters)
+		if err != nil {
+			parameters = []byte("{}")
+		}
+		fmt.Fprintf(&b, "- %s: %s\n  Parameters: %s\n", tool.Function.Name, tool.Function.Description, parameters)
+	}
+	return b.String()
+}
+
+// ParseTextActions 从模型的纯文本回复中解析出 "Action:" / "Action Input:" 块。
+// 返回第一个 Action 之前的内容（思考过程或最终回答）和解析出的工具调用。
+// 模型自己编写的 "Observation:" 及其之后的内容会被丢弃。参数原样保留，由调用方负责修复格式错误的 JSON。
+func ParseTextActions(text string) (string, []ToolCall) {
+	lines := strings.Split(text, "\n")
+	var contentLines []string
+	var toolCalls []ToolCall
+	var input *strings.Builder // 当前 Action Input 的内容，nil 表示不在 Action Input 中
+	finishInput := func() {
+		if input != nil && len(toolCalls) > 0 {
+			toolCalls[len(toolCalls)-1].Function.Arguments = strings.TrimSpace(input.String())
+		}
+		input = nil
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if name, ok := cutLabel(trimmed, "Action:"); ok {
+			finishInput()
+			toolCalls = append(toolCalls, ToolCall{
+				ID:       newTextCallID(),
+				Type:     "function",
+				Function: FunctionCall{Name: strings.Trim(name, "`* ")},
+			})
+			continue
+		}
+		if _, ok := cutLabel(trimmed, "Observation:"); ok {
+			break
+		}
+		if len(toolCalls) == 0 {
+			contentLines = append(contentLines, line)
+			continue
+		}
+		if rest, ok := cutLabel(trimmed, "Action Input:"); ok {
+			finishInput()
+			input = &strings.Builder{}
+			input.WriteString(rest)
+			continue
+		}
+		if _, ok := cutLabel(trimmed, "Thought:"); ok {
+			finishInput()
+			continue
+		}
+		if input != nil {
+			input.WriteString("\n" + line)
+		}
+	}
+	finishInput()
+
+	content := strings.TrimSpace(strings.Join(contentLines, "\n"))
+	if len(toolCalls) == 0 {
+		return content, nil
+	}
+	return content, toolCalls
+}
+
+// cutLabel 判断一行是否以指定的标签开头（忽略大小写和 Markdown 加粗），返回标签之后的内容。
+func cutLabel(line, label string) (string, bool) {
+	line = strings.TrimLeft(line, "*")
+	if len(line) < len(label) || !strings.EqualFold(line[:len(label)], label) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimLeft(line[len(label):], "*")), true
+}
+
+// newTextCallID 为文本协议中解析出的工具调用生成一个唯一的 ID。
+func newTextCallID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return "call_" + hex.EncodeToString(buf[:])
+}
+
+// textToolStream 在流式模式下实现文本协议。
+type textToolStream struct {
+	inner     ChatStream
+	text      strings.Builder // 目前为止收到的全部文本
+	emitted   int             // 已作为内容下发的字节数
+	lineStart int             // 当前（可能尚未接收完整的）行的起始位置
+	inAction  bool            // 是否已经遇到 "Action:" 或 "Observation:" 行，此后的文本不再下发
+	held      []StreamDelta   // 结束标记和用量，等工具调用解析完成后再下发
+	pending   []StreamDelta   // 待下发的片段
+	done      bool
+}
+
+func (s *textToolStream) Recv() (StreamDelta, error) {
+	for {
+		if len(s.pending) > 0 {
+			delta := s.pending[0]
+			s.pending = s.pending[1:]
+			return delta, nil
+		}
+		if s.done {
+			return StreamDelta{}, io.EOF
+		}
+
+		delta, err := s.inner.Recv()
+		if errors.Is(err, io.EOF) {
+			s.finish()
+			continue
+		}
+		if err != nil {
+			return StreamDelta{}, err
+		}
+		if delta.FinishReason != "" || delta.Usage != nil {
+			s.held = append(s.held, StreamDelta{FinishReason: delta.FinishReason, Usage: delta.Usage})
+		}
+		s.text.WriteString(delta.Content)
+		if content := s.releasable(); content != "" {
+			return StreamDelta{Content: content}, nil
+		}
+	}
+}
+
+// releasable 返回可以作为内容下发的新文本：第一行 "Action:" 之前的部分。
+// 可能成为 "Action:" 行开头的不完整的行会被暂缓下发。
+func (s *textToolStream) releasable() string {
+	text := s.text.String()
+	end := s.emitted
+	for !s.inAction {
+		rest := text[s.lineStart:]
+		line, _, complete := strings.Cut(rest, "\n")
+		if isActionLine(line) {
+			s.inAction = true
+			break
+		}
+		if !complete {
+			if !mayBecomeActionLine(line) {
+				end = len(text)
+			}
+			break
+		}
+		s.lineStart += len(line) + 1
+		end = s.lineStart
+	}
+	end = max(end, s.emitted)
+	content := text[s.emitted:end]
+	s.emitted = end
+	return content
+}
+
+// finish 在流结束时解析工具调用，并排队下发剩余的内容、工具调用和结束标记。
+func (s *textToolStream) finish() {
+	s.done = true
+	text := s.text.String()
+	if !s.inAction && s.emitted < len(text) {
+		s.pending = append(s.pending, StreamDelta{Content: text[s.emitted:]})
+	}
+	_, toolCalls := ParseTextActions(text)
+	for i, call := range toolCalls {
+		s.pending = append(s.pending, StreamDelta{ToolCalls: []ToolCallDelta{{
+			Index:     i,
+			ID:        call.ID,
+			Type:      call.Type,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		}}})
+	}
+	s.pending = append(s.pending, s.held...)
+}
+
+func (s *textToolStream) Close() error {
+	return s.inner.Close()
+}
+
+// isActionLine 判断一行是否开始了工具调用，或者是模型自己编写的观察结果。
+func isActionLine(line string) bool {
+	line = strings.TrimSpace(line)
+	_, action := cutLabel(line, "Action:")
+	_, observation := cutLabel(line, "Observation:")
+	return action || observation
+}
+
+// mayBecomeActionLine 判断一个尚未接收完整的行在后续内容到达后是否可能成为 isActionLine 匹配的行。
+func mayBecomeActionLine(partial string) bool {
+	p := strings.ToLower(strings.TrimLeft(partial, " \t*"))
+	return strings.HasPrefix("action:", p) || strings.HasPrefix("observation:", p)
+}
@@ -0,0 +1,177 @@
+package llm
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// call 是测试中期望解析出的工具调用，ID 是随机生成的，不参与比较。
+type call struct {
+	name, arguments string
+}
+
+func TestParseTextActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		wantContent string
+		wantCalls   []call
+	}{
+		{
+			name:        "final answer only",
+			text:        "Thought: nothing to do\nFinal Answer: port 8080 is free",
+			wantContent: "Thought: nothing to do\nFinal Answer: port 8080 is free",
+		},
+		{
+			name:        "single action",
+			text:        "Thought: check the port\nAction: ss\nAction Input: {\"port\": 8080}",
+			wantContent: "Thought: check the port",
+			wantCalls:   []call{{"ss", `{"port": 8080}`}},
+		},
+		{
+			name:      "multi-line input",
+			text:      "Action: find\nAction Input: {\n  \"path\": \"/var/log\",\n  \"name\": \"*.log\"\n}\n",
+			wantCalls: []call{{"find", "{\n  \"path\": \"/var/log\",\n  \"name\": \"*.log\"\n}"}},
+		},
+		{
+			name:        "several actions",
+			text:        "Thought: look around\nAction: ps\nAction Input: {\"name\": \"nginx\"}\nThought: and the port\nAction: ss\nAction Input: {\"port\": 80}",
+			wantContent: "Thought: look around",
+			wantCalls:   []call{{"ps", `{"name": "nginx"}`}, {"ss", `{"port": 80}`}},
+		},
+		{
+			name:      "invented observation is dropped",
+			text:      "Action: ps\nAction Input: {}\nObservation: nginx is running\nFinal Answer: done",
+			wantCalls: []call{{"ps", "{}"}},
+		},
+		{
+			name:        "markdown and case",
+			text:        "**Thought:** hm\n**action:** `grep`\n**ACTION INPUT:** {\"pattern\": \"x\"}",
+			wantContent: "**Thought:** hm",
+			wantCalls:   []call{{"grep", `{"pattern": "x"}`}},
+		},
+		{
+			name:      "action without input",
+			text:      "Action: ps",
+			wantCalls: []call{{"ps", ""}},
+		},
+		{
+			name:        "observation without action",
+			text:        "The answer\nObservation: made up",
+			wantContent: "The answer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, toolCalls := ParseTextActions(tt.text)
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			checkCalls(t, toolCalls, tt.wantCalls)
+		})
+	}
+}
+
+// sliceStream 依次返回预先准备好的片段。
+type sliceStream struct {
+	deltas []StreamDelta
+	closed bool
+}
+
+func (s *sliceStream) Recv() (StreamDelta, error) {
+	if len(s.deltas) == 0 {
+		return StreamDelta{}, io.EOF
+	}
+	delta := s.deltas[0]
+	s.deltas = s.deltas[1:]
+	return delta, nil
+}
+
+func (s *sliceStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestTextToolStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		chunks      []string
+		wantContent string
+		wantCalls   []call
+	}{
+		{
+			name:        "final answer",
+			chunks:      []string{"Final ", "Answer: all ", "good"},
+			wantContent: "Final Answer: all good",
+		},
+		{
+			name:        "action split across chunks",
+			chunks:      []string{"Thought: check\nAc", "tion: s", "s\nAction Input: {\"port\"", ": 22}"},
+			wantContent: "Thought: check\n",
+			wantCalls:   []call{{"ss", `{"port": 22}`}},
+		},
+		{
+			name:        "line that only looks like an action at first",
+			chunks:      []string{"Act", "ually the port is free"},
+			wantContent: "Actually the port is free",
+		},
+		{
+			name:      "invented observation is not streamed",
+			chunks:    []string{"Action: ps\nAction Input: {}\n", "Observation: fake\n", "Final Answer: fake"},
+			wantCalls: []call{{"ps", "{}"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &sliceStream{}
+			for _, chunk := range tt.chunks {
+				inner.deltas = append(inner.deltas, StreamDelta{Content: chunk})
+			}
+			usage := &Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+			inner.deltas = append(inner.deltas, StreamDelta{FinishReason: "stop", Usage: usage})
+
+			var deltas []StreamDelta
+			response, err := CollectStream(&textToolStream{inner: inner}, func(d StreamDelta) { deltas = append(deltas, d) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !inner.closed {
+				t.Error("inner stream was not closed")
+			}
+
+			var streamed strings.Builder
+			for _, d := range deltas {
+				streamed.WriteString(d.Content)
+			}
+			if streamed.String() != tt.wantContent {
+				t.Errorf("streamed content = %q, want %q", streamed.String(), tt.wantContent)
+			}
+			// 结束标记和用量必须在工具调用之后下发
+			if last := deltas[len(deltas)-1]; last.FinishReason != "stop" || last.Usage == nil {
+				t.Errorf("last delta = %+v, want the finish reason and usage", last)
+			}
+			if response.Usage != *usage {
+				t.Errorf("usage = %+v, want %+v", response.Usage, *usage)
+			}
+			checkCalls(t, response.Choices[0].Message.ToolCalls, tt.wantCalls)
+		})
+	}
+}
+
+func checkCalls(t *testing.T, got []ToolCall, want []call) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d tool calls %+v, want %d", len(got), got, len(want))
+	}
+	ids := make(map[string]bool)
+	for i, c := range got {
+		if c.Function.Name != want[i].name || c.Function.Arguments != want[i].arguments {
+			t.Errorf("tool call %d = %s(%s), want %s(%s)", i, c.Function.Name, c.Function.Arguments, want[i].name, want[i].arguments)
+		}
+		if c.ID == "" || ids[c.ID] {
+			t.Errorf("tool call %d has an empty or duplicate ID %q", i, c.ID)
+		}
+		ids[c.ID] = true
+	}
+}
@@ -47,6 +47,25 @@ type ChatResponse struct {
 	ID      string   `json:"id"`
 	Model   string   `json:"model"`
 	Choices []Choice `json:"choices"`
+	Usage   Usage    `json:"usage"` // 本次请求消耗的 token 数量
+}
+
+// Usage 记录一次或多次请求消耗的 token 数量。
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`     // 输入 token 数（包含命中缓存的部分）
+	CompletionTokens int `json:"completion_tokens"` // 输出 token 数
+	CachedTokens     int `json:"cached_tokens"`     // 输入中命中提示词缓存的 token 数
+	TotalTokens      int `json:"total_tokens"`      // 总 token 数
+}
+
+// Add 返回两份用量之和。
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
 }
 
 // Choice 是 API 返回的响应选项之一。
@@ -54,3 +73,22 @@ type Choice struct {
 	Index   int     `json:"index"`
 	Message Message `json:"message"` // 模型生成的消息
 }
+
+// StreamDelta 是流式响应中的一个增量片段。
+type StreamDelta struct {
+	Content      string          `json:"content,omitempty"`       // 新增的文本内容
+	ToolCalls    []ToolCallDelta `json:"tool_calls,omitempty"`    // 工具调用的增量片段
+	FinishReason string          `json:"finish_reason,omitempty"` // 非空表示该选项已生成完毕
+	Usage        *Usage          `json:"usage,omitempty"`         // 仅在最后一个片段中出现，记录整个请求的用量
+}
+
+// ToolCallDelta 是一个工具调用的增量片段。
+// 同一个工具调用会被拆成多个片段下发：首个片段通常带有 ID 和函数名，
+// 后续片段只携带参数 JSON 字符串的一部分，需要按 Index 拼接。
+type ToolCallDelta struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// Mode 是对工具调用的批准方式。
+type Mode string
+
+const (
+	ModeAuto Mode = "auto" // 不询问用户，直接执行
+	ModeAsk  Mode = "ask"  // 执行前询问用户
+	ModeDeny Mode = "deny" // 不询问用户，直接拒绝
+)
+
+// ParseMode 解析配置中的批准方式。
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(s); mode {
+	case ModeAuto, ModeAsk, ModeDeny:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid approval mode %q, expected auto, ask or deny", s)
+}
+
+// ApprovalRule 是一条按参数匹配的批准规则，例如"自动批准在 /var/log 之下的 find"。
+type ApprovalRule struct {
+	Name string     // 规则名称，用于向用户和模型说明做出决定的原因
+	Tool string     // 工具名称，"*" 或为空表示所有工具
+	Args Conditions // 参数需要满足的约束，为空表示不限制参数
+	Mode Mode       // 规则匹配时使用的批准方式
+}
+
+// Decision 是 Approver 对一个工具调用做出的决定。
+type Decision struct {
+	Mode Mode
+	// Source 说明决定的来源，例如 "rule 'find-var-log'"、"tool policy"、"default policy"、
+	// "allowed for this session" 或 "--yolo"
+	Source string
+}
+
+// Approver 按配置的策略决定工具调用是自动执行、询问用户还是直接拒绝。
+// 规则按顺序匹配，第一条匹配的规则生效；没有规则匹配时使用按工具名称配置的方式，最后使用默认方式。
+// 自动批准的规则要求参数数组中的每个元素都满足约束，询问和拒绝的规则只要有一个元素满足即可，
+// 与 RuleSet 中 allow 和 deny 规则的匹配方式相同。
+// 它可以在多个 goroutine 中安全使用。
+type Approver struct {
+	defaultMode   Mode
+	tools         map[string]Mode
+	rules         []ApprovalRule
+	yolo          bool
+	ruleArguments func(toolCall llm.ToolCall) string
+
+	mu      sync.Mutex
+	session map[string]bool // 本次会话中被用户选择"总是允许"的调用
+}
+
+// NewApprover 创建一个 Approver。defaultMode 为空时使用 ModeAsk。
+func NewApprover(defaultMode Mode, tools map[string]Mode, rules []ApprovalRule) (*Approver, error) {
+	if defaultMode == "" {
+		defaultMode = ModeAsk
+	}
+	if _, err := ParseMode(string(defaultMode)); err != nil {
+		return nil, fmt.Errorf("default approval mode: %w", err)
+	}
+	for name, mode := range tools {
+		if _, err := ParseMode(string(mode)); err != nil {
+			return nil, fmt.Errorf("approval mode for tool '%s': %w", name, err)
+		}
+	}
+	rules = append([]ApprovalRule(nil), rules...)
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rules[i].Name = fmt.Sprintf("#%d", i+1)
+		}
+		if _, err := ParseMode(string(rule.Mode)); err != nil {
+			return nil, fmt.Errorf("approval rule %s: %w", rules[i].Name, err)
+		}
+		for arg, condition := range rule.Args {
+			if err := condition.Validate(); err != nil {
+				return nil, fmt.Errorf("approval rule %s, argument '%s': %w", rules[i].Name, arg, err)
+			}
+		}
+	}
+	return &Approver{
+		defaultMode: defaultMode,
+		tools:       tools,
+		rules:       rules,
+		session:     make(map[string]bool),
+	}, nil
+}
+
+// SetYOLO 开启或关闭 "--yolo" 模式：所有需要询问的调用都自动批准，被拒绝的调用仍然会被拒绝。
+func (a *Approver) SetYOLO(yolo bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.yolo = yolo
+}
+
+// SetRuleArguments 设置规则匹配时使用的参数。与 RuleSet 一样，规则应当看到补上缺省值、规范化之后的路径，
+// 否则省略参数或写成相对路径就能绕过规则。fn 为 nil 时使用模型给出的原始参数。
+func (a *Approver) SetRuleArguments(fn func(toolCall llm.ToolCall) string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ruleArguments = fn
+}
+
+// Decide 决定如何处理一个工具调用。
+func (a *Approver) Decide(toolCall llm.ToolCall) Decision {
+	decision := a.decide(toolCall)
+	if decision.Mode != ModeAsk {
+		return decision
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case a.session[callKey(toolCall)]:
+		return Decision{Mode: ModeAuto, Source: "allowed for this session"}
+	case a.yolo:
+		return Decision{Mode: ModeAuto, Source: "--yolo"}
+	}
+	return decision
+}
+
+func (a *Approver) decide(toolCall llm.ToolCall) Decision {
+	name := toolCall.Function.Name
+	a.mu.Lock()
+	ruleArguments := a.ruleArguments
+	a.mu.Unlock()
+	arguments := toolCall.Function.Arguments
+	if ruleArguments != nil {
+		arguments = ruleArguments(toolCall)
+	}
+	for _, rule := range a.rules {
+		if rule.Tool != "" && rule.Tool != "*" && rule.Tool != name {
+			continue
+		}
+		// 在数组中混入一个受限制的路径不能绕过询问或拒绝的规则
+		matched := rule.Args.MatchAny(arguments)
+		if rule.Mode == ModeAuto {
+			matched = rule.Args.Match(arguments)
+		}
+		if matched {
+			return Decision{Mode: rule.Mode, Source: fmt.Sprintf("rule '%s'", rule.Name)}
+		}
+	}
+	if mode, ok := a.tools[name]; ok {
+		return Decision{Mode: mode, Source: "tool policy"}
+	}
+	return Decision{Mode: a.defaultMode, Source: "default policy"}
+}
+
+// AllowForSession 记住一个调用，本次会话中完全相同的调用（工具名称和参数都相同）不再询问用户。
+func (a *Approver) AllowForSession(toolCall llm.ToolCall) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.session[callKey(toolCall)] = true
+}
+
+// callKey 返回标识一个调用的键。参数先被规范化，使键与属性顺序和空白无关。
+func callKey(toolCall llm.ToolCall) string {
+	arguments := toolCall.Function.Arguments
+	if args, err := decodeArgs(arguments); err == nil {
+		// json.Marshal 按键排序输出对象
+		if normalized, err := json.Marshal(args); err == nil {
+			arguments = string(normalized)
+		}
+	}
+	return toolCall.Function.Name + "\x00" + arguments
+}
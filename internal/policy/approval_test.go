@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// canonicalArguments 模拟 tools.Registry 为规则准备参数的方式：path 和 paths 缺省为当前目录，并规范化为绝对路径。
+func canonicalArguments(toolCall llm.ToolCall) string {
+	args, err := decodeArgs(toolCall.Function.Arguments)
+	if err != nil {
+		return toolCall.Function.Arguments
+	}
+	if p, _ := args["path"].(string); p != "" {
+		args["path"] = CanonicalPath(p)
+	} else {
+		args["path"] = CanonicalPath(".")
+	}
+	if paths, _ := args["paths"].([]any); len(paths) > 0 {
+		for i, p := range paths {
+			if s, ok := p.(string); ok {
+				paths[i] = CanonicalPath(s)
+			}
+		}
+	} else {
+		args["paths"] = []any{CanonicalPath(".")}
+	}
+	data, _ := json.Marshal(args)
+	return string(data)
+}
+
+func TestApproverDecide(t *testing.T) {
+	base := t.TempDir()
+	etc := filepath.Join(base, "etc")
+	logs := filepath.Join(base, "logs")
+	secret := filepath.Join(base, "secret")
+	work := filepath.Join(base, "work")
+	for _, dir := range []string{etc, logs, secret, work} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	approver, err := NewApprover(ModeAsk, map[string]Mode{"ps": ModeAuto, "wget": ModeAsk, "grep": ModeAsk}, []ApprovalRule{
+		{Name: "no-etc", Tool: "find", Args: Conditions{"path": {Under: []string{etc}}}, Mode: ModeDeny},
+		{Name: "logs", Tool: "grep", Args: Conditions{"paths": {Under: []string{logs}}}, Mode: ModeAuto},
+		{Name: "no-secret", Tool: "*", Args: Conditions{"paths": {Under: []string{secret}}}, Mode: ModeDeny},
+		{Name: "local-downloads", Tool: "wget", Args: Conditions{"url": {Hosts: []string{"localhost"}}}, Mode: ModeAuto},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	approver.SetRuleArguments(canonicalArguments)
+
+	tests := []struct {
+		name       string
+		cwd        string
+		call       llm.ToolCall
+		wantMode   Mode
+		wantSource string
+	}{
+		{name: "deny rule", call: toolCall("find", `{"path":"`+etc+`/ssh"}`), wantMode: ModeDeny, wantSource: "rule 'no-etc'"},
+		{name: "deny rule with dot-dot", cwd: work, call: toolCall("find", `{"path":"../etc"}`), wantMode: ModeDeny, wantSource: "rule 'no-etc'"},
+		{name: "deny rule with omitted path", cwd: etc, call: toolCall("find", `{"name":"*.conf"}`), wantMode: ModeDeny, wantSource: "rule 'no-etc'"},
+		{name: "deny rule with empty path", cwd: etc, call: toolCall("find", `{"path":""}`), wantMode: ModeDeny, wantSource: "rule 'no-etc'"},
+		{name: "deny rule does not match", cwd: work, call: toolCall("find", `{"path":"."}`), wantMode: ModeAsk, wantSource: "default policy"},
+		{name: "auto rule", call: toolCall("grep", `{"pattern":"x","paths":["`+logs+`/a.log","`+logs+`/b.log"]}`), wantMode: ModeAuto, wantSource: "rule 'logs'"},
+		{name: "auto rule with relative paths", cwd: logs, call: toolCall("grep", `{"pattern":"x"}`), wantMode: ModeAuto, wantSource: "rule 'logs'"},
+		{name: "auto rule needs every element", call: toolCall("grep", `{"pattern":"x","paths":["`+logs+`/a.log","`+work+`"]}`), wantMode: ModeAsk, wantSource: "tool policy"},
+		{name: "deny rule matches any element", call: toolCall("grep", `{"pattern":"x","paths":["`+logs+`/a.log","`+secret+`/key"]}`), wantMode: ModeDeny, wantSource: "rule 'no-secret'"},
+		{name: "deny rule for any tool", cwd: work, call: toolCall("grep", `{"pattern":"x","paths":["../secret"]}`), wantMode: ModeDeny, wantSource: "rule 'no-secret'"},
+		{name: "auto rule on a url", call: toolCall("wget", `{"url":"http://localhost:8080/a"}`), wantMode: ModeAuto, wantSource: "rule 'local-downloads'"},
+		{name: "tool policy", call: toolCall("wget", `{"url":"https://example.com/a"}`), wantMode: ModeAsk, wantSource: "tool policy"},
+		{name: "tool policy auto", call: toolCall("ps", `{}`), wantMode: ModeAuto, wantSource: "tool policy"},
+		{name: "default policy", call: toolCall("lsof", `{"port":22}`), wantMode: ModeAsk, wantSource: "default policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cwd != "" {
+				t.Chdir(tt.cwd)
+			}
+			got := approver.Decide(tt.call)
+			if got.Mode != tt.wantMode || got.Source != tt.wantSource {
+				t.Errorf("Decide(%s %s) = %+v, want %s by %s", tt.call.Function.Name, tt.call.Function.Arguments, got, tt.wantMode, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestApproverWithoutRuleArguments(t *testing.T) {
+	approver, err := NewApprover(ModeAuto, nil, []ApprovalRule{
+		{Name: "no-etc", Tool: "find", Args: Conditions{"path": {Under: []string{"/etc"}}}, Mode: ModeDeny},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有设置 SetRuleArguments 时只能匹配模型给出的参数
+	if got := approver.Decide(toolCall("find", `{"path":"/etc/../etc/ssh"}`)); got.Mode != ModeDeny {
+		t.Errorf("Decide() = %+v, want the deny rule", got)
+	}
+	if got := approver.Decide(toolCall("find", `{}`)); got.Mode != ModeAuto || got.Source != "default policy" {
+		t.Errorf("Decide() = %+v, want the default policy", got)
+	}
+}
+
+func TestApproverSessionAndYOLO(t *testing.T) {
+	approver, err := NewApprover("", map[string]Mode{"lsof": ModeDeny}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		call llm.ToolCall
+		want Decision
+	}{
+		{name: "default is ask", call: toolCall("wget", `{"url":"https://a","output_file":"x"}`), want: Decision{Mode: ModeAsk, Source: "default policy"}},
+		{name: "denied", call: toolCall("lsof", `{"port":22}`), want: Decision{Mode: ModeDeny, Source: "tool policy"}},
+	}
+	for _, tt := range tests {
+		if got := approver.Decide(tt.call); got != tt.want {
+			t.Errorf("%s: Decide() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// 总是允许的调用按规范化后的参数比较，与属性顺序和空白无关
+	approver.AllowForSession(toolCall("wget", `{"url":"https://a","output_file":"x"}`))
+	if got := approver.Decide(toolCall("wget", `{ "output_file": "x", "url": "https://a" }`)); got != (Decision{Mode: ModeAuto, Source: "allowed for this session"}) {
+		t.Errorf("Decide() of the same call = %+v, want it allowed for this session", got)
+	}
+	if got := approver.Decide(toolCall("wget", `{"url":"https://b","output_file":"x"}`)); got.Mode != ModeAsk {
+		t.Errorf("Decide() of a different call = %+v, want ask", got)
+	}
+
+	// --yolo 自动批准需要询问的调用，但不会覆盖拒绝
+	approver.SetYOLO(true)
+	if got := approver.Decide(toolCall("wget", `{"url":"https://b"}`)); got != (Decision{Mode: ModeAuto, Source: "--yolo"}) {
+		t.Errorf("Decide() with --yolo = %+v, want auto", got)
+	}
+	if got := approver.Decide(toolCall("lsof", `{"port":22}`)); got.Mode != ModeDeny {
+		t.Errorf("Decide() with --yolo = %+v, want the denial kept", got)
+	}
+}
+
+func TestNewApproverRejectsInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultMode Mode
+		tools       map[string]Mode
+		rules       []ApprovalRule
+	}{
+		{name: "default mode", defaultMode: "sometimes"},
+		{name: "tool mode", tools: map[string]Mode{"ps": "always"}},
+		{name: "rule mode", rules: []ApprovalRule{{Tool: "ps", Mode: "yes"}}},
+		{name: "rule pattern", rules: []ApprovalRule{{Tool: "find", Mode: ModeAuto, Args: Conditions{"name": {Matches: []string{"["}}}}}},
+		{name: "rule ports", rules: []ApprovalRule{{Tool: "lsof", Mode: ModeDeny, Args: Conditions{"port": {Ports: "10-1"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewApprover(tt.defaultMode, tt.tools, tt.rules); err == nil {
+				t.Error("NewApprover() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Condition 是对一个工具参数的约束。设置的各项约束需要同时满足；
+// 除 Flags 外，参数是数组时数组中的每个元素都需要满足约束（Conditions.MatchAny 中只需一个元素满足）。
+type Condition struct {
+	Under   []string // 路径位于其中某个目录之内（解析 ".." 和符号链接后比较）
+	Equals  []string // 值等于其中之一
+	Matches []string // 值匹配其中一个通配符，语法与 path.Match 相同
+	Hosts   []string // 值是 URL，且主机名匹配其中一个通配符，例如 "*.example.com"
+	Ports   string   // 值是端口号，且位于这些范围之内，例如 "22,8000-8999"
+	Flags   []string // 值（或数组中的某个元素）是其中一个选项，例如 "-exec" 或 "--output=..."
+}
+
+// Validate 检查约束中的通配符和端口范围是否合法。
+func (c Condition) Validate() error {
+	for _, pattern := range append(append([]string(nil), c.Matches...), c.Hosts...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if c.Ports != "" {
+		if _, err := parsePortRanges(c.Ports); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match 判断一个解码后的参数值是否满足约束。anyItem 为 true 时，数组中只要有一个元素满足即可。
+func (c Condition) match(value any, anyItem bool) bool {
+	if len(c.Flags) > 0 && !hasFlag(value, c.Flags) {
+		return false
+	}
+	if len(c.Under) == 0 && len(c.Equals) == 0 && len(c.Matches) == 0 && len(c.Hosts) == 0 && c.Ports == "" {
+		return true
+	}
+	if items, ok := value.([]any); ok {
+		if len(items) == 0 {
+			return false
+		}
+		for _, item := range items {
+			if c.matchValue(item) == anyItem {
+				return anyItem
+			}
+		}
+		return !anyItem
+	}
+	return c.matchValue(value)
+}
+
+// matchValue 判断一个标量值是否满足除 Flags 以外的约束。
+func (c Condition) matchValue(value any) bool {
+	s, ok := scalarString(value)
+	if !ok {
+		return false
+	}
+	if len(c.Equals) > 0 && !slices.Contains(c.Equals, s) {
+		return false
+	}
+	if len(c.Matches) > 0 && !matchAny(c.Matches, s) {
+		return false
+	}
+	if len(c.Under) > 0 {
+		resolved := CanonicalPath(s)
+		if !slices.ContainsFunc(c.Under, func(root string) bool { return IsWithin(CanonicalPath(root), resolved) }) {
+			return false
+		}
+	}
+	if len(c.Hosts) > 0 {
+		u, err := url.Parse(s)
+		if err != nil || u.Hostname() == "" || !matchAny(c.Hosts, strings.ToLower(u.Hostname())) {
+			return false
+		}
+	}
+	if c.Ports != "" {
+		port, err := strconv.Atoi(s)
+		ranges, _ := parsePortRanges(c.Ports)
+		if err != nil || !slices.ContainsFunc(ranges, func(r [2]int) bool { return port >= r[0] && port <= r[1] }) {
+			return false
+		}
+	}
+	return true
+}
+
+// scalarString 将字符串、数字和布尔值转换为字符串。
+func scalarString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// hasFlag 判断值（或数组中的某个元素）是否是 flags 中的选项。"--output" 同样匹配 "--output=file"。
+func hasFlag(value any, flags []string) bool {
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+	for _, item := range items {
+		s, ok := scalarString(item)
+		if !ok {
+			continue
+		}
+		for _, flag := range flags {
+			if s == flag || strings.HasPrefix(s, flag+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, _ := path.Match(pattern, s)
+		return matched
+	})
+}
+
+// parsePortRanges 解析 "22,8000-8999" 形式的端口范围。
+func parsePortRanges(s string) ([][2]int, error) {
+	var ranges [][2]int
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		low, high, isRange := strings.Cut(part, "-")
+		if !isRange {
+			high = low
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(low))
+		to, err2 := strconv.Atoi(strings.TrimSpace(high))
+		if err1 != nil || err2 != nil || from < 0 || to > 65535 || from > to {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+// Conditions 是按参数名称组织的约束。
+type Conditions map[string]Condition
+
+// Match 判断工具调用的参数是否满足所有约束。参数中缺少被约束的属性时视为不满足。
+func (c Conditions) Match(rawArgs string) bool {
+	return c.match(rawArgs, false)
+}
+
+// MatchAny 与 Match 相同，但参数是数组时只要有一个元素满足约束即可。
+// deny 规则使用它，在数组中混入一个被拒绝的路径不能绕过规则。
+func (c Conditions) MatchAny(rawArgs string) bool {
+	return c.match(rawArgs, true)
+}
+
+func (c Conditions) match(rawArgs string, anyItem bool) bool {
+	if len(c) == 0 {
+		return true
+	}
+	args, err := decodeArgs(rawArgs)
+	if err != nil {
+		return false
+	}
+	for name, condition := range c {
+		value, ok := args[name]
+		if !ok || value == nil || !condition.match(value, anyItem) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeArgs 将工具调用的 JSON 参数解码为对象，数字保留原始文本。
+func decodeArgs(rawArgs string) (map[string]any, error) {
+	args := map[string]any{}
+	if strings.TrimSpace(rawArgs) == "" {
+		return args, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(rawArgs))
+	decoder.UseNumber()
+	if err := decoder.Decode(&args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// CanonicalPath 将路径转换为绝对路径，清理其中的 "." 和 ".."，并尽可能解析符号链接。
+// 路径本身不存在时，解析其最近的已存在的上级目录，再拼接上剩余部分。
+func CanonicalPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			slices.Reverse(rest)
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs
+		}
+		rest = append(rest, filepath.Base(dir))
+	}
+}
+
+// IsWithin 判断 p 是否位于目录 root 之内（或就是 root）。两者都应当是 CanonicalPath 的结果。
+func IsWithin(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConditionsMatch(t *testing.T) {
+	base := t.TempDir()
+	logs := filepath.Join(base, "logs")
+	secret := filepath.Join(base, "secret")
+	for _, dir := range []string{logs, secret} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// logs/link 指向 logs 之外的 secret
+	if err := os.Symlink(secret, filepath.Join(logs, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		conditions Conditions
+		args       string
+		want       bool
+	}{
+		{name: "no conditions", conditions: nil, args: `{}`, want: true},
+		{name: "under", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{"path":"` + logs + `/app.log"}`, want: true},
+		{name: "under root itself", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{"path":"` + logs + `"}`, want: true},
+		{name: "under escapes with dot-dot", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{"path":"` + logs + `/../secret"}`, want: false},
+		{name: "under escapes through symlink", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{"path":"` + logs + `/link/key"}`, want: false},
+		{name: "under sibling with common prefix", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{"path":"` + logs + `-old"}`, want: false},
+		{name: "under array all inside", conditions: Conditions{"paths": {Under: []string{logs}}}, args: `{"paths":["` + logs + `/a","` + logs + `/b"]}`, want: true},
+		{name: "under array one outside", conditions: Conditions{"paths": {Under: []string{logs}}}, args: `{"paths":["` + logs + `/a","` + secret + `"]}`, want: false},
+		{name: "under empty array", conditions: Conditions{"paths": {Under: []string{logs}}}, args: `{"paths":[]}`, want: false},
+		{name: "equals", conditions: Conditions{"format": {Equals: []string{"table", "json"}}}, args: `{"format":"json"}`, want: true},
+		{name: "equals mismatch", conditions: Conditions{"format": {Equals: []string{"table"}}}, args: `{"format":"json"}`, want: false},
+		{name: "equals number", conditions: Conditions{"pid": {Equals: []string{"1"}}}, args: `{"pid":1}`, want: true},
+		{name: "equals boolean", conditions: Conditions{"deleted": {Equals: []string{"true"}}}, args: `{"deleted":true}`, want: true},
+		{name: "matches", conditions: Conditions{"name": {Matches: []string{"*.log"}}}, args: `{"name":"app.log"}`, want: true},
+		{name: "matches mismatch", conditions: Conditions{"name": {Matches: []string{"*.log"}}}, args: `{"name":"app.txt"}`, want: false},
+		{name: "hosts", conditions: Conditions{"url": {Hosts: []string{"*.example.com"}}}, args: `{"url":"https://dl.example.com/a.tgz"}`, want: true},
+		{name: "hosts ignores case and port", conditions: Conditions{"url": {Hosts: []string{"*.example.com"}}}, args: `{"url":"https://DL.Example.com:8443/a"}`, want: true},
+		{name: "hosts suffix attack", conditions: Conditions{"url": {Hosts: []string{"*.example.com"}}}, args: `{"url":"https://example.com.evil.org/"}`, want: false},
+		{name: "hosts userinfo attack", conditions: Conditions{"url": {Hosts: []string{"*.example.com"}}}, args: `{"url":"https://dl.example.com@evil.org/"}`, want: false},
+		{name: "hosts not a url", conditions: Conditions{"url": {Hosts: []string{"*"}}}, args: `{"url":"not a url"}`, want: false},
+		{name: "ports in range", conditions: Conditions{"port": {Ports: "22,8000-8999"}}, args: `{"port":8080}`, want: true},
+		{name: "ports single", conditions: Conditions{"port": {Ports: "22,8000-8999"}}, args: `{"port":22}`, want: true},
+		{name: "ports out of range", conditions: Conditions{"port": {Ports: "22,8000-8999"}}, args: `{"port":9000}`, want: false},
+		{name: "flags present", conditions: Conditions{"options": {Flags: []string{"-exec"}}}, args: `{"options":["-name","x","-exec"]}`, want: true},
+		{name: "flags with value", conditions: Conditions{"options": {Flags: []string{"--output"}}}, args: `{"options":["--output=/tmp/x"]}`, want: true},
+		{name: "flags absent", conditions: Conditions{"options": {Flags: []string{"-exec"}}}, args: `{"options":["-name","x"]}`, want: false},
+		{name: "flags prefix is not a flag", conditions: Conditions{"options": {Flags: []string{"-e"}}}, args: `{"options":["-exec"]}`, want: false},
+		{name: "all conditions must hold", conditions: Conditions{"format": {Equals: []string{"json"}}, "pid": {Equals: []string{"1"}}}, args: `{"format":"json","pid":2}`, want: false},
+		{name: "missing argument", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{}`, want: false},
+		{name: "null argument", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{"path":null}`, want: false},
+		{name: "empty arguments", conditions: Conditions{"path": {Under: []string{logs}}}, args: ``, want: false},
+		{name: "invalid json", conditions: Conditions{"path": {Under: []string{logs}}}, args: `{"path":`, want: false},
+		{name: "object value", conditions: Conditions{"path": {Equals: []string{"x"}}}, args: `{"path":{"a":1}}`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conditions.Match(tt.args); got != tt.want {
+				t.Errorf("Match(%s) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionsMatchAny(t *testing.T) {
+	conditions := Conditions{"paths": {Under: []string{"/etc"}}}
+	tests := []struct {
+		name string
+		args string
+		want bool
+	}{
+		{name: "one element matches", args: `{"paths":["/tmp","/etc/passwd"]}`, want: true},
+		{name: "no element matches", args: `{"paths":["/tmp","/var"]}`, want: false},
+		{name: "scalar", args: `{"paths":"/etc"}`, want: true},
+		{name: "empty array", args: `{"paths":[]}`, want: false},
+		{name: "missing argument", args: `{}`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conditions.MatchAny(tt.args); got != tt.want {
+				t.Errorf("MatchAny(%s) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		wantErr   bool
+	}{
+		{name: "empty", condition: Condition{}},
+		{name: "valid", condition: Condition{Matches: []string{"*.log"}, Hosts: []string{"*.example.com"}, Ports: "22, 80-443"}},
+		{name: "bad pattern", condition: Condition{Matches: []string{"[a-"}}, wantErr: true},
+		{name: "bad host pattern", condition: Condition{Hosts: []string{"[x"}}, wantErr: true},
+		{name: "bad port", condition: Condition{Ports: "http"}, wantErr: true},
+		{name: "reversed range", condition: Condition{Ports: "90-80"}, wantErr: true},
+		{name: "port too large", condition: Condition{Ports: "65536"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.condition.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// Effect 是一条工具规则匹配时的效果。
+type Effect string
+
+const (
+	EffectAllow Effect = "allow" // 允许匹配的调用
+	EffectDeny  Effect = "deny"  // 拒绝匹配的调用
+)
+
+// ToolRule 是一条按工具名称和参数匹配的访问规则，例如"wget 只能访问 *.example.com"。
+type ToolRule struct {
+	Name   string     // 规则名称，会出现在拒绝的原因中
+	Tool   string     // 工具名称，"*" 或为空表示所有工具
+	Effect Effect     // 规则匹配时的效果
+	Args   Conditions // 参数需要满足的约束，为空表示匹配该工具的所有调用
+}
+
+func (r ToolRule) appliesTo(tool string) bool {
+	return r.Tool == "" || r.Tool == "*" || r.Tool == tool
+}
+
+// RuleSet 在工具名称白名单和黑名单之上，按参数限制工具调用：
+//   - 任意一条 deny 规则匹配时，调用被拒绝。参数是数组时，只要有一个元素满足约束 deny 规则就匹配；
+//   - 一个工具存在 allow 规则时，调用至少需要匹配其中一条，否则被拒绝；
+//   - 没有规则涉及的工具不受限制。
+//
+// 规则按原样匹配传入的参数，缺少被约束的参数时规则不匹配。因此调用方应当先补上参数的缺省值、
+// 把路径规范化为绝对路径，使规则看到的正是工具实际访问的路径（参见 tools.ToolSet.Check），
+// 否则省略参数或使用相对路径就可以绕过 deny 规则。
+type RuleSet struct {
+	rules []ToolRule
+}
+
+// NewRuleSet 校验并创建一个规则集合。没有名称的规则按顺序命名为 "#1"、"#2" 等。
+func NewRuleSet(rules []ToolRule) (*RuleSet, error) {
+	rules = append([]ToolRule(nil), rules...)
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rules[i].Name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return nil, fmt.Errorf("tool rule %s: invalid effect %q, expected allow or deny", rules[i].Name, rule.Effect)
+		}
+		for arg, condition := range rule.Args {
+			if err := condition.Validate(); err != nil {
+				return nil, fmt.Errorf("tool rule %s, argument '%s': %w", rules[i].Name, arg, err)
+			}
+		}
+	}
+	return &RuleSet{rules: rules}, nil
+}
+
+// Check 检查一个工具调用是否被规则允许。被拒绝时返回的错误说明了是哪条规则做出的决定。
+func (s *RuleSet) Check(toolCall llm.ToolCall) error {
+	if s == nil {
+		return nil
+	}
+	name := toolCall.Function.Name
+	var allowRules []string
+	allowed := false
+	for _, rule := range s.rules {
+		if !rule.appliesTo(name) {
+			continue
+		}
+		switch rule.Effect {
+		case EffectDeny:
+			if rule.Args.MatchAny(toolCall.Function.Arguments) {
+				return fmt.Errorf("tool '%s' call denied by policy rule '%s'", name, rule.Name)
+			}
+		case EffectAllow:
+			allowRules = append(allowRules, rule.Name)
+			allowed = allowed || rule.Args.Match(toolCall.Function.Arguments)
+		}
+	}
+	if len(allowRules) > 0 && !allowed {
+		return fmt.Errorf("tool '%s' call is not permitted by any of the policy rules that restrict it (%s)",
+			name, strings.Join(quoteAll(allowRules), ", "))
+	}
+	return nil
+}
+
+func quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + name + "'"
+	}
+	return quoted
+}
@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+func toolCall(name, args string) llm.ToolCall {
+	return llm.ToolCall{ID: "call_1", Type: "function", Function: llm.FunctionCall{Name: name, Arguments: args}}
+}
+
+func TestRuleSetCheck(t *testing.T) {
+	rules, err := NewRuleSet([]ToolRule{
+		{Name: "trusted-hosts", Tool: "wget", Effect: EffectAllow, Args: Conditions{"url": {Hosts: []string{"*.example.com"}}}},
+		{Name: "no-internal", Tool: "wget", Effect: EffectDeny, Args: Conditions{"url": {Hosts: []string{"internal.example.com"}}}},
+		{Name: "no-etc", Tool: "*", Effect: EffectDeny, Args: Conditions{"path": {Under: []string{"/etc"}}}},
+		{Tool: "lsof", Effect: EffectDeny, Args: Conditions{"port": {Ports: "1-1023"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		call    llm.ToolCall
+		wantErr string // 为空表示允许
+	}{
+		{name: "allowed host", call: toolCall("wget", `{"url":"https://dl.example.com/a"}`)},
+		{name: "host not in allow rules", call: toolCall("wget", `{"url":"https://evil.org/a"}`), wantErr: "not permitted by any of the policy rules that restrict it ('trusted-hosts')"},
+		{name: "deny wins over allow", call: toolCall("wget", `{"url":"https://internal.example.com/a"}`), wantErr: "denied by policy rule 'no-internal'"},
+		{name: "wildcard deny", call: toolCall("find", `{"path":"/etc/ssh"}`), wantErr: "denied by policy rule 'no-etc'"},
+		{name: "wildcard deny does not match other paths", call: toolCall("find", `{"path":"/var/log"}`)},
+		{name: "deny matches one array element", call: toolCall("grep", `{"path":["/var/log","/etc/shadow"]}`), wantErr: "denied by policy rule 'no-etc'"},
+		{name: "unnamed rule", call: toolCall("lsof", `{"port":22}`), wantErr: "denied by policy rule '#4'"},
+		{name: "unnamed rule does not match", call: toolCall("lsof", `{"port":8080}`)},
+		{name: "missing argument does not match", call: toolCall("lsof", `{"pid":1}`)},
+		{name: "tool without rules", call: toolCall("ps", `{}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rules.Check(tt.call)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check() = %v, want allowed", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Check() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleSetNil(t *testing.T) {
+	var rules *RuleSet
+	if err := rules.Check(toolCall("wget", `{}`)); err != nil {
+		t.Errorf("nil RuleSet rejected a call: %v", err)
+	}
+}
+
+func TestNewRuleSetRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ToolRule
+		wantErr string
+	}{
+		{name: "effect", rule: ToolRule{Name: "r", Effect: "block"}, wantErr: `tool rule r: invalid effect "block"`},
+		{name: "missing effect", rule: ToolRule{}, wantErr: `tool rule #1: invalid effect ""`},
+		{name: "condition", rule: ToolRule{Name: "r", Effect: EffectDeny, Args: Conditions{"port": {Ports: "x"}}}, wantErr: "tool rule r, argument 'port'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRuleSet([]ToolRule{tt.rule})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewRuleSet() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Sandbox 限制工具可以读取和写入的文件系统路径。
+// 路径在检查前会被转换为绝对路径并解析 ".." 和符号链接，因此参数中的符号链接无法绕过限制。
+// Sandbox 只检查工具参数中的路径：遍历目录的工具需要自己用 Resolve 检查遍历中遇到的符号链接，
+// 检查之后才被替换为符号链接的路径也不在防护范围之内。
+type Sandbox struct {
+	readRoots  []string
+	writeRoots []string
+}
+
+// NewSandbox 创建一个只允许读取 readRoots、只允许写入 writeRoots 之内路径的沙箱。
+// readRoots 或 writeRoots 为空时不限制对应的访问。
+func NewSandbox(readRoots, writeRoots []string) *Sandbox {
+	return &Sandbox{readRoots: canonicalRoots(readRoots), writeRoots: canonicalRoots(writeRoots)}
+}
+
+// JoinPath 以 dir 为基准拼接相对路径 p，dir 为空或 p 是绝对路径时原样返回 p。
+func JoinPath(dir, p string) string {
+	if !filepath.IsAbs(p) && dir != "" {
+		return filepath.Join(dir, p)
+	}
+	return p
+}
+
+func canonicalRoots(roots []string) []string {
+	canonical := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if root != "" {
+			canonical = append(canonical, CanonicalPath(root))
+		}
+	}
+	return canonical
+}
+
+// Resolve 将路径规范化，并检查它是否位于允许读取（write 为 false）或写入（write 为 true）的目录之内。
+// 相对路径以 dir 为基准，dir 为空时以当前目录为基准。返回规范化后的绝对路径。
+func (s *Sandbox) Resolve(p, dir string, write bool) (string, error) {
+	p = JoinPath(dir, p)
+	resolved := CanonicalPath(p)
+	roots, access := s.readRoots, "read"
+	if write {
+		roots, access = s.writeRoots, "write"
+	}
+	if len(roots) == 0 || slices.ContainsFunc(roots, func(root string) bool { return IsWithin(root, resolved) }) {
+		return resolved, nil
+	}
+	return "", fmt.Errorf("path '%s' (resolved to '%s') is outside the sandbox; allowed %s roots: %s",
+		p, resolved, access, strings.Join(roots, ", "))
+}
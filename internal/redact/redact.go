@@ -0,0 +1,162 @@
+package redact
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// placeholderPrefix 是替换后的文本的前缀，完整形式为 "[REDACTED:<kind>]"。
+const placeholderPrefix = "[REDACTED"
+
+// Pattern 是一个识别敏感信息的正则表达式。
+// 表达式中有名为 secret 的分组时只替换该分组，例如保留 "--password=" 而只替换其后的值；否则替换整个匹配。
+type Pattern struct {
+	Name  string // 敏感信息的种类，会出现在替换后的文本和报告中
+	Regex string
+}
+
+// BuiltinPatterns 是内置的检测规则：常见的 API Key 格式、AWS 密钥、JWT、私钥以及命令行和配置文件中的密码参数。
+var BuiltinPatterns = []Pattern{
+	{"private-key", `(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----.*?(-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----|\z)`},
+	// 只剩下结尾的私钥，例如 grep 的上下文或分页读取的输出从私钥中间开始：替换 END 行及其之前连续的 base64 行
+	{"private-key", `(?m)(^\S*?[A-Za-z0-9+/]{16,}={0,2}\r?\n)*.*-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`},
+	{"aws-access-key", `\b(AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`},
+	{"aws-secret-key", `(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`},
+	{"jwt", `\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`},
+	{"api-key", `\b(sk-[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|glpat-[A-Za-z0-9_-]{20,}|xox[baprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})`},
+	{"bearer-token", `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{16,}=*)`},
+	// 键名必须是完整的单词：可以带有 "DB_" 这样的前缀，但 PWD、next_token 或 password_file 不算密码参数。
+	// token 只有单独出现或带有 access、auth 等前缀时才算，分页用的 next_token、page_token 不会被替换
+	{"password", `(?i)\b((?:[a-z0-9]+[_-])*(?:[a-z0-9]*passw(?:or)?d|secret|api[_-]?key|access[_-]?key|client[_-]?secret|(?:access|auth|api|refresh|session|id|private)[_-]?token)|token)\b["']?\s*[=:]\s*(?P<secret>"[^"\n]*"|'[^'\n]*'|[^\s"',;&]+)`},
+	{"password", `(?i)(^|\s)--?(password|passwd|pass)\s+(?P<secret>[^\s-]\S*)`},
+}
+
+type detector struct {
+	name  string
+	re    *regexp.Regexp
+	group int // secret 分组的序号，0 表示替换整个匹配
+}
+
+// Redactor 在工具输出被发送给 LLM 之前，把其中的敏感信息替换为 "[REDACTED:<kind>]"。
+// 它可以在多个 goroutine 中安全使用。
+type Redactor struct {
+	detectors []detector
+
+	mu    sync.Mutex
+	total Report // 累计的替换次数
+}
+
+// New 创建一个使用内置规则和 patterns 中自定义规则的 Redactor。
+func New(patterns []Pattern) (*Redactor, error) {
+	r := &Redactor{total: Report{}}
+	for _, p := range append(slices.Clone(BuiltinPatterns), patterns...) {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p.Name, err)
+		}
+		name := p.Name
+		if name == "" {
+			name = "custom"
+		}
+		r.detectors = append(r.detectors, detector{name: name, re: re, group: max(re.SubexpIndex("secret"), 0)})
+	}
+	return r, nil
+}
+
+// Redact 替换 text 中的敏感信息，返回替换后的文本和每种敏感信息被替换的次数。
+// r 为 nil 时原样返回。
+func (r *Redactor) Redact(text string) (string, Report) {
+	if r == nil || text == "" {
+		return text, nil
+	}
+	var report Report
+	for _, d := range r.detectors {
+		text = d.replace(text, func() {
+			if report == nil {
+				report = Report{}
+			}
+			report[d.name]++
+		})
+	}
+	if report != nil {
+		r.mu.Lock()
+		for kind, n := range report {
+			r.total[kind] += n
+		}
+		r.mu.Unlock()
+	}
+	return text, report
+}
+
+// Total 返回这个 Redactor 累计的替换次数。
+func (r *Redactor) Total() Report {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.total)
+}
+
+// replace 替换 text 中所有匹配 d 的敏感信息，每替换一处调用一次 count。已经被替换过的内容不会重复计数。
+func (d detector) replace(text string, count func()) string {
+	matches := d.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*d.group], m[2*d.group+1]
+		if start < 0 || start == end || strings.HasPrefix(text[start:end], placeholderPrefix) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(placeholderPrefix + ":" + d.name + "]")
+		last = end
+		count()
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Report 记录每种敏感信息被替换的次数。
+type Report map[string]int
+
+// Total 返回替换的总次数。
+func (r Report) Total() int {
+	total := 0
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+// Merge 返回 r 与 other 合计的替换次数，不修改 r。
+func (r Report) Merge(other Report) Report {
+	if len(other) == 0 {
+		return r
+	}
+	merged := maps.Clone(r)
+	if merged == nil {
+		merged = Report{}
+	}
+	for kind, n := range other {
+		merged[kind] += n
+	}
+	return merged
+}
+
+// String 返回形如 "3 (api-key: 1, password: 2)" 的说明。
+func (r Report) String() string {
+	kinds := slices.Sorted(maps.Keys(r))
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s: %d", kind, r[kind])
+	}
+	return fmt.Sprintf("%d (%s)", r.Total(), strings.Join(parts, ", "))
+}
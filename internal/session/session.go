@@ -0,0 +1,291 @@
+package session
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// ErrNotFound 表示指定的会话不存在。
+var ErrNotFound = errors.New("session not found")
+
+// ErrInvalidID 表示会话 ID 不是 newID 生成的格式，例如包含路径分隔符。
+var ErrInvalidID = errors.New("invalid session id")
+
+// idPattern 匹配 newID 生成的会话 ID。
+var idPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[0-9a-f]{6}$`)
+
+// maxTitleLength 是根据首条用户消息生成的会话标题的最大字符数。
+const maxTitleLength = 60
+
+// 会话文件中的记录类型。
+const (
+	recordMeta       = "meta"
+	recordTitle      = "title"
+	recordMessage    = "message"
+	recordCompaction = "compaction"
+)
+
+// record 是会话 JSONL 文件中的一行。文件只追加不修改，按顺序重放即可恢复历史记录。
+type record struct {
+	Type       string              `json:"type"`
+	Time       time.Time           `json:"time"`
+	Meta       *Info               `json:"meta,omitempty"`
+	Title      string              `json:"title,omitempty"`
+	Message    *llm.Message        `json:"message,omitempty"`
+	Compaction *history.Compaction `json:"compaction,omitempty"`
+}
+
+// Info 描述一个会话。
+type Info struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"-"` // 由文件的修改时间得出
+	Messages  int       `json:"-"` // 由文件内容统计得出
+}
+
+// Store 管理保存在某个目录下的所有会话，每个会话对应一个 <id>.jsonl 文件。
+type Store struct {
+	dir string
+}
+
+// NewStore 创建一个将会话保存在 dir 目录下的 Store。目录会在首次写入时创建。
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir 返回会话所在的目录。
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// path 返回会话文件的路径。id 必须是 newID 生成的格式，避免 "../x" 之类的 ID 访问会话目录以外的文件。
+func (s *Store) path(id string) (string, error) {
+	if !idPattern.MatchString(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return filepath.Join(s.dir, id+".jsonl"), nil
+}
+
+// Create 创建一个新会话，并返回用于追加记录的 Writer。
+func (s *Store) Create(model string) (*Writer, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating session directory: %w", err)
+	}
+	info := Info{ID: newID(), Model: model, CreatedAt: time.Now()}
+	w, err := s.open(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.write(record{Type: recordMeta, Meta: &info}); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Resume 读取一个已有会话，将其历史记录重放到 hist 中，并返回继续向该会话追加记录的 Writer。
+func (s *Store) Resume(id string, hist *history.HistoryManager) (*Writer, error) {
+	info, err := s.load(id, hist)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(*info)
+}
+
+// Latest 返回最近更新的会话，已损坏的会话会被跳过。没有任何会话时返回 ErrNotFound。
+func (s *Store) Latest() (*Info, error) {
+	sessions, _, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return &sessions[0], nil
+}
+
+// List 返回所有会话，按最近更新时间倒序排列。
+// 无法读取的会话文件不会导致整个列表失败，而是被跳过，对应的错误通过 corrupt 返回。
+func (s *Store) List() (sessions []Info, corrupt []error, err error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading session directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || entry.IsDir() || !idPattern.MatchString(id) {
+			continue
+		}
+		info, err := s.load(id, nil)
+		if err != nil {
+			corrupt = append(corrupt, err)
+			continue
+		}
+		sessions = append(sessions, *info)
+	}
+	slices.SortFunc(sessions, func(a, b Info) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return sessions, corrupt, nil
+}
+
+// load 读取会话文件。hist 不为 nil 时，会把消息和压缩记录依次重放到其中。
+func (s *Store) load(id string, hist *history.HistoryManager) (*Info, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening session %s: %w", id, err)
+	}
+	defer f.Close()
+
+	info := &Info{ID: id}
+	scanner := bufio.NewScanner(f)
+	// 工具输出可能很长，放宽单行长度限制
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("error decoding session %s line %d: %w", id, line, err)
+		}
+		switch rec.Type {
+		case recordMeta:
+			if rec.Meta != nil {
+				info.Model = rec.Meta.Model
+				info.CreatedAt = rec.Meta.CreatedAt
+			}
+		case recordTitle:
+			info.Title = rec.Title
+		case recordMessage:
+			if rec.Message == nil {
+				continue
+			}
+			info.Messages++
+			if hist != nil {
+				hist.Messages = append(hist.Messages, *rec.Message)
+			}
+		case recordCompaction:
+			if rec.Compaction != nil && hist != nil {
+				hist.ReplayCompaction(*rec.Compaction)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading session %s: %w", id, err)
+	}
+
+	if stat, err := f.Stat(); err == nil {
+		info.UpdatedAt = stat.ModTime()
+	}
+	return info, nil
+}
+
+// open 以追加模式打开会话文件。
+func (s *Store) open(info Info) (*Writer, error) {
+	path, err := s.path(info.ID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening session %s: %w", info.ID, err)
+	}
+	return &Writer{info: info, file: f, encoder: json.NewEncoder(f)}, nil
+}
+
+// Writer 将历史记录的变化追加写入会话文件，实现了 history.Recorder 接口。
+type Writer struct {
+	mu      sync.Mutex
+	info    Info
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// Info 返回会话的基本信息。
+func (w *Writer) Info() Info {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.info
+}
+
+// RecordMessage 追加一条消息。会话还没有标题时，使用首条用户消息作为标题。
+func (w *Writer) RecordMessage(message llm.Message) error {
+	if err := w.write(record{Type: recordMessage, Message: &message}); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	needsTitle := w.info.Title == "" && message.Role == "user" && message.Content != nil
+	if needsTitle {
+		w.info.Title = makeTitle(*message.Content)
+	}
+	title := w.info.Title
+	w.mu.Unlock()
+
+	if needsTitle {
+		return w.write(record{Type: recordTitle, Title: title})
+	}
+	return nil
+}
+
+// RecordCompaction 追加一条压缩记录，重放时据此把被摘要替换的消息折叠起来。
+func (w *Writer) RecordCompaction(compaction history.Compaction) error {
+	return w.write(record{Type: recordCompaction, Compaction: &compaction})
+}
+
+// Close 关闭会话文件。
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+func (w *Writer) write(rec record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rec.Time = time.Now()
+	// json.Encoder 每次写入一行，并以单次 write 调用写入，进程异常退出时不会留下半行记录
+	if err := w.encoder.Encode(rec); err != nil {
+		return fmt.Errorf("error writing session %s: %w", w.info.ID, err)
+	}
+	return nil
+}
+
+// newID 生成一个按时间排序的会话 ID，例如 20250102-150405-1a2b3c。
+func newID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// makeTitle 将用户消息压缩为单行标题。
+func makeTitle(content string) string {
+	title := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength]) + "…"
+	}
+	return title
+}
@@ -0,0 +1,186 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/history"
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// fixedSummarizer 总是返回同一段摘要。
+type fixedSummarizer string
+
+func (s fixedSummarizer) Summarize(ctx context.Context, messages []llm.Message) (string, error) {
+	return string(s), nil
+}
+
+// newSession 创建一个会话，并通过 HistoryManager 写入一段包含工具调用的对话。
+func newSession(t *testing.T, store *Store) (*history.HistoryManager, Info) {
+	t.Helper()
+	writer, err := store.Create("test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	h := history.NewHistoryManager()
+	h.SetRecorder(writer)
+	h.AddSystemMessage("system prompt")
+	h.AddUserMessage("  which process\n listens on 8080? ")
+	h.AddAssistantMessage(llm.Message{ToolCalls: []llm.ToolCall{
+		{ID: "call_1", Type: "function", Function: llm.FunctionCall{Name: "ss", Arguments: `{"port":8080}`}},
+	}})
+	h.AddToolObservation("call_1", "LISTEN 0 128 *:8080 users:((\"nginx\",pid=42))")
+	answer := "nginx (pid 42)"
+	h.AddAssistantMessage(llm.Message{Content: &answer})
+	if err := h.RecordErr(); err != nil {
+		t.Fatal(err)
+	}
+	return h, writer.Info()
+}
+
+func TestResumeRoundTrip(t *testing.T) {
+	store := NewStore(t.TempDir())
+	h, info := newSession(t, store)
+	if info.Title != "which process listens on 8080?" {
+		t.Errorf("title = %q, want the first user message on one line", info.Title)
+	}
+
+	resumed := history.NewHistoryManager()
+	writer, err := store.Resume(info.ID, resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if !reflect.DeepEqual(resumed.Messages, h.Messages) {
+		t.Errorf("resumed messages = %+v, want %+v", resumed.Messages, h.Messages)
+	}
+	if got := writer.Info(); got.Model != "test-model" || got.Title != info.Title || got.Messages != len(h.Messages) {
+		t.Errorf("resumed info = %+v", got)
+	}
+}
+
+func TestResumeReplaysCompaction(t *testing.T) {
+	store := NewStore(t.TempDir())
+	writer, err := store.Create("test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := history.NewHistoryManager()
+	h.SetRecorder(writer)
+	h.AddSystemMessage("system prompt")
+	for _, text := range []string{"first", "second", "third"} {
+		h.AddUserMessage(text + " question")
+		h.AddAssistantMessage(llm.Message{Content: &text})
+	}
+	if _, err := h.Compact(context.Background(), fixedSummarizer("earlier questions answered"), 2); err != nil {
+		t.Fatal(err)
+	}
+	// 压缩之后继续对话，恢复时这些消息必须位于摘要之后
+	h.AddUserMessage("fourth question")
+	writer.Close()
+
+	resumed := history.NewHistoryManager()
+	writer, err = store.Resume(writer.Info().ID, resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if !reflect.DeepEqual(resumed.Messages, h.Messages) {
+		t.Errorf("resumed messages = %+v, want %+v", resumed.Messages, h.Messages)
+	}
+	if len(resumed.Compactions) != 1 || resumed.Compactions[0].Summary != "earlier questions answered" {
+		t.Errorf("resumed compactions = %+v, want the recorded compaction", resumed.Compactions)
+	}
+}
+
+func TestListAndLatest(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	if _, err := store.Latest(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Latest() on an empty store = %v, want ErrNotFound", err)
+	}
+
+	_, older := newSession(t, store)
+	_, newer := newSession(t, store)
+	now := time.Now()
+	setModTime(t, store, older.ID, now.Add(-time.Hour))
+	setModTime(t, store, newer.ID, now.Add(-time.Minute))
+
+	// 最近修改的文件已损坏，不能影响列表和 --continue
+	corruptID := "20990101-000000-abcdef"
+	if err := os.WriteFile(filepath.Join(dir, corruptID+".jsonl"), []byte("{not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// 名称不是会话 ID 的文件被忽略
+	if err := os.WriteFile(filepath.Join(dir, "notes.jsonl"), []byte("{not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, corrupt, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != newer.ID || sessions[1].ID != older.ID {
+		t.Errorf("List() = %+v, want the newer session first", sessions)
+	}
+	if sessions[0].Messages != 5 {
+		t.Errorf("List() messages = %d, want 5", sessions[0].Messages)
+	}
+	if len(corrupt) != 1 {
+		t.Errorf("List() corrupt = %v, want one error", corrupt)
+	}
+
+	latest, err := store.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.ID != newer.ID {
+		t.Errorf("Latest() = %s, want %s", latest.ID, newer.ID)
+	}
+}
+
+func setModTime(t *testing.T, store *Store, id string, modTime time.Time) {
+	t.Helper()
+	path, err := store.path(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResumeRejectsInvalidIDs(t *testing.T) {
+	base := t.TempDir()
+	store := NewStore(filepath.Join(base, "sessions"))
+	// 会话目录之外的合法 JSONL 文件不能通过 ID 读取
+	if err := os.WriteFile(filepath.Join(base, "outside.jsonl"), []byte(`{"type":"meta"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{
+		"../outside",
+		"20250102-150405-1a2b3c/../../outside",
+		"/etc/passwd",
+		"20250102-150405-1A2B3C",
+		"20250102-150405-1a2b3c.jsonl",
+		"",
+	} {
+		if _, err := store.Resume(id, history.NewHistoryManager()); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Resume(%q) = %v, want ErrInvalidID", id, err)
+		}
+	}
+	if _, err := store.Resume("20250102-150405-1a2b3c", history.NewHistoryManager()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resume() of a missing session = %v, want ErrNotFound", err)
+	}
+	if id := newID(); !idPattern.MatchString(id) {
+		t.Errorf("newID() = %q does not match idPattern", id)
+	}
+}
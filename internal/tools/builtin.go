@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// init 将所有内置工具注册到默认注册表中。
+// 与操作系统相关的实现位于 *_<os>.go 和 tools_<os>.go 中。
+func init() {
+	Register(builtinTool(psDefinition, executePs))
+	Register(builtinTool(findDefinition, executeFind, PathArgument{Name: "path", Default: "."}))
+	Register(builtinTool(grepDefinition, executeGrep, PathArgument{Name: "paths", Default: "."}))
+	Register(NewWgetTool("", DefaultMaxDownloadBytes))
+	Register(builtinTool(ssDefinition, executeSs))
+	Register(builtinTool(lsofDefinition, executeLsof, PathArgument{Name: "path"}))
+	Register(NewReadOutputTool(DefaultRegistry))
+}
+
+// builtinTool 将一个返回纯文本输出的内置执行函数包装为 Tool，paths 是参数中需要受沙箱限制的路径。
+// 通过它注册的内置工具都只读取系统信息，因此都是只读工具。
+func builtinTool(definition llm.Tool, execute func(ctx context.Context, rawArgs json.RawMessage) (string, error), paths ...PathArgument) Tool {
+	return &funcTool{
+		definition: definition,
+		execute: func(ctx context.Context, args json.RawMessage) (Result, error) {
+			output, err := execute(ctx, args)
+			return Result{Output: output}, err
+		},
+		readOnly: true,
+		paths:    paths,
+	}
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commandWaitDelay 是命令被取消后等待其输出管道关闭的最长时间。
+// 即使有孙进程继承了管道，也不会让 Agent 永远阻塞。
+const commandWaitDelay = 2 * time.Second
+
+// commandContext 创建一个与 ctx 绑定的命令。
+// 命令运行在独立的进程组中：ctx 被取消或超时时，整个进程组（包括它派生的子进程）都会被杀死，
+// 同时终端上的 Ctrl-C 也不会直接传递给它，而是由 Agent 通过 ctx 统一处理。
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+	if log, ok := ctx.Value(commandLogKey{}).(*commandLog); ok {
+		log.add(cmd)
+	}
+	return cmd
+}
+
+type commandLogKey struct{}
+
+// commandLog 记录一次工具执行中通过 commandContext 启动的外部命令，
+// 用于报告工具实际执行的命令行和退出码。
+type commandLog struct {
+	mu   sync.Mutex
+	cmds []*exec.Cmd
+}
+
+// withCommandLog 返回一个会把启动的外部命令记录到返回的 commandLog 中的 ctx。
+func withCommandLog(ctx context.Context) (context.Context, *commandLog) {
+	log := &commandLog{}
+	return context.WithValue(ctx, commandLogKey{}, log), log
+}
+
+func (l *commandLog) add(cmd *exec.Cmd) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cmds = append(l.cmds, cmd)
+}
+
+// summary 在工具执行结束后汇总命令行和退出码。
+// 没有启动外部命令的工具使用工具名称和（规范化后的）参数作为命令行，出错时退出码为 1；
+// 否则使用各个命令的命令行，退出码取第一个失败的命令，被信号杀死或未能启动的命令为 -1。
+func (l *commandLog) summary(tool string, args []byte, err error) (string, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	exitCode := 0
+	if len(l.cmds) == 0 {
+		if err != nil {
+			exitCode = 1
+		}
+		return tool + " " + string(args), exitCode
+	}
+
+	lines := make([]string, len(l.cmds))
+	for i, cmd := range l.cmds {
+		lines[i] = cmd.String()
+		code := -1
+		if cmd.ProcessState != nil {
+			code = cmd.ProcessState.ExitCode()
+		}
+		if exitCode == 0 && code != 0 {
+			exitCode = code
+		}
+	}
+	if exitCode == 0 && err != nil {
+		exitCode = 1
+	}
+	return strings.Join(lines, "; "), exitCode
+}
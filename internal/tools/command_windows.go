@@ -0,0 +1,14 @@
+//go:build windows
+
+package tools
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让命令在新的进程组中运行，使控制台的 Ctrl-C 不会直接传递给它。
+// Windows 上取消时沿用 exec 包的默认行为，即杀死该进程。
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
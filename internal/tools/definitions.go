@@ -4,179 +4,345 @@ import (
 	"github.com/DoraZa/mini-agent/internal/llm"
 )
 
-// GetToolDefinitions 返回所有可用工具的定义。
+// 内置工具的定义。
 // 这些定义严格遵循 PRD 文档中的 JSON Schema，以确保 LLM 能够理解和正确使用。
-func GetToolDefinitions() []llm.Tool {
-	return []llm.Tool{
-		{
-			Type: "function",
-			Function: llm.Function{
-				Name:        "ps",
-				Description: "列出当前运行的进程。可以根据用户、进程名或 PID 过滤，类似 'ps' 命令。",
-				Parameters: map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"user": map[string]any{
-							"type":        "string",
-							"description": "按用户名过滤进程。",
-						},
-						"name": map[string]any{
-							"type":        "string",
-							"description": "按进程名过滤进程。",
-						},
-						"pid": map[string]any{
-							"type":        "string",
-							"description": "按进程 ID 过滤进程。",
-						},
-						"options": map[string]any{
-							"type":        "array",
-							"items":       map[string]any{"type": "string"},
-							"description": "ps 命令的额外选项，例如 '-ef' 或 '-aux'。",
-						},
-					},
-					"required": []string{},
+
+// psDefinition 定义了 ps 工具：列出当前运行的进程。
+var psDefinition = llm.Tool{
+	Type: "function",
+	Function: llm.Function{
+		Name:        "ps",
+		Description: "列出当前运行的进程，返回 PID、父 PID、用户、状态、CPU 占用、常驻内存和命令行。可以按用户、进程名、PID、父 PID、最低 CPU/内存占用过滤，并排序和限制数量。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"user": map[string]any{
+					"type":        "string",
+					"description": "按用户名过滤进程。",
+				},
+				"name": map[string]any{
+					"type":        "string",
+					"description": "按进程名或命令行过滤进程，支持正则表达式，例如 'nginx|httpd'。",
+				},
+				"pid": map[string]any{
+					"type":        "integer",
+					"description": "按进程 ID 过滤进程。",
+				},
+				"ppid": map[string]any{
+					"type":        "integer",
+					"description": "按父进程 ID 过滤，用于列出某个进程的子进程；0 表示没有父进程的进程，例如 init 和内核线程的根 kthreadd。",
+				},
+				"min_cpu": map[string]any{
+					"type":        "number",
+					"description": "只返回 CPU 占用（百分比）不低于该值的进程。",
+				},
+				"min_rss_mb": map[string]any{
+					"type":        "integer",
+					"description": "只返回常驻内存（MB）不低于该值的进程。",
+				},
+				"sort": map[string]any{
+					"type":        "string",
+					"enum":        []string{"pid", "cpu", "rss", "name"},
+					"description": "排序字段，cpu 和 rss 按从高到低排序，默认按 pid 排序。",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "最多返回的进程数，默认为 100。",
+				},
+				"format": map[string]any{
+					"type":        "string",
+					"enum":        []string{"table", "json"},
+					"description": "输出格式，默认为紧凑的表格。",
 				},
 			},
+			"required": []string{},
 		},
-		{
-			Type: "function",
-			Function: llm.Function{
-				Name:        "find",
-				Description: "在文件系统中查找文件或目录。返回所有匹配项的路径。当不指定路径时，默认在当前目录及子目录中查找。",
-				Parameters: map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"path": map[string]any{
-							"type":        "string",
-							"description": "查找的起始路径，例如 '.' (当前目录) 或 '/home/user/'。如果未指定，默认在当前目录递归查找。",
-						},
-						"name": map[string]any{
-							"type":        "string",
-							"description": "要查找的文件或目录的名称，支持通配符。",
-						},
-						"type": map[string]any{
-							"type":        "string",
-							"description": "查找类型，'f' 表示文件，'d' 表示目录。",
-							"enum":        []string{"f", "d"},
-						},
-						"maxdepth": map[string]any{
-							"type":        "integer",
-							"description": "查找的最大深度，例如 1 表示只在当前目录查找，不进入子目录。",
-						},
-					},
-					"required": []string{"name"},
+	},
+}
+
+// findDefinition 定义了 find 工具：在文件系统中查找文件或目录。
+var findDefinition = llm.Tool{
+	Type: "function",
+	Function: llm.Function{
+		Name:        "find",
+		Description: "在文件系统中查找文件或目录。可以按名称、类型、大小、修改时间过滤，排除指定目录，并按大小或修改时间排序。当不指定路径时，默认在当前目录及子目录中查找。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "查找的起始路径，例如 '.' (当前目录) 或 '/home/user/'。如果未指定，默认在当前目录递归查找。",
+				},
+				"name": map[string]any{
+					"type":        "string",
+					"description": "要查找的文件或目录的名称，支持通配符，例如 '*.log'。",
+				},
+				"type": map[string]any{
+					"type":        "string",
+					"description": "查找类型，'f' 表示文件，'d' 表示目录，'l' 表示符号链接。",
+					"enum":        []string{"f", "d", "l"},
+				},
+				"maxdepth": map[string]any{
+					"type":        "integer",
+					"description": "查找的最大深度，例如 1 表示只在当前目录查找，不进入子目录。",
+				},
+				"min_size": map[string]any{
+					"type":        "string",
+					"description": "只返回不小于该大小的文件，例如 '100M'、'1G'。",
+				},
+				"max_size": map[string]any{
+					"type":        "string",
+					"description": "只返回不大于该大小的文件，例如 '10k'。",
+				},
+				"modified_after": map[string]any{
+					"type":        "string",
+					"description": "只返回在该时间之后修改过的条目。可以是相对时间，例如 '24h'、'7d'（最近 7 天内），也可以是日期，例如 '2024-01-31'。",
+				},
+				"modified_before": map[string]any{
+					"type":        "string",
+					"description": "只返回在该时间之前修改的条目，格式同 modified_after，例如 '30d' 表示 30 天前。",
+				},
+				"exclude": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "要跳过的文件或目录名称（支持通配符），例如 ['.git', 'node_modules']。",
+				},
+				"max_results": map[string]any{
+					"type":        "integer",
+					"description": "最多返回的结果数，默认为 500。",
+				},
+				"follow_symlinks": map[string]any{
+					"type":        "boolean",
+					"description": "是否进入符号链接指向的目录，默认为 false。",
+				},
+				"sort": map[string]any{
+					"type":        "string",
+					"enum":        []string{"path", "size", "mtime"},
+					"description": "排序方式：size 按大小从大到小，mtime 按修改时间从新到旧，默认按遍历顺序。",
 				},
 			},
+			"required": []string{},
 		},
-		{
-			Type: "function",
-			Function: llm.Function{
-				Name:        "grep",
-				Description: "在文件中搜索匹配指定模式的行。返回所有匹配的行。",
-				Parameters: map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"pattern": map[string]any{
-							"type":        "string",
-							"description": "要搜索的正则表达式或字符串模式。",
-						},
-						"file": map[string]any{
-							"type":        "string",
-							"description": "要搜索的文件名或路径。如果是多个文件，可以是以空格分隔的字符串。",
-						},
-						"recursive": map[string]any{
-							"type":        "boolean",
-							"description": "如果为true，递归搜索目录下的文件，相当于 grep -r。",
-						},
-						"ignore_case": map[string]any{
-							"type":        "boolean",
-							"description": "如果为true，忽略大小写，相当于 grep -i。",
-						},
-						"count_only": map[string]any{
-							"type":        "boolean",
-							"description": "如果为true，只返回匹配行的数量，相当于 grep -c。",
-						},
-					},
-					"required": []string{"pattern", "file"},
+	},
+}
+
+// grepDefinition 定义了 grep 工具：在文件中搜索匹配指定模式的行。
+var grepDefinition = llm.Tool{
+	Type: "function",
+	Function: llm.Function{
+		Name:        "grep",
+		Description: "在文件中搜索匹配指定模式的行，以 'file:line:text' 的格式返回匹配行并汇总匹配数量。目录会被递归搜索，二进制文件和过大的文件会被跳过。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"pattern": map[string]any{
+					"type":        "string",
+					"description": "要搜索的正则表达式（Go 语法），或配合 fixed_strings 使用的普通字符串。",
+				},
+				"paths": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "要搜索的文件或目录列表，目录会被递归搜索。默认为当前目录。",
+				},
+				"include": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "递归搜索时只搜索文件名匹配这些通配符的文件，例如 ['*.go', '*.yaml']。",
+				},
+				"exclude": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "递归搜索时跳过名称匹配这些通配符的文件或目录，例如 ['node_modules', '*.min.js']。",
+				},
+				"ignore_case": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，忽略大小写，相当于 grep -i。",
+				},
+				"fixed_strings": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，将 pattern 作为普通字符串而不是正则表达式，相当于 grep -F。",
+				},
+				"before_context": map[string]any{
+					"type":        "integer",
+					"description": "同时输出每个匹配行之前的若干行，相当于 grep -B。",
+				},
+				"after_context": map[string]any{
+					"type":        "integer",
+					"description": "同时输出每个匹配行之后的若干行，相当于 grep -A。",
+				},
+				"max_count": map[string]any{
+					"type":        "integer",
+					"description": "每个文件最多返回的匹配行数，相当于 grep -m。",
+				},
+				"count_only": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，只返回每个文件匹配行的数量，相当于 grep -c。",
+				},
+				"max_file_size": map[string]any{
+					"type":        "string",
+					"description": "跳过大于该大小的文件，默认为 '10M'。",
+				},
+				"gitignore": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，跳过被搜索目录中 .gitignore 忽略的文件。",
+				},
+			},
+			"required": []string{"pattern"},
+		},
+	},
+}
+
+// wgetDefinition 定义了 wget 工具：通过 HTTP 下载文件。
+var wgetDefinition = llm.Tool{
+	Type: "function",
+	Function: llm.Function{
+		Name:        "wget",
+		Description: "通过 HTTP/HTTPS 下载文件到下载目录，返回保存路径、大小、内容类型和 SHA-256。可以先用 head_only 查看 URL 的响应头（大小、类型）再决定是否下载。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url": map[string]any{
+					"type":        "string",
+					"description": "要下载文件的 URL。",
+				},
+				"output_file": map[string]any{
+					"type":        "string",
+					"description": "可选。下载后文件的保存名称或下载目录内的相对路径，默认取 URL 中的文件名。",
+				},
+				"head_only": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，只发送 HEAD 请求并返回状态、内容类型和大小，不下载文件。",
+				},
+				"overwrite": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，允许覆盖已存在的文件，默认拒绝覆盖。",
 				},
 			},
+			"required": []string{"url"},
 		},
-		{
-			Type: "function",
-			Function: llm.Function{
-				Name:        "wget",
-				Description: "从互联网下载文件。将文件保存到当前目录或指定路径。",
-				Parameters: map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"url": map[string]any{
-							"type":        "string",
-							"description": "要下载文件的 URL。",
-						},
-						"output_file": map[string]any{
-							"type":        "string",
-							"description": "可选。下载后文件的保存名称或路径。",
-						},
-					},
-					"required": []string{"url"},
+	},
+}
+
+// ssDefinition 定义了 ss 工具：查看网络套接字。
+var ssDefinition = llm.Tool{
+	Type: "function",
+	Function: llm.Function{
+		Name:        "ss",
+		Description: "显示网络套接字（TCP/UDP 连接和监听端口）及其所属进程。可以按协议、状态、端口、进程过滤，或只显示监听中的套接字。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"protocol": map[string]any{
+					"type":        "string",
+					"enum":        []string{"tcp", "udp", "tcp4", "tcp6", "udp4", "udp6"},
+					"description": "过滤指定协议，'tcp' 和 'udp' 同时包含 IPv4 和 IPv6。",
+				},
+				"state": map[string]any{
+					"type":        "string",
+					"description": "过滤连接状态，例如 'LISTEN'、'ESTAB'、'TIME-WAIT'、'CLOSE-WAIT'；未连接的 UDP 套接字为 'UNCONN'。",
+				},
+				"port": map[string]any{
+					"type":        "integer",
+					"description": "过滤本地端口或远端端口等于该值的套接字。",
+				},
+				"local_port": map[string]any{
+					"type":        "integer",
+					"description": "过滤本地端口。",
+				},
+				"remote_port": map[string]any{
+					"type":        "integer",
+					"description": "过滤远端端口。",
+				},
+				"listening": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，只显示监听中的套接字，相当于 ss -l。",
+				},
+				"pid": map[string]any{
+					"type":        "integer",
+					"description": "只显示属于该进程的套接字。",
+				},
+				"format": map[string]any{
+					"type":        "string",
+					"enum":        []string{"table", "json"},
+					"description": "输出格式，默认为表格。",
 				},
 			},
+			"required": []string{},
 		},
-		{
-			Type: "function",
-			Function: llm.Function{
-				Name:        "ss",
-				Description: "显示套接字统计信息，用于查看网络连接。可以过滤特定端口或连接状态。",
-				Parameters: map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"options": map[string]any{
-							"type":        "array",
-							"items":       map[string]any{"type": "string"},
-							"description": "ss 命令的选项，例如 '-l' (监听), '-t' (TCP), '-u' (UDP), '-n' (数字显示), '-p' (显示进程), '-a' (所有套接字)。",
-						},
-						"port": map[string]any{
-							"type":        "integer",
-							"description": "过滤指定端口的连接。",
-						},
-						"protocol": map[string]any{
-							"type":        "string",
-							"description": "过滤指定协议，例如 'tcp' 或 'udp'。",
-						},
-					},
-					"required": []string{},
+	},
+}
+
+// lsofDefinition 定义了 lsof 工具：列出进程打开的文件。
+var lsofDefinition = llm.Tool{
+	Type: "function",
+	Function: llm.Function{
+		Name:        "lsof",
+		Description: "列出进程打开的文件。可以查询哪些进程打开了某个文件或目录、某个进程打开了哪些文件、哪个进程占用了某个端口，以及已删除但仍被打开、仍占用磁盘空间的文件。多个条件同时生效。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "查找打开指定文件的进程；如果是目录，则包括目录下的所有文件。",
+				},
+				"pid": map[string]any{
+					"type":        "integer",
+					"description": "列出指定进程打开的文件（包括内存映射的文件）。",
+				},
+				"port": map[string]any{
+					"type":        "integer",
+					"description": "查找占用指定本地端口的进程。",
+				},
+				"user": map[string]any{
+					"type":        "string",
+					"description": "只列出指定用户的进程打开的文件。",
+				},
+				"deleted": map[string]any{
+					"type":        "boolean",
+					"description": "如果为true，只列出已删除但仍被打开的文件，按大小排序并汇总占用的磁盘空间。",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "最多返回的条目数，默认为 500。",
+				},
+				"format": map[string]any{
+					"type":        "string",
+					"enum":        []string{"table", "json"},
+					"description": "输出格式，默认为表格。",
 				},
 			},
+			"required": []string{},
 		},
-		{
-			Type: "function",
-			Function: llm.Function{
-				Name:        "lsof",
-				Description: "列出打开的文件。可以查看进程打开的文件，或端口被哪个进程占用。",
-				Parameters: map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"path": map[string]any{
-							"type":        "string",
-							"description": "查找打开指定文件的进程。",
-						},
-						"port": map[string]any{
-							"type":        "integer",
-							"description": "查找占用指定端口的进程。",
-						},
-						"user": map[string]any{
-							"type":        "string",
-							"description": "查找某个用户打开的文件。",
-						},
-						"options": map[string]any{
-							"type":        "array",
-							"items":       map[string]any{"type": "string"},
-							"description": "lsof 命令的额外选项，例如 '-i' (列出所有网络文件)。",
-						},
-					},
-					"required": []string{},
+	},
+}
+
+// readOutputDefinition 定义了 read_output 工具：分页读取或搜索被截断的工具输出。
+var readOutputDefinition = llm.Tool{
+	Type: "function",
+	Function: llm.Function{
+		Name:        "read_output",
+		Description: "读取之前被截断的工具输出的完整内容。当工具结果中出现 '[output truncated: ...]' 标记时，可以用其中给出的 ID 分页查看，或用正则表达式搜索完整输出。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id": map[string]any{
+					"type":        "string",
+					"description": "被截断输出的 ID，例如 'out-1'。",
+				},
+				"offset": map[string]any{
+					"type":        "integer",
+					"description": "从第几行开始读取（从 1 开始），默认为 1。指定 pattern 时表示从第几个匹配行开始。",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "最多返回的行数，默认为 100。",
+				},
+				"pattern": map[string]any{
+					"type":        "string",
+					"description": "可选的正则表达式，只返回匹配的行（带行号）。",
 				},
 			},
+			"required": []string{"id"},
 		},
-	}
+	},
 }
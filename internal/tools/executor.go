@@ -1,23 +1,35 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"slices"
 
 	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/policy"
+	"github.com/DoraZa/mini-agent/internal/redact"
 )
 
-// ExecuteTool 执行一个工具调用，并应用安全策略。
-// 它首先检查调用的工具是否被允许，然后分发给相应的执行函数。
-func ExecuteTool(toolCall llm.ToolCall, allowedTools, deniedTools []string) (string, error) {
-	toolName := toolCall.Function.Name
+// GetToolDefinitions 返回默认注册表中所有可用工具的定义。
+func GetToolDefinitions() []llm.Tool {
+	return DefaultRegistry.Definitions()
+}
+
+// ExecuteTool 使用默认注册表执行一个工具调用，并应用安全策略。
+// 它首先检查调用的工具是否被允许，然后分发给注册表中对应的工具。
+// ctx 被取消或工具超时时，正在运行的外部命令会被杀死。
+func ExecuteTool(ctx context.Context, toolCall llm.ToolCall, allowedTools, deniedTools []string) (string, error) {
+	return NewToolSet(allowedTools, deniedTools).Execute(ctx, toolCall)
+}
 
+// checkPolicy 检查工具是否被白名单/黑名单允许。
+func checkPolicy(toolName string, allowedTools, deniedTools []string) error {
 	// 安全策略：
 	// 1. 如果白名单不为空（默认情况），工具必须在白名单中。
 	// 2. 工具决不能在黑名单中。
 	isDenied := slices.Contains(deniedTools, toolName)
 	if isDenied {
-		return "", fmt.Errorf("tool '%s' is in the configured blacklist", toolName)
+		return fmt.Errorf("tool '%s' is in the configured blacklist", toolName)
 	}
 
 	// 默认情况下，allowedTools 包含所有支持的工具。
@@ -25,25 +37,86 @@ func ExecuteTool(toolCall llm.ToolCall, allowedTools, deniedTools []string) (str
 	if len(allowedTools) > 0 {
 		isAllowed := slices.Contains(allowedTools, toolName)
 		if !isAllowed {
-			return "", fmt.Errorf("tool '%s' is not in the configured whitelist", toolName)
+			return fmt.Errorf("tool '%s' is not in the configured whitelist", toolName)
 		}
 	}
+	return nil
+}
+
+// ToolSet 将工具注册表与白名单/黑名单策略组合在一起，
+// 实现了 agent.ToolSet 接口，可以直接交给 agent.Runner 使用。
+type ToolSet struct {
+	Registry     *Registry
+	AllowedTools []string
+	DeniedTools  []string
+	// Rules 按参数进一步限制工具调用，为 nil 时不限制
+	Rules *policy.RuleSet
+}
+
+// NewToolSet 创建一个基于默认注册表、应用给定白名单和黑名单的工具集合。
+func NewToolSet(allowedTools, deniedTools []string) *ToolSet {
+	return &ToolSet{
+		Registry:     DefaultRegistry,
+		AllowedTools: allowedTools,
+		DeniedTools:  deniedTools,
+	}
+}
+
+// Definitions 返回所有可用工具的定义。
+func (s *ToolSet) Definitions() []llm.Tool {
+	return s.Registry.Definitions()
+}
+
+// Check 检查工具调用是否被白名单/黑名单允许、参数是否符合工具的 JSON Schema、是否被参数规则允许，
+// 以及访问的路径是否位于沙箱之内，实现了 agent.PolicyChecker 接口。
+// 参数不合法的调用在请求批准之前就会被拒绝，用户不会被要求批准一个注定失败的调用。
+func (s *ToolSet) Check(toolCall llm.ToolCall) error {
+	if err := checkPolicy(toolCall.Function.Name, s.AllowedTools, s.DeniedTools); err != nil {
+		return err
+	}
+	if err := s.Registry.Validate(toolCall); err != nil {
+		return err
+	}
+	// 规则匹配补上缺省值、规范化之后的路径，相对路径或省略参数都无法绕过规则
+	ruleCall := toolCall
+	ruleCall.Function.Arguments = s.Registry.ruleArguments(toolCall)
+	if err := s.Rules.Check(ruleCall); err != nil {
+		return err
+	}
+	return s.Registry.CheckSandbox(toolCall)
+}
+
+// RuleArguments 返回用于匹配参数规则的参数：路径参数补上缺省值并规范化为绝对路径。
+// 批准策略（policy.Approver）的规则应当使用它，与 Check 中的参数规则看到相同的参数。
+func (s *ToolSet) RuleArguments(toolCall llm.ToolCall) string {
+	return s.Registry.ruleArguments(toolCall)
+}
 
-	// 根据工具名称分发到具体的实现函数
-	switch toolName {
-	case "ps":
-		return executePs(toolCall)
-	case "find":
-		return executeFind(toolCall)
-	case "grep":
-		return executeGrep(toolCall)
-	case "wget":
-		return executeWget(toolCall)
-	case "ss":
-		return executeSs(toolCall)
-	case "lsof":
-		return executeLsof(toolCall)
-	default:
-		return "", fmt.Errorf("unknown tool: %s", toolName)
+// Execute 在应用安全策略后执行一个工具调用。
+func (s *ToolSet) Execute(ctx context.Context, toolCall llm.ToolCall) (string, error) {
+	output, _, _, _, err := s.ExecuteCommand(ctx, toolCall)
+	return output, err
+}
+
+// ExecuteCommand 与 Execute 相同，但同时返回工具实际执行的命令行、退出码和输出中被替换的敏感信息的数量，
+// 实现了 agent.CommandExecutor 接口。
+// 工具没有运行（例如被策略拒绝或参数校验失败）时，命令行为空，退出码为 -1。
+func (s *ToolSet) ExecuteCommand(ctx context.Context, toolCall llm.ToolCall) (output, command string, exitCode int, redactions redact.Report, err error) {
+	if err := s.Check(toolCall); err != nil {
+		return "", "", -1, nil, err
+	}
+	// wget 跟随重定向时，目标 URL 要经过与原始调用相同的检查
+	result, err := s.Registry.Execute(withRedirectCheck(ctx, s.Check), toolCall)
+	if result.Command == "" {
+		result.ExitCode = -1
 	}
+	if err != nil {
+		return "", result.Command, result.ExitCode, nil, err
+	}
+	return result.Output, result.Command, result.ExitCode, result.Redactions, nil
+}
+
+// ReadOnly 判断工具调用是否只读，实现了 agent.ReadOnlyChecker 接口。
+func (s *ToolSet) ReadOnly(toolCall llm.ToolCall) bool {
+	return s.Registry.ReadOnly(toolCall.Function.Name)
 }
@@ -0,0 +1,417 @@
+package tools
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultFindMaxResults 是 find 工具默认最多返回的结果数。
+const defaultFindMaxResults = 500
+
+// findArgs 是 find 工具的参数。
+type findArgs struct {
+	Path           string   `json:"path"`
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`
+	MaxDepth       int      `json:"maxdepth"`
+	MinSize        string   `json:"min_size"`
+	MaxSize        string   `json:"max_size"`
+	ModifiedAfter  string   `json:"modified_after"`
+	ModifiedBefore string   `json:"modified_before"`
+	Exclude        []string `json:"exclude"`
+	MaxResults     int      `json:"max_results"`
+	FollowSymlinks bool     `json:"follow_symlinks"`
+	Sort           string   `json:"sort"`
+}
+
+// foundFile 是一个匹配的文件或目录。
+type foundFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+	seq     int // 被发现的顺序，排序时用于保持相同大小或时间的结果的遍历顺序
+}
+
+// topResults 是按 sort 字段排序时只保留前 N 个结果的小顶堆，堆顶是当前最“靠后”的结果。
+// 这样无论匹配多少文件，内存占用都只与 max_results 有关。
+type topResults struct {
+	files  []foundFile
+	before func(a, b foundFile) bool // a 是否应该排在 b 前面
+}
+
+func (t *topResults) Len() int           { return len(t.files) }
+func (t *topResults) Less(i, j int) bool { return t.before(t.files[j], t.files[i]) }
+func (t *topResults) Swap(i, j int)      { t.files[i], t.files[j] = t.files[j], t.files[i] }
+func (t *topResults) Push(x any)         { t.files = append(t.files, x.(foundFile)) }
+func (t *topResults) Pop() any {
+	last := t.files[len(t.files)-1]
+	t.files = t.files[:len(t.files)-1]
+	return last
+}
+
+// add 加入一个结果。结果已满时，只有比堆顶更靠前的结果才会替换堆顶。
+func (t *topResults) add(file foundFile, limit int) {
+	if len(t.files) < limit {
+		heap.Push(t, file)
+		return
+	}
+	if t.before(file, t.files[0]) {
+		t.files[0] = file
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted 返回排好序的结果。
+func (t *topResults) sorted() []foundFile {
+	files := append([]foundFile(nil), t.files...)
+	sort.Slice(files, func(i, j int) bool { return t.before(files[i], files[j]) })
+	return files
+}
+
+// resultOrder 返回 sort 字段对应的排序规则，按路径（遍历顺序）输出时返回 nil。
+func resultOrder(field string) func(a, b foundFile) bool {
+	switch field {
+	case "size":
+		return func(a, b foundFile) bool {
+			if a.size != b.size {
+				return a.size > b.size
+			}
+			return a.seq < b.seq
+		}
+	case "mtime":
+		return func(a, b foundFile) bool {
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.After(b.modTime)
+			}
+			return a.seq < b.seq
+		}
+	}
+	return nil
+}
+
+// finder 保存一次查找的条件和状态。
+type finder struct {
+	ctx  context.Context
+	args findArgs
+
+	minSize, maxSize int64 // -1 表示不限制
+	after, before    time.Time
+
+	results    []foundFile     // 按遍历顺序输出时的结果
+	top        *topResults     // 按大小或修改时间排序时的前 max_results 个结果
+	matched    int             // 匹配的结果总数
+	truncated  bool            // 是否因为达到 max_results 提前停止
+	unreadable int             // 无法读取的目录或文件数
+	refused    int             // 跟随符号链接时指向沙箱之外而被跳过的链接数
+	visited    map[string]bool // 跟随符号链接时已遍历过的目录（真实路径），指向这些目录的链接不再展开，用于避免循环
+}
+
+// executeFind 在文件系统中查找文件或目录。
+// 它使用 filepath.WalkDir 遍历目录树，不依赖 find 命令，因此在所有平台上的行为一致。
+func executeFind(ctx context.Context, rawArgs json.RawMessage) (string, error) {
+	var args findArgs
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return "", fmt.Errorf("error decoding 'find' arguments: %w", err)
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	if args.MaxResults <= 0 {
+		args.MaxResults = defaultFindMaxResults
+	}
+	switch args.Type {
+	case "", "f", "d", "l":
+	default:
+		return "", fmt.Errorf("unsupported 'type' '%s', expected 'f', 'd' or 'l'", args.Type)
+	}
+	switch args.Sort {
+	case "", "path", "size", "mtime":
+	default:
+		return "", fmt.Errorf("unsupported 'sort' field '%s', expected one of path, size, mtime", args.Sort)
+	}
+	if args.Name != "" {
+		if _, err := filepath.Match(args.Name, ""); err != nil {
+			return "", fmt.Errorf("invalid 'name' pattern '%s': %w", args.Name, err)
+		}
+	}
+	for _, pattern := range args.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return "", fmt.Errorf("invalid 'exclude' pattern '%s': %w", pattern, err)
+		}
+	}
+
+	f := &finder{ctx: ctx, args: args, minSize: -1, maxSize: -1, visited: make(map[string]bool)}
+	if order := resultOrder(args.Sort); order != nil {
+		f.top = &topResults{before: order}
+	}
+	var err error
+	if args.MinSize != "" {
+		if f.minSize, err = parseSize(args.MinSize); err != nil {
+			return "", err
+		}
+	}
+	if args.MaxSize != "" {
+		if f.maxSize, err = parseSize(args.MaxSize); err != nil {
+			return "", err
+		}
+	}
+	now := time.Now()
+	if args.ModifiedAfter != "" {
+		if f.after, err = parseTimeFilter(args.ModifiedAfter, now); err != nil {
+			return "", err
+		}
+	}
+	if args.ModifiedBefore != "" {
+		if f.before, err = parseTimeFilter(args.ModifiedBefore, now); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := os.Lstat(args.Path); err != nil {
+		return "", fmt.Errorf("cannot access '%s': %w", args.Path, err)
+	}
+	if real, err := filepath.EvalSymlinks(args.Path); err == nil {
+		f.visited[real] = true
+	}
+	if err := f.walk(args.Path, args.Path, 0, false); err != nil {
+		return "", err
+	}
+	return f.format(), nil
+}
+
+// walk 遍历 root，并以 display 作为输出中的路径前缀。
+// 跟随符号链接时，root 是链接指向的真实目录，display 则是链接本身的路径，viaLink 为 true。
+func (f *finder) walk(root, display string, baseDepth int, viaLink bool) error {
+	// 跟随符号链接时记录遍历到的每个目录的真实路径，这样指向祖先目录或已遍历目录的链接不会再被展开
+	realRoot := root
+	if f.args.FollowSymlinks && !viaLink {
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			realRoot = real
+		}
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := f.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// 权限不足等错误不中断整个查找，只在结果中报告数量
+			f.unreadable++
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		depth := baseDepth
+		if rel != "." {
+			depth += strings.Count(rel, string(filepath.Separator)) + 1
+		}
+		shown := filepath.Join(display, rel)
+		if path != root && matchAny(f.args.Exclude, d.Name()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		descend := f.args.MaxDepth <= 0 || depth < f.args.MaxDepth
+
+		info, err := d.Info()
+		if err != nil {
+			f.unreadable++
+			return nil
+		}
+		symlink := d.Type()&fs.ModeSymlink != 0 || (viaLink && path == root)
+		if f.args.FollowSymlinks && d.IsDir() && descend {
+			f.visited[filepath.Join(realRoot, rel)] = true
+		}
+		if symlink && f.args.FollowSymlinks {
+			// 指向沙箱之外的链接既不跟随也不输出
+			if !sandboxAllows(f.ctx, path) {
+				f.refused++
+				return nil
+			}
+			if target, err := filepath.EvalSymlinks(path); err == nil {
+				if targetInfo, err := os.Stat(target); err == nil {
+					info = targetInfo
+					if info.IsDir() && descend && !f.visited[target] {
+						f.visited[target] = true
+						return f.walk(target, shown, depth, true)
+					}
+				}
+			}
+		}
+
+		f.consider(shown, info, symlink)
+		if f.truncated {
+			return fs.SkipAll
+		}
+		if d.IsDir() && !descend {
+			return fs.SkipDir
+		}
+		return nil
+	})
+}
+
+// consider 检查一个条目是否满足所有条件，满足时加入结果。
+// 跟随符号链接时 info 是链接目标的信息，symlink 表示条目本身（lstat 的结果）是否是符号链接。
+func (f *finder) consider(path string, info fs.FileInfo, symlink bool) {
+	mode := info.Mode()
+	switch f.args.Type {
+	case "f":
+		if !mode.IsRegular() {
+			return
+		}
+	case "d":
+		if !mode.IsDir() {
+			return
+		}
+	case "l":
+		if !symlink {
+			return
+		}
+	}
+	if f.args.Name != "" {
+		if ok, _ := filepath.Match(f.args.Name, filepath.Base(path)); !ok {
+			return
+		}
+	}
+	// 大小条件只对普通文件有意义
+	if f.minSize >= 0 || f.maxSize >= 0 {
+		if !mode.IsRegular() || (f.minSize >= 0 && info.Size() < f.minSize) || (f.maxSize >= 0 && info.Size() > f.maxSize) {
+			return
+		}
+	}
+	if (!f.after.IsZero() && !info.ModTime().After(f.after)) || (!f.before.IsZero() && !info.ModTime().Before(f.before)) {
+		return
+	}
+
+	// 需要排序时必须遍历全部条目，但只保留前 max_results 个结果；否则达到上限即可停止
+	file := foundFile{path: path, size: info.Size(), modTime: info.ModTime(), seq: f.matched}
+	if f.top != nil {
+		f.matched++
+		f.top.add(file, f.args.MaxResults)
+		return
+	}
+	if len(f.results) >= f.args.MaxResults {
+		f.truncated = true
+		return
+	}
+	f.matched++
+	f.results = append(f.results, file)
+}
+
+// format 将结果格式化为输出文本。按大小或修改时间排序时，同时输出大小和修改时间。
+func (f *finder) format() string {
+	if f.top != nil {
+		f.results = f.top.sorted()
+		f.truncated = f.matched > len(f.results)
+	}
+	var notes []string
+	if f.unreadable > 0 {
+		notes = append(notes, fmt.Sprintf("(%d entries could not be read, e.g. permission denied)", f.unreadable))
+	}
+	if f.refused > 0 {
+		notes = append(notes, fmt.Sprintf("(%d symbolic links pointing outside the sandbox were skipped)", f.refused))
+	}
+	if len(f.results) == 0 {
+		return strings.Join(append([]string{"No files found."}, notes...), " ")
+	}
+
+	var b strings.Builder
+	detailed := f.args.Sort == "size" || f.args.Sort == "mtime" || f.minSize >= 0 || f.maxSize >= 0
+	if detailed {
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SIZE\tMODIFIED\tPATH")
+		for _, r := range f.results {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", formatSize(r.size), r.modTime.Format("2006-01-02 15:04"), r.path)
+		}
+		w.Flush()
+	} else {
+		for _, r := range f.results {
+			b.WriteString(r.path + "\n")
+		}
+	}
+
+	if f.truncated {
+		if f.args.Sort == "" || f.args.Sort == "path" {
+			fmt.Fprintf(&b, "(stopped after %d results; narrow the search or raise 'max_results' to see more)\n", f.args.MaxResults)
+		} else {
+			fmt.Fprintf(&b, "(showing %d of %d results; narrow the search or raise 'max_results' to see more)\n", f.args.MaxResults, f.matched)
+		}
+	}
+	for _, note := range notes {
+		b.WriteString(note + "\n")
+	}
+	return b.String()
+}
+
+// parseSize 解析 '500M'、'1G'、'10k' 或纯字节数形式的大小，单位按 1024 进制计算。
+func parseSize(s string) (int64, error) {
+	text := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), "I")
+	multiplier := int64(1)
+	if n := len(text); n > 0 {
+		switch text[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			text = text[:n-1]
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size '%s', expected e.g. '500M', '1G' or a number of bytes", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// parseTimeFilter 解析时间条件：'7d'、'24h' 这样的相对时间表示距 now 之前的时刻，
+// 也可以是 '2006-01-02'、'2006-01-02 15:04:05' 或 RFC 3339 格式的绝对时间。
+func parseTimeFilter(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil {
+			return now.Add(-time.Duration(n * float64(24*time.Hour))), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected a relative time such as '7d' or '24h', or a date such as '2024-01-31'", s)
+}
+
+// formatSize 将字节数格式化为易读的形式。
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(bytes)/(1<<10))
+	default:
+		return strconv.FormatInt(bytes, 10) + "B"
+	}
+}
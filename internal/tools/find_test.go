@@ -0,0 +1,241 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles 在 dir 下创建文件，每个文件的大小和修改时间（距 now 之前多久）由 files 给出。
+func writeFiles(t *testing.T, dir string, now time.Time, files map[string]struct {
+	size int
+	age  time.Duration
+}) {
+	t.Helper()
+	for name, f := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", f.size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(-f.age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// foundPaths 从 find 的输出中取出路径，忽略表头和括号中的说明。
+func foundPaths(output string) []string {
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(line, "(") || strings.HasPrefix(line, "SIZE") {
+			continue
+		}
+		paths = append(paths, fields[len(fields)-1])
+	}
+	return paths
+}
+
+func TestFindSortAndLimit(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, time.Now(), map[string]struct {
+		size int
+		age  time.Duration
+	}{
+		"a.txt":     {10, 3 * time.Hour},
+		"b.txt":     {30, 1 * time.Hour},
+		"c.txt":     {20, 2 * time.Hour},
+		"sub/d.txt": {40, 4 * time.Hour},
+		"sub/e.txt": {30, 5 * time.Hour},
+	})
+	t.Chdir(root)
+
+	tests := []struct {
+		name     string
+		args     string
+		want     []string
+		wantNote string
+	}{
+		{
+			name:     "largest first with ties in walk order",
+			args:     `{"type":"f","sort":"size","max_results":3}`,
+			want:     []string{"sub/d.txt", "b.txt", "sub/e.txt"},
+			wantNote: "(showing 3 of 5 results",
+		},
+		{
+			name:     "newest first",
+			args:     `{"type":"f","sort":"mtime","max_results":2}`,
+			want:     []string{"b.txt", "c.txt"},
+			wantNote: "(showing 2 of 5 results",
+		},
+		{
+			name: "sorted without truncation",
+			args: `{"type":"f","sort":"size"}`,
+			want: []string{"sub/d.txt", "b.txt", "sub/e.txt", "c.txt", "a.txt"},
+		},
+		{
+			name:     "walk order stops at the limit",
+			args:     `{"type":"f","max_results":2}`,
+			want:     []string{"a.txt", "b.txt"},
+			wantNote: "(stopped after 2 results",
+		},
+		{
+			name:     "limit applies after filtering",
+			args:     `{"type":"f","sort":"size","max_results":1,"max_size":"25"}`,
+			want:     []string{"c.txt"},
+			wantNote: "(showing 1 of 2 results",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executeFind(context.Background(), json.RawMessage(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]string, len(tt.want))
+			for i, p := range tt.want {
+				want[i] = filepath.FromSlash(p)
+			}
+			if got := foundPaths(output); !slices.Equal(got, want) {
+				t.Errorf("executeFind(%s) found %q, want %q\n%s", tt.args, got, want, output)
+			}
+			if tt.wantNote != "" && !strings.Contains(output, tt.wantNote) {
+				t.Errorf("output does not contain %q:\n%s", tt.wantNote, output)
+			}
+			if tt.wantNote == "" && strings.Contains(output, "max_results") {
+				t.Errorf("output reports truncation:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestTopResultsMatchesFullSort(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var files []foundFile
+	for i := range 200 {
+		// 大小和时间的取值范围很小，保证有大量相同的值需要按遍历顺序排列
+		files = append(files, foundFile{
+			path:    filepath.Join("dir", string(rune('a'+i%26))),
+			size:    rng.Int64N(10),
+			modTime: base.Add(time.Duration(rng.IntN(10)) * time.Minute),
+			seq:     i,
+		})
+	}
+	for _, field := range []string{"size", "mtime"} {
+		for _, limit := range []int{1, 7, 50, 200, 500} {
+			order := resultOrder(field)
+			top := &topResults{before: order}
+			for _, f := range files {
+				top.add(f, limit)
+			}
+			want := slices.Clone(files)
+			slices.SortStableFunc(want, func(a, b foundFile) int {
+				switch {
+				case order(a, b):
+					return -1
+				case order(b, a):
+					return 1
+				}
+				return 0
+			})
+			want = want[:min(limit, len(want))]
+			if got := top.sorted(); !slices.Equal(got, want) {
+				t.Errorf("sort %s, limit %d: topResults = %v, want %v", field, limit, got, want)
+			}
+		}
+	}
+}
+
+func TestFindFollowSymlinks(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	writeFiles(t, base, time.Now(), map[string]struct {
+		size int
+		age  time.Duration
+	}{
+		"root/a.txt":        {1, 0},
+		"root/x/in-x.txt":   {1, 0},
+		"root/y/in-y.txt":   {1, 0},
+		"outside/other.txt": {1, 0},
+	})
+	links := map[string]string{
+		"root/outside-link": "../outside",
+		"root/x/loop":       "..",   // 指向自己的祖先
+		"root/x/to-y":       "../y", // x 和 y 互相指向对方
+		"root/y/to-x":       "../x",
+		"root/dangling":     "absent", // 目标不存在
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(root)
+
+	tests := []struct {
+		name    string
+		args    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "links are listed but not followed",
+			args:    `{}`,
+			want:    []string{"outside-link", "x/loop", "x/to-y", "y/to-x", "dangling"},
+			notWant: []string{"outside-link/other.txt", "x/to-y/in-y.txt"},
+		},
+		{
+			name: "links are followed",
+			args: `{"follow_symlinks":true,"type":"f"}`,
+			want: []string{"a.txt", "outside-link/other.txt", "x/in-x.txt", "x/to-y/in-y.txt", "y/in-y.txt"},
+			// 指向已遍历过的目录的链接不再展开
+			notWant: []string{"x/loop/a.txt", "x/to-y/to-x/in-x.txt", "x/to-y/to-x/to-y/in-y.txt"},
+		},
+		{
+			name: "loops are reported as directories",
+			args: `{"follow_symlinks":true,"type":"d"}`,
+			want: []string{"x/loop", "x/to-y"},
+		},
+		{
+			name: "only links",
+			args: `{"follow_symlinks":true,"type":"l"}`,
+			want: []string{"outside-link", "x/loop", "x/to-y", "dangling"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			output, err := executeFind(ctx, json.RawMessage(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := foundPaths(output)
+			for _, p := range tt.want {
+				if !slices.Contains(found, filepath.FromSlash(p)) {
+					t.Errorf("executeFind(%s) did not find %s:\n%s", tt.args, p, output)
+				}
+			}
+			for _, p := range tt.notWant {
+				if slices.Contains(found, filepath.FromSlash(p)) {
+					t.Errorf("executeFind(%s) found %s:\n%s", tt.args, p, output)
+				}
+			}
+			// 每个路径只出现一次
+			if len(found) != len(slices.Compact(slices.Sorted(slices.Values(found)))) {
+				t.Errorf("executeFind(%s) listed duplicates:\n%s", tt.args, output)
+			}
+		})
+	}
+}
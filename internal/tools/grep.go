@@ -0,0 +1,403 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// defaultGrepMaxFileSize 是默认跳过的文件大小上限。
+	defaultGrepMaxFileSize = 10 << 20
+	// grepMaxTotalMatches 是一次搜索最多返回的匹配行数，避免输出无限增长。
+	grepMaxTotalMatches = 1000
+	// grepMaxLineWidth 是输出中单行内容的最大字节数。
+	grepMaxLineWidth = 500
+	// binarySniffLen 是判断二进制文件时检查的字节数，与 GNU grep 的做法类似。
+	binarySniffLen = 8000
+)
+
+// vcsDirs 是递归搜索时总是跳过的版本控制目录。
+var vcsDirs = []string{".git", ".hg", ".svn"}
+
+// grepArgs 是 grep 工具的参数。
+type grepArgs struct {
+	Pattern       string   `json:"pattern"`
+	Paths         []string `json:"paths"`
+	Include       []string `json:"include"`
+	Exclude       []string `json:"exclude"`
+	IgnoreCase    bool     `json:"ignore_case"`
+	FixedStrings  bool     `json:"fixed_strings"`
+	BeforeContext int      `json:"before_context"`
+	AfterContext  int      `json:"after_context"`
+	MaxCount      int      `json:"max_count"`
+	CountOnly     bool     `json:"count_only"`
+	MaxFileSize   string   `json:"max_file_size"`
+	Gitignore     bool     `json:"gitignore"`
+}
+
+// grepper 保存一次搜索的条件、输出和统计信息。
+type grepper struct {
+	ctx         context.Context
+	args        grepArgs
+	re          *regexp.Regexp
+	maxFileSize int64
+
+	out          strings.Builder
+	matches      int // 输出的匹配行总数
+	matchedFiles int
+	searched     int
+	binary       int
+	oversized    int
+	unreadable   int
+	refused      int  // 指向沙箱之外而被跳过的符号链接数
+	truncated    bool // 是否因为达到 grepMaxTotalMatches 提前停止
+}
+
+// executeGrep 在文件中搜索匹配正则表达式的行。
+// 它使用 Go 的 regexp 实现，不依赖 grep 命令，并以 file:line:text 的格式返回结果。
+func executeGrep(ctx context.Context, rawArgs json.RawMessage) (string, error) {
+	var args grepArgs
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return "", fmt.Errorf("error decoding 'grep' arguments: %w", err)
+	}
+	if args.Pattern == "" {
+		return "", fmt.Errorf("the 'pattern' argument is required for grep")
+	}
+	if len(args.Paths) == 0 {
+		args.Paths = []string{"."}
+	}
+	for _, pattern := range append(slices.Clone(args.Include), args.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return "", fmt.Errorf("invalid glob '%s': %w", pattern, err)
+		}
+	}
+
+	expr := args.Pattern
+	if args.FixedStrings {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if args.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid regular expression '%s': %w (set 'fixed_strings' to search for it literally)", args.Pattern, err)
+	}
+
+	g := &grepper{ctx: ctx, args: args, re: re, maxFileSize: defaultGrepMaxFileSize}
+	if args.MaxFileSize != "" {
+		if g.maxFileSize, err = parseSize(args.MaxFileSize); err != nil {
+			return "", err
+		}
+	}
+
+	for _, p := range args.Paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return "", fmt.Errorf("cannot access '%s': %w", p, err)
+		}
+		if info.IsDir() {
+			err = g.walk(p)
+		} else {
+			// 显式指定的文件不受 include/exclude 的限制
+			err = g.searchFile(p, info)
+		}
+		if err != nil {
+			return "", err
+		}
+		if g.truncated {
+			break
+		}
+	}
+	return g.summary(), nil
+}
+
+// walk 递归搜索目录 root 下的文件。
+func (g *grepper) walk(root string) error {
+	root = filepath.Clean(root)
+	var ignore *gitignore
+	if g.args.Gitignore {
+		ignore = newGitignore(root)
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := g.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			g.unreadable++
+			if d != nil && d.IsDir() && p != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if g.truncated {
+			return fs.SkipAll
+		}
+
+		name := d.Name()
+		if d.IsDir() {
+			if p == root {
+				ignore.load(p)
+				return nil
+			}
+			if slices.Contains(vcsDirs, name) || matchAny(g.args.Exclude, name) || ignore.ignored(p, true) {
+				return fs.SkipDir
+			}
+			ignore.load(p)
+			return nil
+		}
+
+		if matchAny(g.args.Exclude, name) || (len(g.args.Include) > 0 && !matchAny(g.args.Include, name)) || ignore.ignored(p, false) {
+			return nil
+		}
+		// 符号链接指向的普通文件也会被搜索，但不会进入链接指向的目录；指向沙箱之外的链接会被跳过
+		if d.Type()&fs.ModeSymlink != 0 && !sandboxAllows(g.ctx, p) {
+			g.refused++
+			return nil
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			g.unreadable++
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return g.searchFile(p, info)
+	})
+}
+
+// searchFile 搜索单个文件，跳过过大的文件和二进制文件。
+func (g *grepper) searchFile(p string, info fs.FileInfo) error {
+	if err := g.ctx.Err(); err != nil {
+		return err
+	}
+	if info.Size() > g.maxFileSize {
+		g.oversized++
+		return nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		g.unreadable++
+		return nil
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		g.binary++
+		return nil
+	}
+	g.searched++
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	var hits []int
+	for i, line := range lines {
+		if g.re.MatchString(strings.TrimSuffix(line, "\r")) {
+			hits = append(hits, i)
+			if g.args.MaxCount > 0 && len(hits) >= g.args.MaxCount {
+				break
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+	if remaining := grepMaxTotalMatches - g.matches; len(hits) > remaining {
+		hits = hits[:remaining]
+		g.truncated = true
+	}
+	g.matchedFiles++
+	g.matches += len(hits)
+
+	if g.args.CountOnly {
+		fmt.Fprintf(&g.out, "%s:%d\n", p, len(hits))
+		return nil
+	}
+
+	// 输出匹配行及其上下文，相邻的片段会合并，不相邻的片段之间用 '--' 分隔
+	isHit := make(map[int]bool, len(hits))
+	for _, i := range hits {
+		isHit[i] = true
+	}
+	last := -1
+	for _, i := range hits {
+		start := max(i-g.args.BeforeContext, last+1)
+		end := min(i+g.args.AfterContext, len(lines)-1)
+		if last >= 0 && start > last+1 && (g.args.BeforeContext > 0 || g.args.AfterContext > 0) {
+			g.out.WriteString("--\n")
+		}
+		for j := start; j <= end; j++ {
+			sep := "-"
+			if isHit[j] {
+				sep = ":"
+			}
+			line := strings.TrimSuffix(lines[j], "\r")
+			if len(line) > grepMaxLineWidth {
+				line = truncateBytes(line, grepMaxLineWidth) + "..."
+			}
+			fmt.Fprintf(&g.out, "%s%s%d%s%s\n", p, sep, j+1, sep, line)
+		}
+		last = max(last, end)
+	}
+	return nil
+}
+
+// summary 返回搜索结果以及匹配数量的汇总。
+func (g *grepper) summary() string {
+	var b strings.Builder
+	if g.matches == 0 {
+		b.WriteString("No matching lines found.")
+	} else {
+		b.WriteString(g.out.String())
+		fmt.Fprintf(&b, "Found %d matching lines in %d files (%d files searched).", g.matches, g.matchedFiles, g.searched)
+	}
+	var skipped []string
+	if g.binary > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d binary", g.binary))
+	}
+	if g.oversized > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d larger than %s", g.oversized, formatSize(g.maxFileSize)))
+	}
+	if g.unreadable > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d unreadable", g.unreadable))
+	}
+	if g.refused > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d symbolic links pointing outside the sandbox", g.refused))
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, " Skipped files: %s.", strings.Join(skipped, ", "))
+	}
+	if g.truncated {
+		fmt.Fprintf(&b, " Stopped after %d matching lines; narrow the search with more specific paths, 'include' globs or 'max_count'.", grepMaxTotalMatches)
+	}
+	return b.String()
+}
+
+// matchAny 判断名称是否匹配任一通配符模式。
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// gitignore 是搜索目录中各个 .gitignore 文件的简化实现，
+// 支持注释、'!' 取反、以 '/' 结尾只匹配目录、包含 '/' 的模式相对于 .gitignore 所在目录匹配，以及 '**'。
+// nil 的 gitignore 不忽略任何文件。
+type gitignore struct {
+	root  string
+	rules map[string][]ignoreRule // 按 .gitignore 所在目录保存的规则
+}
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func newGitignore(root string) *gitignore {
+	return &gitignore{root: root, rules: make(map[string][]ignoreRule)}
+}
+
+// load 读取目录 dir 中的 .gitignore（如果存在）。
+func (g *gitignore) load(dir string) {
+	if g == nil {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			rule.negate, line = true, rest
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly, line = true, rest
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	g.rules[dir] = rules
+}
+
+// ignored 判断 p 是否被其所在目录及上级目录（不超出搜索根目录）中的 .gitignore 忽略。
+// 与 git 一致，后出现的规则优先，深层目录的规则优先于上层目录。
+func (g *gitignore) ignored(p string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	var dirs []string
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == g.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], p)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range g.rules[dirs[i]] {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			target := rel
+			if !rule.anchored {
+				target = path.Base(rel)
+			}
+			if matchGlobPath(rule.pattern, target) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// matchGlobPath 按 '/' 分段匹配路径，'**' 可以匹配任意多层目录。
+func matchGlobPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,259 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTree 在 dir 下按相对路径创建文件。
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// numberedLines 返回 "line 1" 到 "line n" 这样的 n 行文本，hits 中的行以 "match" 结尾。
+func numberedLines(n int, hits ...int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d", i)
+		if slices.Contains(hits, i) {
+			b.WriteString(" match")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// grepLines 去掉 grep 输出末尾的汇总，返回结果行。
+func grepLines(output string) []string {
+	body, _, _ := strings.Cut(output, "Found ")
+	return strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+}
+
+func TestGrepContextAndMaxCount(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"spread.txt": numberedLines(12, 3, 5, 10),
+		"dense.txt":  numberedLines(6, 3, 4),
+		"crlf.txt":   "line 1 match\r\nline 2\r\n",
+	})
+	t.Chdir(dir)
+
+	tests := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{
+			name: "no context",
+			args: `{"pattern":"match","paths":["spread.txt"]}`,
+			want: []string{"spread.txt:3:line 3 match", "spread.txt:5:line 5 match", "spread.txt:10:line 10 match"},
+		},
+		{
+			name: "overlapping context is merged",
+			args: `{"pattern":"match","paths":["spread.txt"],"before_context":1,"after_context":1}`,
+			want: []string{
+				"spread.txt-2-line 2", "spread.txt:3:line 3 match", "spread.txt-4-line 4",
+				"spread.txt:5:line 5 match", "spread.txt-6-line 6",
+				"--",
+				"spread.txt-9-line 9", "spread.txt:10:line 10 match", "spread.txt-11-line 11",
+			},
+		},
+		{
+			name: "adjacent context is merged without a separator",
+			args: `{"pattern":"match","paths":["spread.txt"],"after_context":4}`,
+			want: []string{
+				"spread.txt:3:line 3 match", "spread.txt-4-line 4", "spread.txt:5:line 5 match",
+				"spread.txt-6-line 6", "spread.txt-7-line 7", "spread.txt-8-line 8", "spread.txt-9-line 9",
+				"spread.txt:10:line 10 match", "spread.txt-11-line 11", "spread.txt-12-line 12",
+			},
+		},
+		{
+			name: "matches inside each other's context",
+			args: `{"pattern":"match","paths":["dense.txt"],"before_context":2,"after_context":2}`,
+			want: []string{
+				"dense.txt-1-line 1", "dense.txt-2-line 2", "dense.txt:3:line 3 match",
+				"dense.txt:4:line 4 match", "dense.txt-5-line 5", "dense.txt-6-line 6",
+			},
+		},
+		{
+			name: "context clipped at the start and end of the file",
+			args: `{"pattern":"match","paths":["crlf.txt"],"before_context":3,"after_context":3}`,
+			want: []string{"crlf.txt:1:line 1 match", "crlf.txt-2-line 2"},
+		},
+		{
+			name: "max_count stops each file",
+			args: `{"pattern":"match","paths":["spread.txt","dense.txt"],"max_count":1}`,
+			want: []string{"spread.txt:3:line 3 match", "dense.txt:3:line 3 match"},
+		},
+		{
+			name: "max_count with count_only",
+			args: `{"pattern":"match","paths":["spread.txt"],"max_count":2,"count_only":true}`,
+			want: []string{"spread.txt:2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executeGrep(context.Background(), json.RawMessage(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := grepLines(output); !slices.Equal(got, tt.want) {
+				t.Errorf("executeGrep(%s) =\n%s\nwant\n%s", tt.args, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestGrepFixedStrings(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"file.txt": "a.b(c\naxb(c\nA.B(C\n"})
+	t.Chdir(dir)
+
+	tests := []struct {
+		name    string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "regexp", args: `{"pattern":"a.b","paths":["file.txt"]}`, want: []string{"file.txt:1:a.b(c", "file.txt:2:axb(c"}},
+		{name: "fixed string", args: `{"pattern":"a.b(","paths":["file.txt"],"fixed_strings":true}`, want: []string{"file.txt:1:a.b(c"}},
+		{name: "fixed string ignoring case", args: `{"pattern":"a.b(","paths":["file.txt"],"fixed_strings":true,"ignore_case":true}`,
+			want: []string{"file.txt:1:a.b(c", "file.txt:3:A.B(C"}},
+		{name: "invalid regexp", args: `{"pattern":"a.b(","paths":["file.txt"]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executeGrep(context.Background(), json.RawMessage(tt.args))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "fixed_strings") {
+					t.Errorf("executeGrep(%s) = %v, want an error suggesting fixed_strings", tt.args, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := grepLines(output); !slices.Equal(got, tt.want) {
+				t.Errorf("executeGrep(%s) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrepSkipsBinaryAndOversizedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"text.txt":   "needle\n",
+		"binary.bin": "needle\x00\x01\x02\n",
+		"large.txt":  "needle\n" + strings.Repeat("x", 100) + "\n",
+		// NUL 出现在检查范围之外的文件仍然按文本搜索
+		"late-nul.txt": strings.Repeat("x", binarySniffLen) + "\x00\nneedle\n",
+	})
+	t.Chdir(dir)
+
+	output, err := executeGrep(context.Background(), json.RawMessage(`{"pattern":"needle","max_file_size":"9000"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "large.txt:1:needle\nlate-nul.txt:2:needle\ntext.txt:1:needle\n" +
+		"Found 3 matching lines in 3 files (3 files searched). Skipped files: 1 binary."
+	if output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+
+	output, err = executeGrep(context.Background(), json.RawMessage(`{"pattern":"needle","max_file_size":"50"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(output, "Skipped files: 1 binary, 2 larger than 50B.") || !strings.Contains(output, "text.txt:1:needle") {
+		t.Errorf("output = %q, want the large files skipped", output)
+	}
+
+	// 显式指定的二进制文件同样被跳过
+	output, err = executeGrep(context.Background(), json.RawMessage(`{"pattern":"needle","paths":["binary.bin"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "No matching lines found. Skipped files: 1 binary."; output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
+
+func TestGrepGitignore(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore": "# build output\n*.log\n!keep.log\nbuild/\n/top.txt\ndocs/**/*.md\n",
+		"a.log":      "needle\n",
+		"keep.log":   "needle\n",
+		"top.txt":    "needle\n",
+		"main.go":    "needle\n",
+		// build/ 只忽略目录，同名文件不受影响
+		"lib/build":         "needle\n",
+		"build/out.txt":     "needle\n",
+		"sub/build/out.txt": "needle\n",
+		"sub/top.txt":       "needle\n",
+		"docs/guide.md":     "needle\n",
+		"docs/api/ref.md":   "needle\n",
+		"docs/notes.txt":    "needle\n",
+		// 深层目录的规则优先
+		"other/.gitignore": "!a.log\n",
+		"other/a.log":      "needle\n",
+		".git/config":      "needle\n",
+	})
+	t.Chdir(dir)
+
+	tests := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{
+			name: "without gitignore",
+			args: `{"pattern":"needle","count_only":true}`,
+			want: []string{"a.log", "build/out.txt", "docs/api/ref.md", "docs/guide.md", "docs/notes.txt", "keep.log",
+				"lib/build", "main.go", "other/a.log", "sub/build/out.txt", "sub/top.txt", "top.txt"},
+		},
+		{
+			name: "with gitignore",
+			args: `{"pattern":"needle","count_only":true,"gitignore":true}`,
+			want: []string{"docs/notes.txt", "keep.log", "lib/build", "main.go", "other/a.log", "sub/top.txt"},
+		},
+		{
+			name: "gitignore of a subdirectory root",
+			args: `{"pattern":"needle","count_only":true,"gitignore":true,"paths":["other"]}`,
+			want: []string{"other/a.log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executeGrep(context.Background(), json.RawMessage(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, line := range grepLines(output) {
+				name, _, _ := strings.Cut(line, ":")
+				got = append(got, filepath.ToSlash(name))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("executeGrep(%s) searched %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+//go:build linux
+
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/DoraZa/mini-agent/internal/policy"
+)
+
+const (
+	// defaultLsofLimit 是 lsof 工具默认最多返回的条目数。
+	defaultLsofLimit = 500
+	// deletedSuffix 是内核在已删除文件的路径后追加的标记。
+	deletedSuffix = " (deleted)"
+)
+
+// openFile 是进程打开的一个文件，或者映射到进程内存中的一个文件。
+type openFile struct {
+	Command string `json:"command"`
+	PID     int    `json:"pid"`
+	User    string `json:"user"`
+	FD      string `json:"fd"`   // 文件描述符编号，内存映射的文件为 "mem"
+	Type    string `json:"type"` // REG、DIR、CHR、FIFO、sock、pipe 等
+	Size    int64  `json:"size,omitempty"`
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// lsofArgs 是 lsof 工具的参数。
+type lsofArgs struct {
+	Path    string `json:"path"`
+	PID     int    `json:"pid"`
+	Port    int    `json:"port"`
+	User    string `json:"user"`
+	Deleted bool   `json:"deleted"`
+	Limit   int    `json:"limit"`
+	Format  string `json:"format"`
+}
+
+// executeLsof 列出进程打开的文件。
+// 它直接读取 /proc/<pid>/fd 和 /proc/<pid>/maps，不依赖 lsof 命令。所有条件同时生效；
+// 没有权限检查的进程会在结果中报告，而不会让整个调用失败。
+func executeLsof(ctx context.Context, rawArgs json.RawMessage) (string, error) {
+	var args lsofArgs
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return "", fmt.Errorf("error decoding 'lsof' arguments: %w", err)
+	}
+	if args.Path == "" && args.PID == 0 && args.Port == 0 && args.User == "" && !args.Deleted {
+		return "", fmt.Errorf("at least one of 'path', 'pid', 'port', 'user' or 'deleted' must be provided for lsof")
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultLsofLimit
+	}
+	if args.Format == "" {
+		args.Format = "table"
+	}
+	if args.Format != "table" && args.Format != "json" {
+		return "", fmt.Errorf("unsupported 'format' '%s', expected 'table' or 'json'", args.Format)
+	}
+	if args.Path != "" {
+		// 内核报告的是解析过符号链接的真实路径，查询路径也必须用同样的形式比较
+		args.Path = policy.CanonicalPath(args.Path)
+	}
+
+	// 套接字只在 fd 中以 inode 出现，需要通过 /proc/net 中的套接字表还原出地址
+	sockets := make(map[uint64]socketInfo)
+	for _, table := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		parsed, err := readSocketTable(table)
+		if err != nil {
+			return "", err
+		}
+		for _, s := range parsed {
+			sockets[s.inode] = s
+		}
+	}
+
+	pids := []int{args.PID}
+	if args.PID == 0 {
+		var err error
+		if pids, err = listPIDs(); err != nil {
+			return "", err
+		}
+	} else if _, err := os.Stat(filepath.Join(procRoot, strconv.Itoa(args.PID))); err != nil {
+		return "", fmt.Errorf("process %d not found", args.PID)
+	}
+	// 内存映射的文件（例如共享库）数量很多，只在查询具体文件、进程或已删除文件时才列出
+	includeMaps := args.Path != "" || args.PID != 0 || args.Deleted
+
+	var files []openFile
+	var denied []string
+	for _, pid := range pids {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		user := lookupUsername(readProcUID(pid))
+		if args.User != "" && user != args.User {
+			continue
+		}
+		fds, err := readFDs(pid)
+		if err != nil {
+			if os.IsPermission(err) {
+				denied = append(denied, fmt.Sprintf("%d (%s)", pid, readComm(pid)))
+			}
+			continue
+		}
+		command := readComm(pid)
+		for _, fd := range fds {
+			f := describeFD(pid, fd, sockets)
+			f.Command, f.PID, f.User = command, pid, user
+			if matchesLsof(f, args, sockets, fd.target) {
+				files = append(files, f)
+			}
+		}
+		if includeMaps {
+			for _, f := range readMappedFiles(pid) {
+				f.Command, f.PID, f.User = command, pid, user
+				if matchesLsof(f, args, sockets, "") {
+					files = append(files, f)
+				}
+			}
+		}
+	}
+
+	return formatOpenFiles(files, denied, args)
+}
+
+// describeFD 根据 fd 链接的目标判断文件类型和大小。
+func describeFD(pid int, fd procFD, sockets map[uint64]socketInfo) openFile {
+	f := openFile{FD: strconv.Itoa(fd.fd), Name: fd.target}
+	switch {
+	case strings.HasPrefix(fd.target, "socket:["):
+		f.Type = "sock"
+		if inode, ok := socketInode(fd.target); ok {
+			if s, ok := sockets[inode]; ok {
+				f.Name = fmt.Sprintf("%s %s->%s (%s)", s.Proto,
+					joinHostPort(s.LocalAddr, s.LocalPort), joinHostPort(s.RemoteAddr, s.RemotePort), s.State)
+			}
+		}
+		return f
+	case strings.HasPrefix(fd.target, "pipe:["):
+		f.Type = "pipe"
+		return f
+	case strings.HasPrefix(fd.target, "anon_inode:"):
+		f.Type = "anon"
+		return f
+	}
+
+	if name, ok := strings.CutSuffix(fd.target, deletedSuffix); ok {
+		f.Name, f.Deleted = name, true
+	}
+	// 通过 /proc/<pid>/fd/<fd> 获取文件信息，对已删除的文件同样有效
+	info, err := os.Stat(filepath.Join(procRoot, strconv.Itoa(pid), "fd", strconv.Itoa(fd.fd)))
+	if err != nil {
+		f.Type = "unknown"
+		return f
+	}
+	f.Type = fileTypeName(info.Mode())
+	if info.Mode().IsRegular() {
+		f.Size = info.Size()
+	}
+	return f
+}
+
+// readMappedFiles 读取 /proc/<pid>/maps 中映射的文件，每个文件只返回一次。
+func readMappedFiles(pid int) []openFile {
+	file, err := os.Open(filepath.Join(procRoot, strconv.Itoa(pid), "maps"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	seen := make(map[string]bool)
+	var files []openFile
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// address perms offset dev inode pathname
+		fields := strings.SplitN(scanner.Text(), " ", 6)
+		if len(fields) < 6 {
+			continue
+		}
+		name := strings.TrimSpace(fields[5])
+		if !strings.HasPrefix(name, "/") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		f := openFile{FD: "mem", Type: "REG", Name: name}
+		statPath := name
+		if trimmed, ok := strings.CutSuffix(name, deletedSuffix); ok {
+			// 已删除的文件无法再通过路径访问，但仍可以通过 map_files 中对应映射区间的链接访问
+			f.Name, f.Deleted = trimmed, true
+			statPath = mapFilePath(pid, fields[0])
+		}
+		if info, err := os.Stat(statPath); err == nil {
+			f.Size = info.Size()
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// mapFilePath 返回 maps 中一个映射区间（例如 "00400000-00452000"）在 /proc/<pid>/map_files 下对应的链接。
+// map_files 中的地址不补零，因此需要重新格式化。
+func mapFilePath(pid int, addresses string) string {
+	startText, endText, _ := strings.Cut(addresses, "-")
+	start, err1 := strconv.ParseUint(startText, 16, 64)
+	end, err2 := strconv.ParseUint(endText, 16, 64)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	return filepath.Join(procRoot, strconv.Itoa(pid), "map_files", fmt.Sprintf("%x-%x", start, end))
+}
+
+// matchesLsof 判断一个打开的文件是否满足查询条件。
+func matchesLsof(f openFile, args lsofArgs, sockets map[uint64]socketInfo, target string) bool {
+	if args.Deleted && !f.Deleted {
+		return false
+	}
+	if args.Path != "" && !policy.IsWithin(args.Path, f.Name) {
+		return false
+	}
+	if args.Port != 0 {
+		inode, ok := socketInode(target)
+		if !ok {
+			return false
+		}
+		if s, ok := sockets[inode]; !ok || s.LocalPort != args.Port {
+			return false
+		}
+	}
+	return true
+}
+
+// fileTypeName 返回与 lsof TYPE 列一致的文件类型名称。
+func fileTypeName(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "REG"
+	case mode.IsDir():
+		return "DIR"
+	case mode&fs.ModeCharDevice != 0:
+		return "CHR"
+	case mode&fs.ModeDevice != 0:
+		return "BLK"
+	case mode&fs.ModeNamedPipe != 0:
+		return "FIFO"
+	case mode&fs.ModeSocket != 0:
+		return "unix"
+	default:
+		return "unknown"
+	}
+}
+
+// formatOpenFiles 格式化查询结果。查询已删除文件时按大小降序排列并汇总占用的空间。
+func formatOpenFiles(files []openFile, denied []string, args lsofArgs) (string, error) {
+	if args.Deleted {
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	}
+	// 同一个文件可能被多个进程或多个 fd 同时打开，按文件去重后再汇总占用的空间
+	var deletedSize int64
+	seen := make(map[string]bool)
+	for _, f := range files {
+		key := f.Name + "\x00" + strconv.FormatInt(f.Size, 10)
+		if f.Deleted && !seen[key] {
+			seen[key] = true
+			deletedSize += f.Size
+		}
+	}
+	total := len(files)
+	if len(files) > args.Limit {
+		files = files[:args.Limit]
+	}
+
+	var b strings.Builder
+	if args.Format == "json" {
+		data, err := json.MarshalIndent(files, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("error encoding open files: %w", err)
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	} else if total == 0 {
+		b.WriteString("No matching open files found.\n")
+	} else {
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "COMMAND\tPID\tUSER\tFD\tTYPE\tSIZE\tNAME")
+		for _, f := range files {
+			size := "-"
+			if f.Type == "REG" {
+				size = formatSize(f.Size)
+			}
+			name := f.Name
+			if f.Deleted {
+				name += deletedSuffix
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", f.Command, f.PID, f.User, f.FD, f.Type, size, name)
+		}
+		w.Flush()
+	}
+
+	if total > len(files) {
+		fmt.Fprintf(&b, "(showing %d of %d open files; narrow the query or raise 'limit' to see more)\n", len(files), total)
+	}
+	if args.Deleted && total > 0 {
+		fmt.Fprintf(&b, "Deleted-but-open files hold about %s of disk space; the space is freed when the processes above close them or exit.\n", formatSize(deletedSize))
+	}
+	if len(denied) > 0 {
+		shown := denied[:min(len(denied), 10)]
+		fmt.Fprintf(&b, "(permission denied for %d processes, their open files are not listed: %s", len(denied), strings.Join(shown, ", "))
+		if len(denied) > len(shown) {
+			b.WriteString(", ...")
+		}
+		b.WriteString("; run as root or as the process owner to inspect them)\n")
+	}
+	return b.String(), nil
+}
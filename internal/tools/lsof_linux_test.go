@@ -0,0 +1,133 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMappedFiles(t *testing.T) {
+	root := useProcRoot(t)
+	files := t.TempDir()
+	writeTree(t, files, map[string]string{
+		"libc.so.6":        strings.Repeat("x", 100),
+		"my app/data.bin":  strings.Repeat("x", 30),
+		"still-mapped.bin": strings.Repeat("x", 50),
+	})
+	libc := filepath.Join(files, "libc.so.6")
+	data := filepath.Join(files, "my app", "data.bin")
+	gone := filepath.Join(files, "gone.bin")
+	writeProcFile(t, root, "7/maps", strings.Join([]string{
+		"00400000-00452000 r-xp 00000000 08:01 1310734                            " + gone + " (deleted)",
+		"55d0c9a28000-55d0c9a49000 rw-p 00000000 00:00 0                          [heap]",
+		"7f3e2c000000-7f3e2c021000 rw-p 00000000 00:00 0 ",
+		"7f3e2d200000-7f3e2d228000 r--p 00000000 08:01 2097321                    " + libc,
+		"7f3e2d228000-7f3e2d3bd000 r-xp 00028000 08:01 2097321                    " + libc,
+		"7f3e2d500000-7f3e2d501000 r--s 00000000 08:01 2097400                    " + data,
+		"7ffd1b5e6000-7ffd1b5ea000 r--p 00000000 00:00 0                          [vvar]",
+		"short line",
+	}, "\n")+"\n")
+	// 已删除的文件通过 map_files 中不补零的地址访问
+	if err := os.MkdirAll(filepath.Join(root, "7", "map_files"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(files, "still-mapped.bin"), filepath.Join(root, "7", "map_files", "400000-452000")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []openFile{
+		{FD: "mem", Type: "REG", Name: gone, Deleted: true, Size: 50},
+		{FD: "mem", Type: "REG", Name: libc, Size: 100},
+		{FD: "mem", Type: "REG", Name: data, Size: 30},
+	}
+	if got := readMappedFiles(7); !reflect.DeepEqual(got, want) {
+		t.Errorf("readMappedFiles() =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := readMappedFiles(8); got != nil {
+		t.Errorf("readMappedFiles() of a missing process = %+v, want nil", got)
+	}
+}
+
+func TestMatchesLsof(t *testing.T) {
+	sockets := map[uint64]socketInfo{
+		100: {Proto: "tcp", State: "LISTEN", LocalPort: 8080},
+		200: {Proto: "tcp", State: "ESTAB", LocalPort: 41412, RemotePort: 8080},
+	}
+	log := openFile{Name: "/var/log/app.log", Type: "REG"}
+	deleted := openFile{Name: "/var/log/old.log", Type: "REG", Deleted: true}
+	sock := openFile{Name: "tcp 0.0.0.0:8080->0.0.0.0:* (LISTEN)", Type: "sock"}
+
+	tests := []struct {
+		name   string
+		file   openFile
+		args   lsofArgs
+		target string
+		want   bool
+	}{
+		{name: "exact path", file: log, args: lsofArgs{Path: "/var/log/app.log"}, want: true},
+		{name: "file under directory", file: log, args: lsofArgs{Path: "/var/log"}, want: true},
+		{name: "root directory", file: log, args: lsofArgs{Path: "/"}, want: true},
+		{name: "sibling with common prefix", file: log, args: lsofArgs{Path: "/var/lo"}, want: false},
+		{name: "other file", file: log, args: lsofArgs{Path: "/var/log/app.log.1"}, want: false},
+		{name: "socket never matches a path", file: sock, args: lsofArgs{Path: "/"}, target: "socket:[100]", want: false},
+		{name: "deleted only", file: log, args: lsofArgs{Deleted: true}, want: false},
+		{name: "deleted under path", file: deleted, args: lsofArgs{Deleted: true, Path: "/var/log"}, want: true},
+		{name: "listening port", file: sock, args: lsofArgs{Port: 8080}, target: "socket:[100]", want: true},
+		{name: "remote port is not matched", file: sock, args: lsofArgs{Port: 8080}, target: "socket:[200]", want: false},
+		{name: "unknown socket", file: sock, args: lsofArgs{Port: 8080}, target: "socket:[300]", want: false},
+		{name: "port on a regular file", file: log, args: lsofArgs{Port: 8080}, target: "/var/log/app.log", want: false},
+		{name: "no conditions", file: log, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesLsof(tt.file, tt.args, sockets, tt.target); got != tt.want {
+				t.Errorf("matchesLsof(%+v, %+v, %q) = %v, want %v", tt.file, tt.args, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLsofCanonicalizesPath(t *testing.T) {
+	root := useProcRoot(t)
+	base := t.TempDir()
+	writeTree(t, base, map[string]string{"real/data.txt": "data"})
+	if err := os.Symlink("real", filepath.Join(base, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	// 与内核一样，fd 链接指向解析过符号链接的真实路径
+	target, err := filepath.EvalSymlinks(filepath.Join(base, "real", "data.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeProcFile(t, root, "42/comm", "app\n")
+	if err := os.MkdirAll(filepath.Join(root, "42", "fd"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, "42", "fd", "3")); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(base, "real"))
+
+	for _, path := range []string{"data.txt", "../alias/data.txt", "../alias", "./../real/../alias/"} {
+		t.Run(path, func(t *testing.T) {
+			args, _ := json.Marshal(map[string]any{"path": path, "format": "json"})
+			output, err := executeLsof(context.Background(), args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []openFile
+			if err := json.Unmarshal([]byte(output), &files); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, output)
+			}
+			if len(files) != 1 || files[0].Name != target || files[0].PID != 42 || files[0].FD != "3" || files[0].Size != 4 {
+				t.Errorf("executeLsof(path %q) = %+v, want fd 3 of process 42", path, files)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxOutputBytes 是观察结果的默认最大字节数。
+	DefaultMaxOutputBytes = 16 * 1024
+	// DefaultMaxOutputLines 是观察结果的默认最大行数。
+	DefaultMaxOutputLines = 400
+	// maxStoredOutputs 是 OutputStore 最多保留的完整输出数量，超出后丢弃最早的输出。
+	maxStoredOutputs = 32
+	// pageOverheadBytes 是 read_output 每页为页眉和页脚预留的字节数。
+	pageOverheadBytes = 200
+	// minPageBytes 是 read_output 每页正文的最小字节数，避免字节数限制过小时无法返回任何内容。
+	minPageBytes = 256
+)
+
+// OutputStore 保存被截断的工具输出的完整内容，供 read_output 工具按需分页读取或搜索。
+// 它可以在多个 goroutine 中安全使用。
+type OutputStore struct {
+	mu      sync.Mutex
+	next    int
+	outputs map[string]string
+	order   []string // 保存顺序，用于淘汰最早的输出
+}
+
+// NewOutputStore 创建一个空的 OutputStore。
+func NewOutputStore() *OutputStore {
+	return &OutputStore{outputs: make(map[string]string)}
+}
+
+// Save 保存一份完整输出并返回其 ID。
+func (s *OutputStore) Save(output string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+	id := fmt.Sprintf("out-%d", s.next)
+	s.outputs[id] = output
+	s.order = append(s.order, id)
+	if len(s.order) > maxStoredOutputs {
+		delete(s.outputs, s.order[0])
+		s.order = s.order[1:]
+	}
+	return id
+}
+
+// Get 按 ID 取回完整输出。
+func (s *OutputStore) Get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	output, ok := s.outputs[id]
+	return output, ok
+}
+
+// NewReadOutputTool 创建 read_output 工具，用于分页读取或搜索 registry 中保存的被截断输出。
+// 每页的大小同样受 registry 的输出限制约束，因此它自身的输出不会再被截断。
+func NewReadOutputTool(registry *Registry) Tool {
+	return NewReadOnlyTool(readOutputDefinition, func(ctx context.Context, rawArgs json.RawMessage) (Result, error) {
+		output, err := readOutput(registry, rawArgs)
+		return Result{Output: output}, err
+	})
+}
+
+// defaultReadOutputLimit 是 read_output 默认每页返回的行数。
+const defaultReadOutputLimit = 100
+
+func readOutput(registry *Registry, rawArgs json.RawMessage) (string, error) {
+	var args struct {
+		ID      string `json:"id"`
+		Offset  int    `json:"offset"`
+		Limit   int    `json:"limit"`
+		Pattern string `json:"pattern"`
+	}
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return "", fmt.Errorf("error decoding 'read_output' arguments: %w", err)
+	}
+	if args.ID == "" {
+		return "", fmt.Errorf("'id' parameter is required for read_output")
+	}
+	output, ok := registry.Outputs().Get(args.ID)
+	if !ok {
+		return "", fmt.Errorf("output '%s' not found; only the %d most recent truncated outputs of this session are kept", args.ID, maxStoredOutputs)
+	}
+
+	maxBytes, maxLines := registry.OutputLimits()
+	if args.Offset < 1 {
+		args.Offset = 1
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultReadOutputLimit
+	}
+	if maxLines > 0 && args.Limit > maxLines-2 {
+		args.Limit = max(maxLines-2, 1)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	// selected 保存要分页的行号（从 0 开始）：全部行，或者匹配 pattern 的行
+	selected := make([]int, 0, len(lines))
+	unit := "lines"
+	if args.Pattern != "" {
+		re, err := regexp.Compile(args.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern '%s': %w", args.Pattern, err)
+		}
+		for i, line := range lines {
+			if re.MatchString(line) {
+				selected = append(selected, i)
+			}
+		}
+		unit = "matching lines"
+		if len(selected) == 0 {
+			return fmt.Sprintf("%s: no lines match pattern '%s' (%d lines total)", args.ID, args.Pattern, len(lines)), nil
+		}
+	} else {
+		for i := range lines {
+			selected = append(selected, i)
+		}
+	}
+	if args.Offset > len(selected) {
+		return "", fmt.Errorf("offset %d is past the end of %s (%d %s)", args.Offset, args.ID, len(selected), unit)
+	}
+
+	// 每页同样受字节数限制约束，预留一部分给页眉和页脚
+	budget := max(maxBytes-pageOverheadBytes, minPageBytes)
+	if maxBytes <= 0 {
+		budget = len(output) + len(lines)*12
+	}
+	var body strings.Builder
+	end := args.Offset - 1
+	for ; end < len(selected) && end-(args.Offset-1) < args.Limit; end++ {
+		line := lines[selected[end]]
+		if len(line) > budget/2 {
+			line = truncateBytes(line, budget/2) + " ..."
+		}
+		entry := fmt.Sprintf("%d: %s\n", selected[end]+1, line)
+		if body.Len()+len(entry) > budget && end > args.Offset-1 {
+			break
+		}
+		body.WriteString(entry)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s %d-%d of %d\n", args.ID, unit, args.Offset, end, len(selected))
+	b.WriteString(body.String())
+	if end < len(selected) {
+		fmt.Fprintf(&b, "(more available: call read_output again with offset=%d)\n", end+1)
+	}
+	return b.String(), nil
+}
+
+// exceedsLimits 判断输出是否超出字节数或行数限制，限制为 0 表示不限制。
+func exceedsLimits(output string, maxBytes, maxLines int) bool {
+	if maxBytes > 0 && len(output) > maxBytes {
+		return true
+	}
+	return maxLines > 0 && strings.Count(strings.TrimSuffix(output, "\n"), "\n")+1 > maxLines
+}
+
+// truncateOutput 保留输出开头和结尾各一半的预算，中间替换为截断标记和 note 说明。
+// 调用方应先用 exceedsLimits 确认输出确实需要截断。
+func truncateOutput(output string, maxBytes, maxLines int, note string) string {
+	if maxBytes <= 0 {
+		maxBytes = len(output)
+	}
+	lines := strings.SplitAfter(output, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if maxLines <= 0 {
+		maxLines = len(lines)
+	}
+
+	// 行数和字节数的预算在开头与结尾之间平分
+	headLines, tailLines := (maxLines+1)/2, maxLines/2
+	headBytes, tailBytes := maxBytes/2, maxBytes/2
+
+	var b strings.Builder
+	head, used := 0, 0
+	for _, line := range lines {
+		if head >= headLines || used+len(line) > headBytes {
+			break
+		}
+		b.WriteString(line)
+		head++
+		used += len(line)
+	}
+	if head == 0 {
+		// 单行就超出预算（例如一行压缩过的 JSON）时，至少保留该行的开头部分
+		b.WriteString(truncateBytes(lines[0], headBytes) + " ...")
+	}
+	if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+
+	tail, used := 0, 0
+	for i := len(lines) - 1; i >= head; i-- {
+		if tail >= tailLines || used+len(lines[i]) > tailBytes {
+			break
+		}
+		tail++
+		used += len(lines[i])
+	}
+
+	fmt.Fprintf(&b, "[output truncated: %d lines omitted]\n", len(lines)-head-tail)
+	if note != "" {
+		b.WriteString(note + "\n")
+	}
+	for _, line := range lines[len(lines)-tail:] {
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// truncateBytes 将字符串截断到不超过 n 字节，且不会截断多字节字符。
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
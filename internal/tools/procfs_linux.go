@@ -0,0 +1,271 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// procRoot 是 procfs 的挂载点，测试中会替换为包含样例文件的临时目录。
+var procRoot = "/proc"
+
+// clockTicks 是 /proc 中时间字段使用的时钟频率（USER_HZ）。
+// 它在所有主流 Linux 架构上都是 100，纯 Go 无法在不使用 cgo 的情况下调用 sysconf 获取。
+const clockTicks = 100
+
+// listProcesses 读取 /proc/<pid>/stat、status 和 cmdline 采集所有进程的信息。
+// 在读取过程中退出的进程会被忽略。
+func listProcesses(ctx context.Context) ([]processInfo, error) {
+	pids, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := readUptime()
+	if err != nil {
+		return nil, err
+	}
+	pageKB := int64(os.Getpagesize() / 1024)
+
+	procs := make([]processInfo, 0, len(pids))
+	for _, pid := range pids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		p := processInfo{
+			PID:     pid,
+			PPID:    stat.ppid,
+			State:   stat.state,
+			RSSKB:   stat.rssPages * pageKB,
+			Name:    stat.comm,
+			Command: readCmdline(pid),
+			User:    lookupUsername(readProcUID(pid)),
+		}
+		p.CPU = stat.cpuPercent(uptime)
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// listPIDs 返回 /proc 下所有进程的 PID。
+func listPIDs() ([]int, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", procRoot, err)
+	}
+	var pids []int
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// procStat 是 /proc/<pid>/stat 中用到的字段。
+type procStat struct {
+	comm         string
+	state        string
+	ppid         int
+	utime, stime int64
+	startTicks   int64
+	rssPages     int64
+}
+
+// cpuPercent 与 ps 一致，返回进程启动以来消耗的 CPU 时间占经过时间的百分比。uptime 是系统启动以来的秒数。
+func (s procStat) cpuPercent(uptime float64) float64 {
+	elapsed := uptime - float64(s.startTicks)/clockTicks
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.utime+s.stime) / clockTicks / elapsed * 100
+}
+
+// readProcStat 解析 /proc/<pid>/stat。
+// 进程名位于括号中且可能包含空格和括号，因此以最后一个 ')' 作为分界。
+func readProcStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+	line := string(data)
+	open, end := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return procStat{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	// fields[0] 是 stat 中的第 3 个字段（state）
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	field := func(n int) int64 {
+		v, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return v
+	}
+	return procStat{
+		comm:       line[open+1 : end],
+		state:      fields[0],
+		ppid:       int(field(4)),
+		utime:      field(14),
+		stime:      field(15),
+		startTicks: field(22),
+		rssPages:   field(24),
+	}, nil
+}
+
+// readCmdline 读取进程的命令行，参数之间以空格分隔。内核线程的命令行为空。
+func readCmdline(pid int) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+}
+
+// readProcUID 从 /proc/<pid>/status 读取进程的真实 UID，读取失败时返回空字符串。
+func readProcUID(pid int) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return ""
+	}
+	for line := range strings.SplitSeq(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				return fields[0]
+			}
+		}
+	}
+	return ""
+}
+
+// readUptime 读取系统启动以来经过的秒数。
+func readUptime() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, "uptime"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read uptime: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("malformed %s/uptime", procRoot)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// usernames 缓存 UID 到用户名的映射，避免对每个进程重复查询。
+var usernames sync.Map
+
+// lookupUsername 将 UID 转换为用户名，找不到对应用户时返回 UID 本身。
+func lookupUsername(uid string) string {
+	if uid == "" {
+		return ""
+	}
+	if name, ok := usernames.Load(uid); ok {
+		return name.(string)
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	usernames.Store(uid, name)
+	return name
+}
+
+// procFD 是进程打开的一个文件描述符。
+type procFD struct {
+	fd     int
+	target string // 符号链接 /proc/<pid>/fd/<fd> 指向的目标，例如文件路径或 "socket:[12345]"
+}
+
+// readFDs 列出进程打开的文件描述符。没有权限读取其他用户的进程时返回 os.ErrPermission。
+func readFDs(pid int) ([]procFD, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fds := make([]procFD, 0, len(entries))
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		fds = append(fds, procFD{fd: fd, target: target})
+	}
+	return fds, nil
+}
+
+// readComm 读取进程名。
+func readComm(pid int) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// processRef 标识一个进程。
+type processRef struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name"`
+}
+
+// socketOwners 扫描所有进程的文件描述符，返回 socket inode 到所属进程的映射，
+// 以及因权限不足而无法检查的进程数。一个 socket 可能被多个进程共享（例如 fork 之后）。
+func socketOwners(ctx context.Context) (map[uint64][]processRef, int, error) {
+	pids, err := listPIDs()
+	if err != nil {
+		return nil, 0, err
+	}
+	owners := make(map[uint64][]processRef)
+	denied := 0
+	for _, pid := range pids {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		fds, err := readFDs(pid)
+		if err != nil {
+			if os.IsPermission(err) {
+				denied++
+			}
+			continue
+		}
+		var name string
+		for _, fd := range fds {
+			inode, ok := socketInode(fd.target)
+			if !ok {
+				continue
+			}
+			if name == "" {
+				name = readComm(pid)
+			}
+			if refs := owners[inode]; len(refs) == 0 || refs[len(refs)-1].PID != pid {
+				owners[inode] = append(refs, processRef{PID: pid, Name: name})
+			}
+		}
+	}
+	return owners, denied, nil
+}
+
+// socketInode 从 "socket:[12345]" 形式的链接目标中解析出 inode。
+func socketInode(target string) (uint64, bool) {
+	rest, ok := strings.CutPrefix(target, "socket:[")
+	if !ok {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(strings.TrimSuffix(rest, "]"), 10, 64)
+	return inode, err == nil
+}
@@ -0,0 +1,185 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// useProcRoot 把 procRoot 替换为临时目录，测试结束后恢复。
+func useProcRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	old := procRoot
+	procRoot = root
+	t.Cleanup(func() { procRoot = old })
+	return root
+}
+
+// writeProcFile 在 procRoot 下写入一个样例文件。
+func writeProcFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// statLine 生成一行与内核格式相同的 /proc/<pid>/stat，其余字段取真实进程中的典型值。
+func statLine(pid int, comm, state string, ppid int, utime, stime, startTicks, rssPages int64) string {
+	return fmt.Sprintf("%d (%s) %s %d %d %d 0 -1 4194560 1234 5678 10 0 %d %d 0 0 20 0 1 0 %d 23456789 %d 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0\n",
+		pid, comm, state, ppid, pid, pid, utime, stime, startTicks, rssPages)
+}
+
+func TestReadProcStat(t *testing.T) {
+	root := useProcRoot(t)
+	tests := []struct {
+		name    string
+		stat    string
+		want    procStat
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			stat: statLine(1, "systemd", "S", 0, 120, 80, 5, 3000),
+			want: procStat{comm: "systemd", state: "S", ppid: 0, utime: 120, stime: 80, startTicks: 5, rssPages: 3000},
+		},
+		{
+			name: "comm with spaces",
+			stat: statLine(2, "Web Content", "R", 1, 1, 2, 3, 4),
+			want: procStat{comm: "Web Content", state: "R", ppid: 1, utime: 1, stime: 2, startTicks: 3, rssPages: 4},
+		},
+		{
+			name: "comm with parentheses",
+			stat: statLine(3, "a) (b) S 9", "Z", 2, 5, 6, 7, 8),
+			want: procStat{comm: "a) (b) S 9", state: "Z", ppid: 2, utime: 5, stime: 6, startTicks: 7, rssPages: 8},
+		},
+		{name: "no parentheses", stat: "4 bash S 1 2 3\n", wantErr: true},
+		{name: "too few fields", stat: "5 (bash) S 1 2 3\n", wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pid := 100 + i
+			writeProcFile(t, root, filepath.Join(strconv.Itoa(pid), "stat"), tt.stat)
+			got, err := readProcStat(pid)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("readProcStat() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("readProcStat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcStatCPUPercent(t *testing.T) {
+	tests := []struct {
+		name   string
+		stat   procStat
+		uptime float64
+		want   float64
+	}{
+		// 启动于第 500 秒，运行了 500 秒，共消耗 50 秒 CPU 时间
+		{name: "ten percent", stat: procStat{utime: 3000, stime: 2000, startTicks: 50000}, uptime: 1000, want: 10},
+		{name: "several cores", stat: procStat{utime: 20000, stime: 0, startTicks: 0}, uptime: 100, want: 200},
+		{name: "idle", stat: procStat{startTicks: 100}, uptime: 1000, want: 0},
+		{name: "started just now", stat: procStat{utime: 5, startTicks: 100000}, uptime: 1000, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stat.cpuPercent(tt.uptime); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("cpuPercent(%v) = %v, want %v", tt.uptime, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPsReadsProcFixtures(t *testing.T) {
+	root := useProcRoot(t)
+	writeProcFile(t, root, "uptime", "1000.00 3500.00\n")
+	processes := []struct {
+		pid     int
+		comm    string
+		ppid    int
+		cmdline string
+	}{
+		{1, "systemd", 0, "/sbin/init\x00splash\x00"},
+		{2, "kthreadd", 0, ""},
+		{42, "nginx", 1, "nginx: master process /usr/sbin/nginx\x00"},
+	}
+	for _, p := range processes {
+		dir := strconv.Itoa(p.pid)
+		writeProcFile(t, root, filepath.Join(dir, "stat"), statLine(p.pid, p.comm, "S", p.ppid, 3000, 2000, 50000, 256))
+		writeProcFile(t, root, filepath.Join(dir, "status"), "Name:\t"+p.comm+"\nUid:\t0\t0\t0\t0\nGid:\t0\t0\t0\t0\n")
+		writeProcFile(t, root, filepath.Join(dir, "cmdline"), p.cmdline)
+	}
+	// 不是进程的目录和文件被忽略
+	writeProcFile(t, root, filepath.Join("net", "tcp"), "")
+
+	procs, err := listProcesses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != len(processes) {
+		t.Fatalf("listProcesses() returned %d processes, want %d", len(procs), len(processes))
+	}
+	slices.SortFunc(procs, func(a, b processInfo) int { return a.PID - b.PID })
+	first := procs[0]
+	if first.Name != "systemd" || first.Command != "/sbin/init splash" || first.User != "root" ||
+		first.RSSKB != 256*int64(os.Getpagesize()/1024) || math.Abs(first.CPU-10) > 1e-9 {
+		t.Errorf("process 1 = %+v", first)
+	}
+
+	tests := []struct {
+		name string
+		args string
+		want []int
+	}{
+		{name: "no filter", args: `{"format":"json"}`, want: []int{1, 2, 42}},
+		{name: "ppid 0", args: `{"ppid":0,"format":"json"}`, want: []int{1, 2}},
+		{name: "ppid 1", args: `{"ppid":1,"format":"json"}`, want: []int{42}},
+		{name: "name", args: `{"name":"nginx","format":"json"}`, want: []int{42}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executePs(context.Background(), json.RawMessage(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var listed []processInfo
+			if err := json.Unmarshal([]byte(output), &listed); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, output)
+			}
+			var pids []int
+			for _, p := range listed {
+				pids = append(pids, p.PID)
+			}
+			if !slices.Equal(pids, tt.want) {
+				t.Errorf("executePs(%s) listed %v, want %v", tt.args, pids, tt.want)
+			}
+		})
+	}
+
+	output, err := executePs(context.Background(), json.RawMessage(`{"ppid":7}`))
+	if err != nil || !strings.Contains(output, "No matching processes") {
+		t.Errorf("executePs(ppid 7) = %q, %v; want no matches", output, err)
+	}
+}
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultPsLimit 是 ps 工具默认最多返回的进程数。
+const defaultPsLimit = 100
+
+// processInfo 是一个进程的快照。各平台的 listProcesses 负责采集，过滤、排序和格式化在此统一完成。
+type processInfo struct {
+	PID     int     `json:"pid"`
+	PPID    int     `json:"ppid"`
+	User    string  `json:"user"`
+	State   string  `json:"state"`
+	CPU     float64 `json:"cpu_percent"` // 进程生命周期内的平均 CPU 占用，与 ps 的 %CPU 含义相同
+	RSSKB   int64   `json:"rss_kb"`
+	Name    string  `json:"name"`
+	Command string  `json:"command"`
+}
+
+// psArgs 是 ps 工具的参数。
+type psArgs struct {
+	User   string  `json:"user"`
+	Name   string  `json:"name"`
+	PID    int     `json:"pid"`
+	PPID   *int    `json:"ppid"` // 为 nil 表示不过滤，0 是合法的父进程 ID
+	MinCPU float64 `json:"min_cpu"`
+	MinRSS int64   `json:"min_rss_mb"`
+	Sort   string  `json:"sort"`
+	Limit  int     `json:"limit"`
+	Format string  `json:"format"`
+}
+
+// executePs 列出当前运行的进程。
+// 进程信息由各平台的 listProcesses 直接采集（Linux 上读取 /proc），不依赖 ps 命令的版本和输出格式。
+func executePs(ctx context.Context, rawArgs json.RawMessage) (string, error) {
+	var args psArgs
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return "", fmt.Errorf("error decoding 'ps' arguments: %w", err)
+	}
+	var nameRe *regexp.Regexp
+	if args.Name != "" {
+		var err error
+		if nameRe, err = regexp.Compile(args.Name); err != nil {
+			return "", fmt.Errorf("invalid 'name' regular expression '%s': %w", args.Name, err)
+		}
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultPsLimit
+	}
+	if args.Format == "" {
+		args.Format = "table"
+	}
+	if args.Format != "table" && args.Format != "json" {
+		return "", fmt.Errorf("unsupported 'format' '%s', expected 'table' or 'json'", args.Format)
+	}
+
+	procs, err := listProcesses(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	matched := procs[:0]
+	for _, p := range procs {
+		switch {
+		case args.User != "" && p.User != args.User,
+			args.PID != 0 && p.PID != args.PID,
+			args.PPID != nil && p.PPID != *args.PPID,
+			p.CPU < args.MinCPU,
+			p.RSSKB < args.MinRSS*1024,
+			nameRe != nil && !nameRe.MatchString(p.Name) && !nameRe.MatchString(p.Command):
+			continue
+		}
+		matched = append(matched, p)
+	}
+	if len(matched) == 0 {
+		return "No matching processes found.", nil
+	}
+	if err := sortProcesses(matched, args.Sort); err != nil {
+		return "", err
+	}
+
+	total := len(matched)
+	if len(matched) > args.Limit {
+		matched = matched[:args.Limit]
+	}
+	for i := range matched {
+		if len(matched[i].Command) > maxCommandWidth {
+			matched[i].Command = truncateBytes(matched[i].Command, maxCommandWidth) + "..."
+		}
+	}
+	if args.Format == "json" {
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(matched); err != nil {
+			return "", fmt.Errorf("error encoding processes: %w", err)
+		}
+		return b.String(), nil
+	}
+	return formatProcesses(matched, total), nil
+}
+
+// sortProcesses 按指定字段排序。cpu 和 rss 按降序，其余按升序。
+func sortProcesses(procs []processInfo, by string) error {
+	var less func(a, b processInfo) bool
+	switch by {
+	case "", "pid":
+		less = func(a, b processInfo) bool { return a.PID < b.PID }
+	case "cpu":
+		less = func(a, b processInfo) bool { return a.CPU > b.CPU }
+	case "rss", "memory":
+		less = func(a, b processInfo) bool { return a.RSSKB > b.RSSKB }
+	case "name":
+		less = func(a, b processInfo) bool { return a.Name < b.Name }
+	default:
+		return fmt.Errorf("unsupported 'sort' field '%s', expected one of pid, cpu, rss, name", by)
+	}
+	sort.SliceStable(procs, func(i, j int) bool { return less(procs[i], procs[j]) })
+	return nil
+}
+
+// maxCommandWidth 是输出中命令行的最大字节数。
+const maxCommandWidth = 200
+
+// formatProcesses 将进程列表格式化为紧凑的表格。
+func formatProcesses(procs []processInfo, total int) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tPPID\tUSER\tSTATE\t%CPU\tRSS\tCOMMAND")
+	for _, p := range procs {
+		command := p.Command
+		if command == "" {
+			command = "[" + p.Name + "]"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.1f\t%s\t%s\n",
+			p.PID, orDash(p.PPID), valueOrDash(p.User), valueOrDash(p.State), p.CPU, formatSize(p.RSSKB*1024), command)
+	}
+	w.Flush()
+	if total > len(procs) {
+		fmt.Fprintf(&b, "(showing %d of %d matching processes; narrow the filters or raise 'limit' to see more)\n", len(procs), total)
+	}
+	return b.String()
+}
+
+func orDash(n int) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,298 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/policy"
+	"github.com/DoraZa/mini-agent/internal/redact"
+)
+
+// Result 是一次工具执行的结果。
+type Result struct {
+	Output string // 反馈给模型的输出
+
+	// OutputID 在输出因超出限制被截断时，是完整输出在 OutputStore 中的 ID
+	OutputID string
+
+	// Command 和 ExitCode 由 Registry 填写：工具实际执行的命令行和退出码，见 commandLog.summary
+	Command  string
+	ExitCode int
+
+	// Redactions 是输出在截断和保存之前被替换的敏感信息的数量，见 Registry.SetRedactor
+	Redactions redact.Report
+}
+
+// Tool 是所有工具都需要实现的接口。
+// 新增工具时只需实现该接口并注册到 Registry 中，无需修改 Agent 的核心代码。
+type Tool interface {
+	// Name 返回工具名称，需要与 Definition 中的函数名一致。
+	Name() string
+	// Definition 返回发送给 LLM 的工具定义（JSON Schema）。
+	Definition() llm.Tool
+	// Execute 使用模型生成的 JSON 参数执行工具。
+	Execute(ctx context.Context, args json.RawMessage) (Result, error)
+}
+
+// ReadOnlyTool 可以由 Tool 额外实现，声明工具只读取系统状态而不做任何修改。
+// 同一步中的多个只读工具调用可以被并发执行。
+type ReadOnlyTool interface {
+	ReadOnly() bool
+}
+
+// ExecuteFunc 是工具执行逻辑的函数形式。
+type ExecuteFunc func(ctx context.Context, args json.RawMessage) (Result, error)
+
+// NewTool 用一份工具定义和一个执行函数构造一个 Tool，适合实现简单的工具。
+func NewTool(definition llm.Tool, execute ExecuteFunc) Tool {
+	return &funcTool{definition: definition, execute: execute}
+}
+
+// NewReadOnlyTool 与 NewTool 相同，但构造的工具实现了 ReadOnlyTool，会被视为只读工具。
+func NewReadOnlyTool(definition llm.Tool, execute ExecuteFunc) Tool {
+	return &funcTool{definition: definition, execute: execute, readOnly: true}
+}
+
+type funcTool struct {
+	definition llm.Tool
+	execute    ExecuteFunc
+	readOnly   bool
+	paths      []PathArgument
+}
+
+func (t *funcTool) Name() string                  { return t.definition.Function.Name }
+func (t *funcTool) Definition() llm.Tool          { return t.definition }
+func (t *funcTool) ReadOnly() bool                { return t.readOnly }
+func (t *funcTool) PathArguments() []PathArgument { return t.paths }
+func (t *funcTool) Execute(ctx context.Context, args json.RawMessage) (Result, error) {
+	return t.execute(ctx, args)
+}
+
+// Registry 保存所有可用的工具，工具定义和工具执行都从这里派生。
+// 它可以在多个 goroutine 中安全使用。
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string // 注册顺序，保证发送给 LLM 的工具定义顺序稳定
+
+	schemas  map[string]*schema // 按工具名称保存的参数 schema，用于在执行前校验参数
+	sandbox  *policy.Sandbox    // 限制工具可以访问的文件系统路径，为 nil 时不限制
+	redactor *redact.Redactor   // 替换输出中的敏感信息，为 nil 时不替换
+
+	defaultTimeout time.Duration            // 未单独配置的工具的执行超时，0 表示不限制
+	timeouts       map[string]time.Duration // 按工具名称配置的执行超时
+
+	outputs        *OutputStore // 被截断输出的完整内容
+	maxOutputBytes int          // 单次输出的最大字节数，0 表示不限制
+	maxOutputLines int          // 单次输出的最大行数，0 表示不限制
+}
+
+// NewRegistry 创建一个空的工具注册表，使用默认的输出大小限制。
+func NewRegistry() *Registry {
+	return &Registry{
+		tools:          make(map[string]Tool),
+		schemas:        make(map[string]*schema),
+		outputs:        NewOutputStore(),
+		maxOutputBytes: DefaultMaxOutputBytes,
+		maxOutputLines: DefaultMaxOutputLines,
+	}
+}
+
+// DefaultRegistry 是包含所有内置工具的默认注册表。
+// 其他包可以在 init 中调用 Register 向其中添加自定义工具。
+var DefaultRegistry = NewRegistry()
+
+// Register 向默认注册表中注册一个工具。
+func Register(tool Tool) {
+	DefaultRegistry.Register(tool)
+}
+
+// Register 注册一个工具。同名工具会被替换（保留原有顺序），便于覆盖内置实现。
+func (r *Registry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := tool.Name()
+	if _, exists := r.tools[name]; !exists {
+		r.order = append(r.order, name)
+	}
+	r.tools[name] = tool
+	// 无法解析的 schema 不阻止注册，只是跳过对该工具参数的校验
+	r.schemas[name], _ = compileSchema(tool.Definition().Function.Parameters)
+}
+
+// SetTimeouts 设置工具的执行超时。perTool 中的配置优先于 defaultTimeout，0 表示不限制。
+func (r *Registry) SetTimeouts(defaultTimeout time.Duration, perTool map[string]time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultTimeout = defaultTimeout
+	r.timeouts = perTool
+}
+
+// Timeout 返回指定工具的执行超时，0 表示不限制。
+func (r *Registry) Timeout(name string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if timeout, ok := r.timeouts[name]; ok {
+		return timeout
+	}
+	return r.defaultTimeout
+}
+
+// SetRedactor 设置在输出被截断和保存之前替换其中敏感信息的 Redactor，为 nil 时不替换。
+func (r *Registry) SetRedactor(redactor *redact.Redactor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.redactor = redactor
+}
+
+// SetOutputLimits 设置单次工具输出的最大字节数和行数，0 表示不限制。
+// 超出限制的输出只保留开头和结尾，完整内容保存在 Outputs 中，可以通过 read_output 工具读取。
+func (r *Registry) SetOutputLimits(maxBytes, maxLines int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxOutputBytes = maxBytes
+	r.maxOutputLines = maxLines
+}
+
+// OutputLimits 返回单次工具输出的最大字节数和行数。
+func (r *Registry) OutputLimits() (maxBytes, maxLines int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.maxOutputBytes, r.maxOutputLines
+}
+
+// Outputs 返回保存被截断输出完整内容的 OutputStore。
+func (r *Registry) Outputs() *OutputStore {
+	return r.outputs
+}
+
+// Lookup 按名称查找工具。
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// ReadOnly 判断指定的工具是否只读。未实现 ReadOnlyTool 的工具都不是只读的。
+func (r *Registry) ReadOnly(name string) bool {
+	tool, ok := r.Lookup(name)
+	if !ok {
+		return false
+	}
+	readOnly, ok := tool.(ReadOnlyTool)
+	return ok && readOnly.ReadOnly()
+}
+
+// Names 按注册顺序返回所有工具的名称。
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+// Definitions 按注册顺序返回所有工具的定义。
+func (r *Registry) Definitions() []llm.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]llm.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		definitions = append(definitions, r.tools[name].Definition())
+	}
+	return definitions
+}
+
+// Validate 按工具定义中的 JSON Schema 校验一个工具调用的参数。
+// 校验失败时返回的错误列出所有问题，可以直接作为观察结果反馈给模型。
+func (r *Registry) Validate(toolCall llm.ToolCall) error {
+	r.mu.RLock()
+	_, ok := r.tools[toolCall.Function.Name]
+	s := r.schemas[toolCall.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown tool: %s", toolCall.Function.Name)
+	}
+	if s == nil {
+		return nil
+	}
+
+	args := json.RawMessage(toolCall.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	problems := s.validate(args)
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid arguments for tool '%s' (the tool was not run; fix the arguments and call it again):\n- %s",
+		toolCall.Function.Name, strings.Join(problems, "\n- "))
+}
+
+// Execute 校验参数后查找并执行一个工具调用。它不做任何白名单/黑名单检查。
+func (r *Registry) Execute(ctx context.Context, toolCall llm.ToolCall) (Result, error) {
+	tool, ok := r.Lookup(toolCall.Function.Name)
+	if !ok {
+		return Result{}, fmt.Errorf("unknown tool: %s", toolCall.Function.Name)
+	}
+
+	args := json.RawMessage(toolCall.Function.Arguments)
+	// 部分模型在工具没有参数时会返回空字符串
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	if err := r.Validate(toolCall); err != nil {
+		return Result{}, err
+	}
+	args, err := r.sandboxArguments(tool, args)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// 为本次执行加上超时。超时或被取消时，工具启动的外部命令会连同其子进程一起被杀死。
+	timeout := r.Timeout(toolCall.Function.Name)
+	execCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	r.mu.RLock()
+	execCtx = withSandbox(execCtx, r.sandbox)
+	r.mu.RUnlock()
+	execCtx, commands := withCommandLog(execCtx)
+	result, err := tool.Execute(execCtx, args)
+	result.Command, result.ExitCode = commands.summary(toolCall.Function.Name, args, err)
+	switch {
+	case ctx.Err() != nil:
+		// 调用方（例如用户按下 Ctrl-C）取消了执行
+		return result, fmt.Errorf("tool '%s' was cancelled: %w", toolCall.Function.Name, ctx.Err())
+	case execCtx.Err() != nil:
+		return result, fmt.Errorf("tool '%s' timed out after %s and was killed; try narrowing the request (e.g. a more specific path or filter)",
+			toolCall.Function.Name, timeout)
+	}
+
+	// 先替换敏感信息再截断和保存，使截断处附近、被截掉的部分以及 read_output 读取的完整输出中都不会留下密钥
+	r.mu.RLock()
+	redactor := r.redactor
+	r.mu.RUnlock()
+	result.Output, result.Redactions = redactor.Redact(result.Output)
+
+	// 输出过大时截断，避免把几 MB 的内容直接塞进上下文
+	maxBytes, maxLines := r.OutputLimits()
+	if exceedsLimits(result.Output, maxBytes, maxLines) {
+		result.OutputID = r.outputs.Save(result.Output)
+		note := fmt.Sprintf("[full output (%d bytes) saved as '%s'; use the read_output tool to page through or search it]",
+			len(result.Output), result.OutputID)
+		result.Output = truncateOutput(result.Output, maxBytes, maxLines, note)
+	}
+	return result, err
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/redact"
+)
+
+func TestRegistryRedactsBeforeTruncating(t *testing.T) {
+	const secret = "sk-abcdefghijklmnopqrstuvwxyz123456"
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	// 一个密钥位于会被截掉的中间部分，另一个跨越截断处附近
+	lines[100] = "OPENAI_API_KEY=" + secret
+	lines[195] = "token: " + secret
+	output := strings.Join(lines, "\n")
+
+	registry := NewRegistry()
+	registry.Register(builtinTool(llm.Tool{
+		Type:     "function",
+		Function: llm.Function{Name: "dump", Parameters: map[string]any{"type": "object"}},
+	}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return output, nil
+	}))
+	registry.SetOutputLimits(0, 20)
+	redactor, err := redact.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.SetRedactor(redactor)
+
+	result, err := registry.Execute(context.Background(), llm.ToolCall{ID: "call_1", Type: "function", Function: llm.FunctionCall{Name: "dump"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OutputID == "" {
+		t.Fatal("output was not truncated")
+	}
+	full, ok := registry.Outputs().Get(result.OutputID)
+	if !ok {
+		t.Fatalf("full output %s was not saved", result.OutputID)
+	}
+	for name, text := range map[string]string{"truncated output": result.Output, "saved output": full} {
+		if strings.Contains(text, secret) {
+			t.Errorf("%s contains the secret:\n%s", name, text)
+		}
+	}
+	if !strings.Contains(full, "[REDACTED:") {
+		t.Errorf("saved output has no redaction placeholder:\n%s", full)
+	}
+	if result.Redactions.Total() != 2 {
+		t.Errorf("Redactions = %v, want 2", result.Redactions)
+	}
+}
@@ -0,0 +1,195 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/policy"
+)
+
+// PathArgument 描述工具参数中的一个文件系统路径。
+type PathArgument struct {
+	Name    string // 参数名称，值为字符串或字符串数组
+	Write   bool   // 工具是否会写入该路径
+	Dir     string // 相对路径的基准目录，为空表示当前目录
+	Default string // 参数缺省时工具访问的路径，为空表示缺省时不访问文件系统
+}
+
+// PathTool 可以由 Tool 额外实现，声明参数中的哪些属性是文件系统路径。
+// Registry 在执行前会把这些路径规范化为绝对路径，并拒绝沙箱之外的路径。
+type PathTool interface {
+	PathArguments() []PathArgument
+}
+
+// SetSandbox 设置限制工具读写路径的沙箱，为 nil 时不限制。
+func (r *Registry) SetSandbox(sandbox *policy.Sandbox) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sandbox = sandbox
+}
+
+// CheckSandbox 检查一个工具调用访问的路径是否都位于沙箱之内，不执行工具。
+func (r *Registry) CheckSandbox(toolCall llm.ToolCall) error {
+	tool, ok := r.Lookup(toolCall.Function.Name)
+	if !ok {
+		return nil
+	}
+	args := json.RawMessage(toolCall.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	_, err := r.sandboxArguments(tool, args)
+	return err
+}
+
+// sandboxArguments 检查工具参数中的路径，并把它们替换为规范化后的绝对路径，
+// 使工具实际访问的正是通过检查的路径。
+func (r *Registry) sandboxArguments(tool Tool, args json.RawMessage) (json.RawMessage, error) {
+	r.mu.RLock()
+	sandbox := r.sandbox
+	r.mu.RUnlock()
+	pathTool, ok := tool.(PathTool)
+	if sandbox == nil || !ok || len(pathTool.PathArguments()) == 0 {
+		return args, nil
+	}
+
+	values, err := decodeArguments(args)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding '%s' arguments: %w", tool.Name(), err)
+	}
+	resolve := func(arg PathArgument, p string) (string, error) {
+		resolved, err := sandbox.Resolve(p, arg.Dir, arg.Write)
+		if err != nil {
+			return "", fmt.Errorf("tool '%s' refused to access argument '%s': %w", tool.Name(), arg.Name, err)
+		}
+		return resolved, nil
+	}
+
+	for _, arg := range pathTool.PathArguments() {
+		switch value := values[arg.Name].(type) {
+		case nil:
+			if arg.Default != "" {
+				if _, err := resolve(arg, arg.Default); err != nil {
+					return nil, err
+				}
+			}
+		case string:
+			if value == "" {
+				// 工具把空字符串当作缺省处理
+				if arg.Default != "" {
+					if _, err := resolve(arg, arg.Default); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+			resolved, err := resolve(arg, value)
+			if err != nil {
+				return nil, err
+			}
+			values[arg.Name] = resolved
+		case []any:
+			if len(value) == 0 && arg.Default != "" {
+				if _, err := resolve(arg, arg.Default); err != nil {
+					return nil, err
+				}
+			}
+			for i, item := range value {
+				if p, ok := item.(string); ok {
+					resolved, err := resolve(arg, p)
+					if err != nil {
+						return nil, err
+					}
+					value[i] = resolved
+				}
+			}
+		}
+	}
+	return json.Marshal(values)
+}
+
+type sandboxKey struct{}
+
+// withSandbox 返回一个携带沙箱的 ctx，遍历目录的工具用它检查遍历中遇到的符号链接。sandbox 为 nil 时原样返回 ctx。
+func withSandbox(ctx context.Context, sandbox *policy.Sandbox) context.Context {
+	if sandbox == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sandboxKey{}, sandbox)
+}
+
+// sandboxAllows 判断遍历目录时遇到的符号链接 p 指向的路径是否位于沙箱允许读取的目录之内。
+// ctx 中没有沙箱时总是允许。
+func sandboxAllows(ctx context.Context, p string) bool {
+	sandbox, ok := ctx.Value(sandboxKey{}).(*policy.Sandbox)
+	if !ok {
+		return true
+	}
+	_, err := sandbox.Resolve(p, "", false)
+	return err == nil
+}
+
+// ruleArguments 返回用于匹配参数规则的参数：路径参数缺省或为空时补上 PathArgument.Default，
+// 并以 PathArgument.Dir 为基准规范化为绝对路径，使规则看到的正是工具实际访问的路径。
+// 工具没有声明路径参数或参数无法解码时原样返回。
+func (r *Registry) ruleArguments(toolCall llm.ToolCall) string {
+	tool, _ := r.Lookup(toolCall.Function.Name)
+	pathTool, ok := tool.(PathTool)
+	if !ok || len(pathTool.PathArguments()) == 0 {
+		return toolCall.Function.Arguments
+	}
+	values, err := decodeArguments(json.RawMessage(toolCall.Function.Arguments))
+	if err != nil {
+		return toolCall.Function.Arguments
+	}
+
+	for _, arg := range pathTool.PathArguments() {
+		canonical := func(p string) string { return policy.CanonicalPath(policy.JoinPath(arg.Dir, p)) }
+		missing := false
+		switch value := values[arg.Name].(type) {
+		case nil:
+			missing = true
+		case string:
+			missing = value == ""
+			if !missing {
+				values[arg.Name] = canonical(value)
+			}
+		case []any:
+			missing = len(value) == 0
+			for i, item := range value {
+				if p, ok := item.(string); ok {
+					value[i] = canonical(p)
+				}
+			}
+		}
+		if missing && arg.Default != "" {
+			values[arg.Name] = canonical(arg.Default)
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return toolCall.Function.Arguments
+	}
+	return string(data)
+}
+
+// decodeArguments 将工具参数解码为对象，数字保留原始文本。参数为空时返回空对象。
+func decodeArguments(args json.RawMessage) (map[string]any, error) {
+	values := map[string]any{}
+	if len(strings.TrimSpace(string(args))) == 0 {
+		return values, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(args)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		// 参数为 JSON null
+		values = map[string]any{}
+	}
+	return values, nil
+}
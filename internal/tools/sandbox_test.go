@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/policy"
+)
+
+// newPathToolRegistry 注册一个带有读取参数 path（缺省为当前目录）和写入参数 output_file（以 downloads 为基准）的工具。
+func newPathToolRegistry(downloads string) *Registry {
+	registry := NewRegistry()
+	registry.Register(builtinTool(llm.Tool{
+		Type: "function",
+		Function: llm.Function{Name: "probe", Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":        map[string]any{"type": "string"},
+				"paths":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"output_file": map[string]any{"type": "string"},
+			},
+		}},
+	}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return string(args), nil
+	},
+		PathArgument{Name: "path", Default: "."},
+		PathArgument{Name: "paths", Default: "."},
+		PathArgument{Name: "output_file", Write: true, Dir: downloads, Default: downloads},
+	))
+	return registry
+}
+
+func probeCall(args string) llm.ToolCall {
+	return llm.ToolCall{ID: "call_1", Type: "function", Function: llm.FunctionCall{Name: "probe", Arguments: args}}
+}
+
+func TestToolSetCheckRulesSeeCanonicalArguments(t *testing.T) {
+	base := t.TempDir()
+	work := filepath.Join(base, "work")
+	downloads := filepath.Join(base, "downloads")
+	for _, dir := range []string{filepath.Join(work, "secret"), filepath.Join(downloads, "protected")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(work)
+
+	tests := []struct {
+		name    string
+		rule    policy.ToolRule
+		args    string
+		wantErr bool
+	}{
+		{name: "relative path", rule: denyUnder("path", filepath.Join(work, "secret")), args: `{"path":"secret"}`, wantErr: true},
+		{name: "relative path with dot-dot", rule: denyUnder("path", filepath.Join(work, "secret")), args: `{"path":"secret/../secret/x"}`, wantErr: true},
+		{name: "relative path elsewhere", rule: denyUnder("path", filepath.Join(work, "secret")), args: `{"path":"other"}`},
+		{name: "missing path uses default", rule: denyUnder("path", work), args: `{}`, wantErr: true},
+		{name: "empty path uses default", rule: denyUnder("path", work), args: `{"path":""}`, wantErr: true},
+		{name: "empty array uses default", rule: denyUnder("paths", work), args: `{"paths":[]}`, wantErr: true},
+		{name: "relative array items", rule: denyUnder("paths", filepath.Join(work, "secret")), args: `{"paths":["ok","secret/key"]}`, wantErr: true},
+		{name: "output file relative to its directory", rule: denyUnder("output_file", filepath.Join(downloads, "protected")), args: `{"output_file":"protected/x"}`, wantErr: true},
+		{name: "output file not relative to cwd", rule: denyUnder("output_file", filepath.Join(work, "protected")), args: `{"output_file":"protected/x"}`},
+		{name: "missing output file uses default", rule: denyUnder("output_file", downloads), args: `{}`, wantErr: true},
+		{name: "allow rule on default", rule: policy.ToolRule{Name: "only-work", Tool: "probe", Effect: policy.EffectAllow,
+			Args: policy.Conditions{"path": {Under: []string{work}}}}, args: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := policy.NewRuleSet([]policy.ToolRule{tt.rule})
+			if err != nil {
+				t.Fatal(err)
+			}
+			toolSet := &ToolSet{Registry: newPathToolRegistry(downloads), Rules: rules}
+			err = toolSet.Check(probeCall(tt.args))
+			if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "policy rule")) {
+				t.Errorf("Check(%s) = %v, want it denied by the rule", tt.args, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Check(%s) = %v, want allowed", tt.args, err)
+			}
+		})
+	}
+}
+
+func denyUnder(arg, dir string) policy.ToolRule {
+	return policy.ToolRule{Name: "deny-" + arg, Tool: "probe", Effect: policy.EffectDeny,
+		Args: policy.Conditions{arg: {Under: []string{dir}}}}
+}
+
+func TestSandboxedWalksSkipLinksOutsideSandbox(t *testing.T) {
+	base := t.TempDir()
+	work := filepath.Join(base, "work")
+	secret := filepath.Join(base, "secret")
+	for _, dir := range []string{filepath.Join(work, "inner"), secret} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(work, "app.txt"):   "needle in work\n",
+		filepath.Join(secret, "key.txt"): "needle in secret\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// work 中指向 secret 的链接必须被跳过，指向 work 之内的链接仍然可以使用
+	links := map[string]string{
+		filepath.Join(work, "key-link.txt"): filepath.Join(secret, "key.txt"),
+		filepath.Join(work, "secret-dir"):   secret,
+		filepath.Join(work, "app-link.txt"): filepath.Join(work, "app.txt"),
+		filepath.Join(work, "inner-link"):   filepath.Join(work, "inner"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry := NewRegistry()
+	registry.Register(builtinTool(findDefinition, executeFind, PathArgument{Name: "path", Default: "."}))
+	registry.Register(builtinTool(grepDefinition, executeGrep, PathArgument{Name: "paths", Default: "."}))
+	registry.SetSandbox(policy.NewSandbox([]string{work}, nil))
+
+	tests := []struct {
+		name    string
+		tool    string
+		args    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "grep",
+			tool:    "grep",
+			args:    `{"pattern":"needle","paths":["` + work + `"]}`,
+			want:    []string{"app.txt:1:needle in work", "app-link.txt:1:needle in work", "2 symbolic links pointing outside the sandbox"},
+			notWant: []string{"secret"},
+		},
+		{
+			name:    "find following links",
+			tool:    "find",
+			args:    `{"path":"` + work + `","follow_symlinks":true}`,
+			want:    []string{"app-link.txt", "inner-link", "(2 symbolic links pointing outside the sandbox were skipped)"},
+			notWant: []string{"key-link.txt", "secret-dir", "key.txt"},
+		},
+		{
+			name: "find without following links",
+			tool: "find",
+			args: `{"path":"` + work + `","type":"l"}`,
+			want: []string{"key-link.txt", "secret-dir"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call := llm.ToolCall{ID: "call_1", Type: "function", Function: llm.FunctionCall{Name: tt.tool, Arguments: tt.args}}
+			result, err := registry.Execute(context.Background(), call)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(result.Output, s) {
+					t.Errorf("output does not contain %q:\n%s", s, result.Output)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(result.Output, s) {
+					t.Errorf("output contains %q:\n%s", s, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestSandboxResolvesDefaultForEmptyPath(t *testing.T) {
+	base := t.TempDir()
+	work := filepath.Join(base, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// 当前目录在沙箱之外，缺省路径 "." 必须被拒绝
+	t.Chdir(base)
+	registry := newPathToolRegistry(filepath.Join(base, "downloads"))
+	registry.SetSandbox(policy.NewSandbox([]string{work}, nil))
+
+	for _, args := range []string{`{}`, `{"path":""}`, `{"paths":[]}`} {
+		if err := registry.CheckSandbox(probeCall(args)); err == nil || !strings.Contains(err.Error(), "outside the sandbox") {
+			t.Errorf("CheckSandbox(%s) = %v, want the default path refused", args, err)
+		}
+	}
+	if err := registry.CheckSandbox(probeCall(`{"path":"` + work + `","paths":["` + work + `"]}`)); err != nil {
+		t.Errorf("CheckSandbox() = %v, want paths inside the sandbox allowed", err)
+	}
+}
+
+func TestApproverRulesSeeCanonicalArguments(t *testing.T) {
+	base := t.TempDir()
+	work := filepath.Join(base, "work")
+	secret := filepath.Join(base, "secret")
+	for _, dir := range []string{work, secret} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	approver, err := policy.NewApprover(policy.ModeAuto, nil, []policy.ApprovalRule{
+		{Name: "no-secret", Tool: "probe", Args: policy.Conditions{"path": {Under: []string{secret}}}, Mode: policy.ModeDeny},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolSet := &ToolSet{Registry: newPathToolRegistry(filepath.Join(base, "downloads"))}
+	approver.SetRuleArguments(toolSet.RuleArguments)
+
+	t.Chdir(work)
+	if got := approver.Decide(probeCall(`{"path":"../secret/key"}`)); got.Mode != policy.ModeDeny {
+		t.Errorf("Decide(../secret/key) = %+v, want the deny rule", got)
+	}
+	if got := approver.Decide(probeCall(`{"path":"key"}`)); got.Mode != policy.ModeAuto {
+		t.Errorf("Decide(key) = %+v, want the default policy", got)
+	}
+	// 省略的路径缺省为当前目录
+	t.Chdir(secret)
+	if got := approver.Decide(probeCall(`{}`)); got.Mode != policy.ModeDeny {
+		t.Errorf("Decide({}) = %+v, want the deny rule", got)
+	}
+}
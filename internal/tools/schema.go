@@ -0,0 +1,233 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// schema 是工具参数 JSON Schema 中被校验的子集：
+// type、properties、required、enum、items、minimum、maximum 和 additionalProperties。
+type schema struct {
+	Type                 schemaType         `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Enum                 []any              `json:"enum"`
+	Items                *schema            `json:"items"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+}
+
+// schemaType 是 "type" 字段，它可以是单个类型名，也可以是类型名数组。
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*t = multiple
+	return nil
+}
+
+// compileSchema 将工具定义中的 Parameters 转换为 schema。
+// Parameters 可以是任意能序列化为 JSON Schema 的值；没有定义参数时返回 nil，表示不做校验。
+func compileSchema(parameters any) (*schema, error) {
+	if parameters == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, err
+	}
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// validate 校验模型生成的参数，返回所有违反 schema 的地方。
+// 除非 schema 显式设置了 additionalProperties，对象中未定义的属性都会被拒绝。
+// 可选属性的值为 null 时视为未提供，因为模型经常这样表示"不使用该参数"。
+func (s *schema) validate(args json.RawMessage) []string {
+	decoder := json.NewDecoder(bytes.NewReader(args))
+	decoder.UseNumber() // 保留数字的原始文本，以便区分整数和小数
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("arguments are not valid JSON: %v", err)}
+	}
+	if decoder.More() {
+		return []string{"arguments must be a single JSON object"}
+	}
+	var problems []string
+	s.check("", value, &problems)
+	return problems
+}
+
+// check 校验 value 是否满足 schema，path 是 value 在参数中的位置，例如 'exclude[0]'。
+func (s *schema) check(path string, value any, problems *[]string) {
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(value, t) }) {
+		what := "arguments"
+		if path != "" {
+			what = fmt.Sprintf("'%s'", path)
+		}
+		*problems = append(*problems, fmt.Sprintf("%s must be %s, got %s", what, describeTypes(s.Type), describeValue(value)))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return jsonEqual(e, value) }) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = compactJSON(e)
+		}
+		*problems = append(*problems, fmt.Sprintf("'%s' must be one of %s, got %s", path, strings.Join(allowed, ", "), compactJSON(value)))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.checkObject(path, v, problems)
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.check(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case json.Number:
+		n, _ := new(big.Float).SetString(v.String())
+		if s.Minimum != nil && n != nil && n.Cmp(big.NewFloat(*s.Minimum)) < 0 {
+			*problems = append(*problems, fmt.Sprintf("'%s' must be at least %v, got %s", path, *s.Minimum, v))
+		}
+		if s.Maximum != nil && n != nil && n.Cmp(big.NewFloat(*s.Maximum)) > 0 {
+			*problems = append(*problems, fmt.Sprintf("'%s' must be at most %v, got %s", path, *s.Maximum, v))
+		}
+	}
+}
+
+func (s *schema) checkObject(path string, object map[string]any, problems *[]string) {
+	prefix := ""
+	if path != "" {
+		prefix = path + "."
+	}
+	for _, name := range s.Required {
+		if value, ok := object[name]; !ok || value == nil {
+			*problems = append(*problems, fmt.Sprintf("missing required property '%s%s'", prefix, name))
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := object[name]
+		property, known := s.Properties[name]
+		switch {
+		case !known && (s.AdditionalProperties == nil || !*s.AdditionalProperties):
+			*problems = append(*problems, fmt.Sprintf("unknown property '%s%s'; valid properties are: %s", prefix, name, s.propertyNames()))
+		case !known, value == nil:
+			// 允许的额外属性，或者值为 null 的可选属性（缺少的必填属性已在上面报告）
+		default:
+			property.check(prefix+name, value, problems)
+		}
+	}
+}
+
+// propertyNames 按字母顺序列出 schema 定义的属性。
+func (s *schema) propertyNames() string {
+	if len(s.Properties) == 0 {
+		return "(none)"
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// hasType 判断解码后的 JSON 值是否属于 JSON Schema 类型 t。
+func hasType(value any, t string) bool {
+	switch v := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		if t != "integer" {
+			return false
+		}
+		// 工具参数最终会被解码到 Go 的整数类型，"5.0" 和 "1e3" 这样的写法会解码失败
+		_, err := v.Int64()
+		return err == nil
+	}
+	return false
+}
+
+// describeTypes 将类型列表描述为 "an integer" 或 "a string or an array" 的形式。
+func describeTypes(types []string) string {
+	described := make([]string, len(types))
+	for i, t := range types {
+		switch t {
+		case "integer", "array", "object":
+			described[i] = "an " + t
+		case "null":
+			described[i] = "null"
+		default:
+			described[i] = "a " + t
+		}
+	}
+	return strings.Join(described, " or ")
+}
+
+// describeValue 描述一个值的类型和内容，用于错误信息。
+func describeValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case string:
+		return fmt.Sprintf("string %s", compactJSON(v))
+	case json.Number:
+		return fmt.Sprintf("number %s", v)
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return compactJSON(value)
+}
+
+// jsonEqual 按 JSON 语义比较两个值，enum 中的值和模型传入的值可能有不同的 Go 类型。
+func jsonEqual(a, b any) bool {
+	return compactJSON(a) == compactJSON(b)
+}
+
+func compactJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
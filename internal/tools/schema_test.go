@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// testParameters 覆盖 schema 支持的所有关键字。
+var testParameters = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"path":    map[string]any{"type": "string"},
+		"type":    map[string]any{"type": "string", "enum": []string{"f", "d", "l"}},
+		"depth":   map[string]any{"type": "integer", "minimum": 1, "maximum": 10},
+		"ratio":   map[string]any{"type": "number"},
+		"follow":  map[string]any{"type": "boolean"},
+		"exclude": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"id":      map[string]any{"type": []string{"string", "integer"}},
+		"headers": map[string]any{"type": "object", "additionalProperties": true},
+	},
+	"required": []string{"path"},
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := compileSchema(testParameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args string
+		want []string // 每一项都必须出现在某个问题中；为空表示参数合法
+	}{
+		{name: "minimal", args: `{"path":"/tmp"}`},
+		{name: "all properties", args: `{"path":"/tmp","type":"d","depth":3,"ratio":0.5,"follow":true,"exclude":["a","b"],"id":"x","headers":{"x-any":1}}`},
+		{name: "null optional property", args: `{"path":"/tmp","depth":null}`},
+		{name: "union type integer", args: `{"path":"/tmp","id":7}`},
+		{name: "integer written as float", args: `{"path":"/tmp","depth":5.0}`, want: []string{"'depth' must be an integer, got number 5.0"}},
+		{name: "missing required", args: `{}`, want: []string{"missing required property 'path'"}},
+		{name: "null required", args: `{"path":null}`, want: []string{"missing required property 'path'"}},
+		{name: "wrong type", args: `{"path":42}`, want: []string{"'path' must be a string, got number 42"}},
+		{name: "enum", args: `{"path":"/","type":"x"}`, want: []string{`'type' must be one of "f", "d", "l", got "x"`}},
+		{name: "minimum", args: `{"path":"/","depth":0}`, want: []string{"'depth' must be at least 1, got 0"}},
+		{name: "maximum", args: `{"path":"/","depth":11}`, want: []string{"'depth' must be at most 10, got 11"}},
+		{name: "array items", args: `{"path":"/","exclude":["a",1]}`, want: []string{"'exclude[1]' must be a string, got number 1"}},
+		{name: "union type mismatch", args: `{"path":"/","id":true}`, want: []string{"'id' must be a string or an integer, got boolean true"}},
+		{name: "unknown property", args: `{"path":"/","colour":"red"}`, want: []string{"unknown property 'colour'; valid properties are: depth, exclude, follow, headers, id, path, ratio, type"}},
+		{name: "several problems", args: `{"depth":"3","follow":"yes"}`, want: []string{"missing required property 'path'", "'depth' must be an integer", "'follow' must be a boolean"}},
+		{name: "not an object", args: `["/tmp"]`, want: []string{"arguments must be an object, got an array"}},
+		{name: "invalid JSON", args: `{"path":`, want: []string{"arguments are not valid JSON"}},
+		{name: "trailing value", args: `{"path":"/"} {}`, want: []string{"arguments must be a single JSON object"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := s.validate(json.RawMessage(tt.args))
+			if len(tt.want) == 0 {
+				if len(problems) != 0 {
+					t.Fatalf("validate(%s) = %q, want no problems", tt.args, problems)
+				}
+				return
+			}
+			if len(problems) != len(tt.want) {
+				t.Fatalf("validate(%s) = %q, want %d problems", tt.args, problems, len(tt.want))
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, p := range problems {
+					found = found || strings.Contains(p, want)
+				}
+				if !found {
+					t.Errorf("validate(%s) = %q, want a problem containing %q", tt.args, problems, want)
+				}
+			}
+		})
+	}
+}
+
+func TestToolSetCheckValidatesArguments(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewTool(llm.Tool{
+		Type:     "function",
+		Function: llm.Function{Name: "probe", Parameters: testParameters},
+	}, func(ctx context.Context, args json.RawMessage) (Result, error) {
+		return Result{Output: "ok"}, nil
+	}))
+	toolSet := &ToolSet{Registry: registry}
+
+	call := func(args string) llm.ToolCall {
+		return llm.ToolCall{ID: "1", Type: "function", Function: llm.FunctionCall{Name: "probe", Arguments: args}}
+	}
+	if err := toolSet.Check(call(`{"path":"/tmp"}`)); err != nil {
+		t.Errorf("Check rejected valid arguments: %v", err)
+	}
+	err := toolSet.Check(call(`{"path":"/tmp","depth":"deep"}`))
+	if err == nil || !strings.Contains(err.Error(), "'depth' must be an integer") {
+		t.Errorf("Check = %v, want the schema violation to be reported", err)
+	}
+}
@@ -0,0 +1,265 @@
+//go:build linux
+
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// tcpStates 是 /proc/net/tcp 中 st 字段到状态名的映射，名称与 ss 保持一致。
+var tcpStates = map[string]string{
+	"01": "ESTAB",
+	"02": "SYN-SENT",
+	"03": "SYN-RECV",
+	"04": "FIN-WAIT-1",
+	"05": "FIN-WAIT-2",
+	"06": "TIME-WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE-WAIT",
+	"09": "LAST-ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+	"0C": "NEW-SYN-RECV",
+}
+
+// socketInfo 是一个套接字的信息。
+type socketInfo struct {
+	Proto      string       `json:"proto"`
+	State      string       `json:"state"`
+	LocalAddr  string       `json:"local_address"`
+	LocalPort  int          `json:"local_port"`
+	RemoteAddr string       `json:"remote_address"`
+	RemotePort int          `json:"remote_port"`
+	User       string       `json:"user"`
+	Processes  []processRef `json:"processes,omitempty"`
+
+	inode uint64
+}
+
+// listening 判断套接字是否处于监听状态。与 ss -l 一致，未连接的 UDP 套接字也视为监听。
+func (s socketInfo) listening() bool {
+	return s.State == "LISTEN" || s.State == "UNCONN"
+}
+
+// executeSs 列出网络套接字。
+// 它直接解析 /proc/net/{tcp,tcp6,udp,udp6}，并通过 /proc/<pid>/fd 找到套接字所属的进程，不依赖 iproute2。
+func executeSs(ctx context.Context, rawArgs json.RawMessage) (string, error) {
+	var args struct {
+		Protocol   string `json:"protocol"`
+		State      string `json:"state"`
+		Port       int    `json:"port"`
+		LocalPort  int    `json:"local_port"`
+		RemotePort int    `json:"remote_port"`
+		Listening  bool   `json:"listening"`
+		PID        int    `json:"pid"`
+		Format     string `json:"format"`
+	}
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return "", fmt.Errorf("error decoding 'ss' arguments: %w", err)
+	}
+	if args.Format == "" {
+		args.Format = "table"
+	}
+	if args.Format != "table" && args.Format != "json" {
+		return "", fmt.Errorf("unsupported 'format' '%s', expected 'table' or 'json'", args.Format)
+	}
+
+	var tables []string
+	switch strings.ToLower(args.Protocol) {
+	case "":
+		tables = []string{"tcp", "tcp6", "udp", "udp6"}
+	case "tcp":
+		tables = []string{"tcp", "tcp6"}
+	case "udp":
+		tables = []string{"udp", "udp6"}
+	case "tcp4":
+		tables = []string{"tcp"}
+	case "udp4":
+		tables = []string{"udp"}
+	case "tcp6", "udp6":
+		tables = []string{strings.ToLower(args.Protocol)}
+	default:
+		return "", fmt.Errorf("unsupported 'protocol' '%s', expected tcp, udp, tcp4, tcp6, udp4 or udp6", args.Protocol)
+	}
+	// 状态名同时接受 'established'、'time_wait' 等写法
+	state := strings.ReplaceAll(strings.ToUpper(args.State), "_", "-")
+	if state == "ESTABLISHED" {
+		state = "ESTAB"
+	}
+
+	var sockets []socketInfo
+	for _, table := range tables {
+		parsed, err := readSocketTable(table)
+		if err != nil {
+			return "", err
+		}
+		sockets = append(sockets, parsed...)
+	}
+
+	owners, denied, err := socketOwners(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	matched := sockets[:0]
+	for _, s := range sockets {
+		s.Processes = owners[s.inode]
+		switch {
+		case state != "" && s.State != state,
+			args.Listening && !s.listening(),
+			args.Port != 0 && s.LocalPort != args.Port && s.RemotePort != args.Port,
+			args.LocalPort != 0 && s.LocalPort != args.LocalPort,
+			args.RemotePort != 0 && s.RemotePort != args.RemotePort,
+			args.PID != 0 && !ownedBy(s.Processes, args.PID):
+			continue
+		}
+		matched = append(matched, s)
+	}
+	if len(matched) == 0 {
+		return "No matching sockets found.", nil
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].Proto != matched[j].Proto {
+			return matched[i].Proto < matched[j].Proto
+		}
+		return matched[i].LocalPort < matched[j].LocalPort
+	})
+
+	if args.Format == "json" {
+		data, err := json.MarshalIndent(matched, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("error encoding sockets: %w", err)
+		}
+		return string(data), nil
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTO\tSTATE\tLOCAL\tPEER\tUSER\tPROCESS")
+	unknownOwner := false
+	for _, s := range matched {
+		var procs []string
+		for _, p := range s.Processes {
+			procs = append(procs, fmt.Sprintf("%s(pid=%d)", p.Name, p.PID))
+		}
+		if len(procs) == 0 {
+			procs = []string{"-"}
+			unknownOwner = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Proto, s.State,
+			joinHostPort(s.LocalAddr, s.LocalPort), joinHostPort(s.RemoteAddr, s.RemotePort), s.User, strings.Join(procs, ","))
+	}
+	w.Flush()
+	if unknownOwner && denied > 0 {
+		fmt.Fprintf(&b, "(owners of some sockets are unknown: %d processes could not be inspected due to insufficient permissions)\n", denied)
+	}
+	return b.String(), nil
+}
+
+// readSocketTable 解析 /proc/net 下的一个套接字表，例如 tcp 或 udp6。
+func readSocketTable(table string) ([]socketInfo, error) {
+	f, err := os.Open(filepath.Join(procRoot, "net", table))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 内核未启用 IPv6 时没有 tcp6/udp6
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read socket table '%s': %w", table, err)
+	}
+	defer f.Close()
+
+	isUDP := strings.HasPrefix(table, "udp")
+	var sockets []socketInfo
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // 跳过表头
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		localAddr, localPort, err := parseSocketAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		remoteAddr, remotePort, err := parseSocketAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		inode, _ := strconv.ParseUint(fields[9], 10, 64)
+
+		state := tcpStates[fields[3]]
+		if isUDP {
+			// UDP 只有已连接（ESTABLISHED）和未连接两种状态
+			state = "UNCONN"
+			if fields[3] == "01" {
+				state = "ESTAB"
+			}
+		}
+		sockets = append(sockets, socketInfo{
+			Proto:      table,
+			State:      state,
+			LocalAddr:  localAddr,
+			LocalPort:  localPort,
+			RemoteAddr: remoteAddr,
+			RemotePort: remotePort,
+			User:       lookupUsername(fields[7]),
+			inode:      inode,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read socket table '%s': %w", table, err)
+	}
+	return sockets, nil
+}
+
+// parseSocketAddr 解析 "0100007F:0CEA" 形式的地址。
+// 地址按 32 位字以主机字节序存储，端口则是大端序的十六进制数。
+func parseSocketAddr(s string) (string, int, error) {
+	hexAddr, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed socket address '%s'", s)
+	}
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || len(raw)%4 != 0 {
+		return "", 0, fmt.Errorf("malformed socket address '%s'", s)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.NativeEndian.Uint32(raw[i:]))
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed socket port '%s'", s)
+	}
+	return ip.String(), int(port), nil
+}
+
+// joinHostPort 格式化地址和端口，端口为 0 时显示为 '*'。
+func joinHostPort(addr string, port int) string {
+	if port == 0 {
+		return net.JoinHostPort(addr, "*")
+	}
+	return net.JoinHostPort(addr, strconv.Itoa(port))
+}
+
+func ownedBy(procs []processRef, pid int) bool {
+	for _, p := range procs {
+		if p.PID == pid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// 以下样例取自 x86_64 主机上的 /proc/net/{tcp,tcp6,udp}，地址按小端序的 32 位字存储。
+const (
+	procNetHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	procNetTCP    = procNetHeader +
+		"   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 23456 1 0000000000000000 100 0 0 10 0\n" +
+		"   1: 0F02000A:0016 0202000A:D4B2 01 00000000:00000000 02:000A7D8C 00000000     0        0 34567 4 0000000000000000 20 4 29 10 -1\n" +
+		"   2: 0F02000A:9C40 22D8B85D:01BB 06 00000000:00000000 03:00001772 00000000     0        0 0 3 0000000000000000\n" +
+		"   3: 0100007F:1F90 0100007F:A1C4 08 00000000:00000001 00:00000000 00000000     0        0 45678 1 0000000000000000 20 4 30 10 -1\n"
+	procNetTCP6 = "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+		"   0: 00000000000000000000000000000000:1F90 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 56789 1 0000000000000000 100 0 0 10 0\n" +
+		"   1: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 67890 1 0000000000000000 100 0 0 10 0\n" +
+		"   2: 0000000000000000FFFF00000F02000A:0016 0000000000000000FFFF00000202000A:D4B4 01 00000000:00000000 02:0009E2A4 00000000     0        0 78901 2 0000000000000000 20 4 30 10 -1\n"
+	procNetUDP = "   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n" +
+		"  100: 00000000:0044 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 11111 2 0000000000000000 0\n" +
+		"  200: 0F02000A:A3B1 08080808:0035 01 00000000:00000000 00:00000000 00000000     0        0 22222 2 0000000000000000 0\n"
+)
+
+// skipOnBigEndian 跳过依赖小端序样例的测试。
+func skipOnBigEndian(t *testing.T) {
+	t.Helper()
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("the /proc/net fixtures are little-endian")
+	}
+}
+
+func TestParseSocketAddr(t *testing.T) {
+	skipOnBigEndian(t)
+	tests := []struct {
+		in       string
+		wantAddr string
+		wantPort int
+		wantErr  bool
+	}{
+		{in: "0100007F:0CEA", wantAddr: "127.0.0.1", wantPort: 3306},
+		{in: "0F02000A:D4B2", wantAddr: "10.0.2.15", wantPort: 54450},
+		{in: "00000000:0000", wantAddr: "0.0.0.0", wantPort: 0},
+		{in: "00000000000000000000000000000000:1F90", wantAddr: "::", wantPort: 8080},
+		{in: "00000000000000000000000001000000:0277", wantAddr: "::1", wantPort: 631},
+		{in: "B80D0120000000000000000001000000:01BB", wantAddr: "2001:db8::1", wantPort: 443},
+		{in: "000080FE00000000FF27000AA1664EFE:0222", wantAddr: "fe80::a00:27ff:fe4e:66a1", wantPort: 546},
+		{in: "0000000000000000FFFF00000F02000A:0016", wantAddr: "10.0.2.15", wantPort: 22},
+		{in: "0100007F", wantErr: true},
+		{in: "0100007:0CEA", wantErr: true},
+		{in: "01000000007F:0CEA", wantErr: true},
+		{in: "0100007G:0CEA", wantErr: true},
+		{in: "0100007F:XYZ", wantErr: true},
+		{in: "0100007F:10000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			addr, port, err := parseSocketAddr(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSocketAddr(%q) = %s, %d; want an error", tt.in, addr, port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr != tt.wantAddr || port != tt.wantPort {
+				t.Errorf("parseSocketAddr(%q) = %s, %d; want %s, %d", tt.in, addr, port, tt.wantAddr, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestReadSocketTable(t *testing.T) {
+	skipOnBigEndian(t)
+	root := useProcRoot(t)
+	writeProcFile(t, root, "net/tcp", procNetTCP)
+	writeProcFile(t, root, "net/tcp6", procNetTCP6)
+	writeProcFile(t, root, "net/udp", procNetUDP)
+
+	tests := []struct {
+		table string
+		want  []socketInfo
+	}{
+		{table: "tcp", want: []socketInfo{
+			{Proto: "tcp", State: "LISTEN", LocalAddr: "127.0.0.1", LocalPort: 3306, RemoteAddr: "0.0.0.0", inode: 23456},
+			{Proto: "tcp", State: "ESTAB", LocalAddr: "10.0.2.15", LocalPort: 22, RemoteAddr: "10.0.2.2", RemotePort: 54450, inode: 34567},
+			{Proto: "tcp", State: "TIME-WAIT", LocalAddr: "10.0.2.15", LocalPort: 40000, RemoteAddr: "93.184.216.34", RemotePort: 443},
+			{Proto: "tcp", State: "CLOSE-WAIT", LocalAddr: "127.0.0.1", LocalPort: 8080, RemoteAddr: "127.0.0.1", RemotePort: 41412, inode: 45678},
+		}},
+		{table: "tcp6", want: []socketInfo{
+			{Proto: "tcp6", State: "LISTEN", LocalAddr: "::", LocalPort: 8080, RemoteAddr: "::", inode: 56789},
+			{Proto: "tcp6", State: "LISTEN", LocalAddr: "::1", LocalPort: 631, RemoteAddr: "::", inode: 67890},
+			{Proto: "tcp6", State: "ESTAB", LocalAddr: "10.0.2.15", LocalPort: 22, RemoteAddr: "10.0.2.2", RemotePort: 54452, inode: 78901},
+		}},
+		// UDP 的 st 字段只区分已连接和未连接
+		{table: "udp", want: []socketInfo{
+			{Proto: "udp", State: "UNCONN", LocalAddr: "0.0.0.0", LocalPort: 68, RemoteAddr: "0.0.0.0", inode: 11111},
+			{Proto: "udp", State: "ESTAB", LocalAddr: "10.0.2.15", LocalPort: 41905, RemoteAddr: "8.8.8.8", RemotePort: 53, inode: 22222},
+		}},
+		// 内核未启用 IPv6 时没有 udp6
+		{table: "udp6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			sockets, err := readSocketTable(tt.table)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range sockets {
+				sockets[i].User = "" // 用户名取决于测试主机
+			}
+			if !reflect.DeepEqual(sockets, tt.want) {
+				t.Errorf("readSocketTable(%s) =\n%+v\nwant\n%+v", tt.table, sockets, tt.want)
+			}
+		})
+	}
+}
+
+func TestSsFiltersProcFixtures(t *testing.T) {
+	skipOnBigEndian(t)
+	root := useProcRoot(t)
+	writeProcFile(t, root, "net/tcp", procNetTCP)
+	writeProcFile(t, root, "net/tcp6", procNetTCP6)
+	writeProcFile(t, root, "net/udp", procNetUDP)
+	// 进程 42 持有 tcp6 上监听 8080 的套接字
+	writeProcFile(t, root, "42/comm", "nginx\n")
+	if err := os.MkdirAll(filepath.Join(root, "42", "fd"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for fd, target := range map[string]string{"3": "socket:[56789]", "4": "/var/log/nginx/access.log"} {
+		if err := os.Symlink(target, filepath.Join(root, "42", "fd", fd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args string
+		want []string // "proto state local_port"
+	}{
+		{name: "state", args: `{"state":"time_wait","format":"json"}`, want: []string{"tcp TIME-WAIT 40000"}},
+		{name: "established", args: `{"state":"established","protocol":"tcp","format":"json"}`, want: []string{"tcp ESTAB 22", "tcp6 ESTAB 22"}},
+		{name: "listening includes unconnected udp", args: `{"listening":true,"format":"json"}`,
+			want: []string{"tcp LISTEN 3306", "tcp6 LISTEN 631", "tcp6 LISTEN 8080", "udp UNCONN 68"}},
+		{name: "protocol tcp4", args: `{"protocol":"tcp4","listening":true,"format":"json"}`, want: []string{"tcp LISTEN 3306"}},
+		{name: "port matches either end", args: `{"port":8080,"format":"json"}`, want: []string{"tcp CLOSE-WAIT 8080", "tcp6 LISTEN 8080"}},
+		{name: "remote port", args: `{"remote_port":53,"format":"json"}`, want: []string{"udp ESTAB 41905"}},
+		{name: "pid", args: `{"pid":42,"format":"json"}`, want: []string{"tcp6 LISTEN 8080"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executeSs(context.Background(), json.RawMessage(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sockets []socketInfo
+			if err := json.Unmarshal([]byte(output), &sockets); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, output)
+			}
+			var got []string
+			for _, s := range sockets {
+				got = append(got, s.Proto+" "+s.State+" "+strconv.Itoa(s.LocalPort))
+				if s.LocalPort == 8080 && s.Proto == "tcp6" && (len(s.Processes) != 1 || s.Processes[0] != (processRef{PID: 42, Name: "nginx"})) {
+					t.Errorf("owner of %s:8080 = %+v, want nginx (pid 42)", s.Proto, s.Processes)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("executeSs(%s) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,34 +3,22 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"runtime"
-
-	"github.com/DoraZa/mini-agent/internal/llm"
 )
 
 var errUnsupportedOS = fmt.Errorf("command not supported on operating system: %s", runtime.GOOS)
 
-func executePs(toolCall llm.ToolCall) (string, error) {
-	return "", errUnsupportedOS
-}
-
-func executeFind(toolCall llm.ToolCall) (string, error) {
-	return "", errUnsupportedOS
-}
-
-func executeGrep(toolCall llm.ToolCall) (string, error) {
-	return "", errUnsupportedOS
-}
-
-func executeWget(toolCall llm.ToolCall) (string, error) {
-	return "", errUnsupportedOS
+func listProcesses(ctx context.Context) ([]processInfo, error) {
+	return nil, errUnsupportedOS
 }
 
-func executeSs(toolCall llm.ToolCall) (string, error) {
+func executeSs(ctx context.Context, rawArgs json.RawMessage) (string, error) {
 	return "", errUnsupportedOS
 }
 
-func executeLsof(toolCall llm.ToolCall) (string, error) {
+func executeLsof(ctx context.Context, rawArgs json.RawMessage) (string, error) {
 	return "", errUnsupportedOS
 }
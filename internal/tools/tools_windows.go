@@ -3,129 +3,57 @@
 package tools
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"strconv"
 	"strings"
-
-	"github.com/DoraZa/mini-agent/internal/llm"
 )
 
 // For Windows, many commands behave differently. We provide equivalents where possible.
 
-func executePs(toolCall llm.ToolCall) (string, error) {
-	// tasklist is the Windows equivalent of ps.
-	// It doesn't have flags as flexible as ps, so we ignore them and use /FO CSV for parsable output.
-	cmd := exec.Command("tasklist", "/FO", "CSV")
-	output, err := cmd.CombinedOutput()
+// listProcesses uses tasklist's CSV output. tasklist does not report the parent PID,
+// owner or CPU usage without the slow /V mode, so those fields are left unknown.
+func listProcesses(ctx context.Context) ([]processInfo, error) {
+	output, err := commandContext(ctx, "tasklist", "/FO", "CSV", "/NH").Output()
 	if err != nil {
-		return "", fmt.Errorf("command 'tasklist' failed: %w, output: %s", err, string(output))
-	}
-	return string(output), nil
-}
-
-func executeFind(toolCall llm.ToolCall) (string, error) {
-	// 'dir /s /b' is a rough equivalent for 'find'.
-	var args struct {
-		Path string `json:"path"`
-		Name string `json:"name"`
-	}
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		return "", fmt.Errorf("error decoding 'find' arguments: %w", err)
-	}
-	if args.Path == "" {
-		args.Path = "."
-	}
-	// The 'name' parameter is used as a pattern for 'dir'.
-	searchPath := fmt.Sprintf("%s\\%s", args.Path, args.Name)
-	if args.Name == "" {
-		searchPath = args.Path
+		return nil, fmt.Errorf("command 'tasklist' failed: %w", err)
 	}
-
-	cmd := exec.Command("cmd", "/c", "dir", "/s", "/b", searchPath)
-	output, err := cmd.CombinedOutput()
+	records, err := csv.NewReader(strings.NewReader(string(output))).ReadAll()
 	if err != nil {
-		// 'dir' can return exit code 1 if no files are found. This is not a fatal error.
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return "No files found.", nil
-		}
-		return "", fmt.Errorf("command 'dir' failed: %w, output: %s", err, string(output))
-	}
-	return string(output), nil
-}
-
-func executeGrep(toolCall llm.ToolCall) (string, error) {
-	// 'findstr' is the Windows equivalent of grep.
-	var args struct {
-		Pattern    string `json:"pattern"`
-		File       string `json:"file"`
-		IgnoreCase bool   `json:"ignore_case"`
-	}
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		return "", fmt.Errorf("error decoding 'grep' arguments: %w", err)
-	}
-	if args.Pattern == "" || args.File == "" {
-		return "", fmt.Errorf("the 'pattern' and 'file' arguments are required for grep/findstr")
+		return nil, fmt.Errorf("failed to parse tasklist output: %w", err)
 	}
-
-	cmdArgs := []string{}
-	if args.IgnoreCase {
-		cmdArgs = append(cmdArgs, "/I")
-	}
-	cmdArgs = append(cmdArgs, args.Pattern, args.File)
-
-	cmd := exec.Command("findstr", cmdArgs...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return "No matching lines found.", nil
+	var procs []processInfo
+	for _, record := range records {
+		// "Image Name","PID","Session Name","Session#","Mem Usage"
+		if len(record) < 5 {
+			continue
 		}
-		return "", fmt.Errorf("command 'findstr' failed: %w, output: %s", err, string(output))
-	}
-	return string(output), nil
-}
-
-func executeWget(toolCall llm.ToolCall) (string, error) {
-	// PowerShell's Invoke-WebRequest is a good equivalent for wget.
-	var args struct {
-		URL        string `json:"url"`
-		OutputFile string `json:"output_file"`
-	}
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		return "", fmt.Errorf("error decoding 'wget' arguments: %w", err)
-	}
-	if args.URL == "" {
-		return "", fmt.Errorf("the 'url' argument is required for wget")
-	}
-
-	psCommand := fmt.Sprintf("Invoke-WebRequest -Uri %s", args.URL)
-	if args.OutputFile != "" {
-		psCommand += fmt.Sprintf(" -OutFile %s", args.OutputFile)
-	}
-
-	cmd := exec.Command("powershell", "-Command", psCommand)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("command 'Invoke-WebRequest' failed: %w, output: %s", err, string(output))
+		p := processInfo{PPID: -1, Name: record[0], Command: record[0]}
+		p.PID, _ = strconv.Atoi(record[1])
+		mem := strings.NewReplacer(",", "", ".", "", " K", "", "\u00a0K", "").Replace(record[4])
+		p.RSSKB, _ = strconv.ParseInt(strings.TrimSpace(mem), 10, 64)
+		procs = append(procs, p)
 	}
-	return fmt.Sprintf("Successfully downloaded from %s.", args.URL), nil
+	return procs, nil
 }
 
-func executeLsof(toolCall llm.ToolCall) (string, error) {
+func executeLsof(ctx context.Context, rawArgs json.RawMessage) (string, error) {
 	// 'netstat -ano' is the way to find processes by port on Windows.
 	var args struct {
-		Port string `json:"port"`
+		Port int `json:"port"`
 	}
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
 		return "", fmt.Errorf("error decoding 'lsof' arguments: %w", err)
 	}
-	if args.Port == "" {
+	if args.Port == 0 {
 		return "", fmt.Errorf("the 'port' argument is required for lsof on Windows")
 	}
 
 	// Find the PID using the specified port
 	// The output format is tricky, so we'll do our best.
-	cmd := exec.Command("netstat", "-ano")
+	cmd := commandContext(ctx, "netstat", "-ano")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("command 'netstat -ano' failed: %w, output: %s", err, string(output))
@@ -136,7 +64,7 @@ func executeLsof(toolCall llm.ToolCall) (string, error) {
 	result := "Proto  Local Address          Foreign Address        State           PID\n"
 	found := false
 	for _, line := range lines {
-		if strings.Contains(line, ":"+args.Port) {
+		if strings.Contains(line, ":"+strconv.Itoa(args.Port)) {
 			result += strings.TrimSpace(line) + "\n"
 			found = true
 		}
@@ -151,6 +79,6 @@ func executeLsof(toolCall llm.ToolCall) (string, error) {
 // 'ss' does not have a direct, simple equivalent on Windows.
 // 'netstat' is the closest, but its functionality is more aligned with lsof.
 // We will consider 'ss' unsupported on Windows for now.
-func executeSs(toolCall llm.ToolCall) (string, error) {
+func executeSs(ctx context.Context, rawArgs json.RawMessage) (string, error) {
 	return "", fmt.Errorf("'ss' command is not supported on Windows. Use 'lsof' with a port instead")
 }
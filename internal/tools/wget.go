@@ -0,0 +1,307 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/policy"
+)
+
+// DefaultMaxDownloadBytes 是 wget 工具默认允许下载的最大字节数。
+const DefaultMaxDownloadBytes = 100 << 20
+
+// WgetTool 使用 net/http 下载文件，不依赖 wget 命令。
+// 下载的文件只能写入 DownloadDir 之内，超过 MaxBytes 的下载会被中止，
+// 超时由 Registry 为 wget 配置的执行超时控制。
+type WgetTool struct {
+	DownloadDir string       // 允许写入的目录，为空时使用当前目录
+	MaxBytes    int64        // 单次下载的最大字节数，0 表示使用 DefaultMaxDownloadBytes
+	Client      *http.Client // 为 nil 时使用 http.DefaultClient；重定向总是由 WgetTool 检查
+}
+
+// maxRedirects 是 wget 最多跟随的重定向次数，与 net/http 的默认值相同。
+const maxRedirects = 10
+
+// NewWgetTool 创建一个将文件下载到 downloadDir、单次最多下载 maxBytes 字节的 wget 工具。
+func NewWgetTool(downloadDir string, maxBytes int64) *WgetTool {
+	return &WgetTool{DownloadDir: downloadDir, MaxBytes: maxBytes}
+}
+
+func (t *WgetTool) Name() string         { return wgetDefinition.Function.Name }
+func (t *WgetTool) Definition() llm.Tool { return wgetDefinition }
+
+// PathArguments 实现了 PathTool 接口。未指定 output_file 时文件保存在下载目录中，因此下载目录本身需要可写。
+func (t *WgetTool) PathArguments() []PathArgument {
+	dir := t.DownloadDir
+	if dir == "" {
+		dir = "."
+	}
+	return []PathArgument{{Name: "output_file", Write: true, Dir: dir, Default: dir}}
+}
+
+// Execute 下载 URL 指向的文件，或者在 head_only 模式下只查看响应头。
+func (t *WgetTool) Execute(ctx context.Context, rawArgs json.RawMessage) (Result, error) {
+	var args struct {
+		URL        string `json:"url"`
+		OutputFile string `json:"output_file"`
+		HeadOnly   bool   `json:"head_only"`
+		Overwrite  bool   `json:"overwrite"`
+	}
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return Result{}, fmt.Errorf("error decoding 'wget' arguments: %w", err)
+	}
+	if args.URL == "" {
+		return Result{}, fmt.Errorf("the 'url' argument is required for wget")
+	}
+	u, err := url.Parse(args.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Result{}, fmt.Errorf("invalid url '%s': only absolute http and https URLs are supported", args.URL)
+	}
+
+	if args.HeadOnly {
+		output, err := t.head(ctx, t.client(ctx, rawArgs), u.String())
+		return Result{Output: output}, err
+	}
+	output, err := t.download(ctx, t.client(ctx, rawArgs), u, args.OutputFile, args.Overwrite)
+	return Result{Output: output}, err
+}
+
+// head 发送 HEAD 请求并报告响应头，不下载内容。
+func (t *WgetTool) head(ctx context.Context, client *http.Client, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HEAD %s failed: %w", rawURL, err)
+	}
+	resp.Body.Close()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "HEAD %s\n", rawURL)
+	if final := resp.Request.URL.String(); final != rawURL {
+		fmt.Fprintf(&b, "Final URL: %s\n", final)
+	}
+	fmt.Fprintf(&b, "Status: %s\n", resp.Status)
+	fmt.Fprintf(&b, "Content-Type: %s\n", valueOrDash(resp.Header.Get("Content-Type")))
+	if resp.ContentLength >= 0 {
+		fmt.Fprintf(&b, "Content-Length: %s (%d bytes)\n", formatSize(resp.ContentLength), resp.ContentLength)
+		if resp.ContentLength > t.maxBytes() {
+			fmt.Fprintf(&b, "Note: larger than the download limit of %s, downloading it would be refused.\n", formatSize(t.maxBytes()))
+		}
+	} else {
+		b.WriteString("Content-Length: unknown\n")
+	}
+	for _, name := range []string{"Last-Modified", "Content-Disposition", "ETag"} {
+		if value := resp.Header.Get(name); value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", name, value)
+		}
+	}
+	return b.String(), nil
+}
+
+// download 下载文件并报告保存位置、大小、内容类型和 SHA-256。
+// 内容先写入同一目录下的临时文件，完整下载后才重命名为目标文件，失败时不会留下不完整的文件。
+func (t *WgetTool) download(ctx context.Context, client *http.Client, u *url.URL, outputFile string, overwrite bool) (string, error) {
+	if outputFile == "" {
+		outputFile = path.Base(u.Path)
+		if outputFile == "/" || outputFile == "." {
+			outputFile = "index.html"
+		}
+	}
+	target, err := t.resolveTarget(outputFile)
+	if err != nil {
+		return "", err
+	}
+	if !overwrite {
+		if _, err := os.Lstat(target); err == nil {
+			return "", fmt.Errorf("'%s' already exists; set 'overwrite' to true to replace it or choose another 'output_file'", target)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GET %s failed: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("GET %s failed: server returned %s", u, resp.Status)
+	}
+	maxBytes := t.maxBytes()
+	if resp.ContentLength > maxBytes {
+		return "", fmt.Errorf("refusing to download %s: Content-Length %s exceeds the limit of %s", u, formatSize(resp.ContentLength), formatSize(maxBytes))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".wget-*.part")
+	if err != nil {
+		return "", fmt.Errorf("failed to create file in '%s': %w", filepath.Dir(target), err)
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后删除会失败，可以忽略
+	// CreateTemp 创建的文件权限为 0600，下载的文件应与普通新建文件一致
+	_ = tmp.Chmod(0o644)
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(resp.Body, maxBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("download of %s failed after %d bytes: %w", u, n, err)
+	}
+	if n > maxBytes {
+		return "", fmt.Errorf("download of %s aborted: response exceeds the limit of %s", u, formatSize(maxBytes))
+	}
+
+	if err := saveDownload(tmp.Name(), target, overwrite); err != nil {
+		return "", err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Downloaded %s to %s\n", u, target)
+	fmt.Fprintf(&b, "Status: %s\n", resp.Status)
+	fmt.Fprintf(&b, "Content-Type: %s\n", valueOrDash(contentType))
+	fmt.Fprintf(&b, "Size: %s (%d bytes)\n", formatSize(n), n)
+	fmt.Fprintf(&b, "SHA-256: %s\n", hex.EncodeToString(hash.Sum(nil)))
+	return b.String(), nil
+}
+
+// saveDownload 将下载完成的临时文件移动到目标位置。
+// 不允许覆盖时优先使用 os.Link：目标在下载期间被创建的话它会失败，而不会覆盖已有文件。
+func saveDownload(tmp, target string, overwrite bool) error {
+	if !overwrite {
+		err := os.Link(tmp, target)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("'%s' already exists; set 'overwrite' to true to replace it", target)
+		}
+		// 文件系统不支持硬链接时退回到先检查再重命名
+		if _, err := os.Lstat(target); err == nil {
+			return fmt.Errorf("'%s' already exists; set 'overwrite' to true to replace it", target)
+		}
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return fmt.Errorf("failed to save '%s': %w", target, err)
+	}
+	return nil
+}
+
+// resolveTarget 将 output_file 解析为下载目录中的绝对路径。
+// 解析符号链接后仍位于下载目录之外的路径会被拒绝。
+func (t *WgetTool) resolveTarget(outputFile string) (string, error) {
+	dir := t.DownloadDir
+	if dir == "" {
+		dir = "."
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid download directory '%s': %w", dir, err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create download directory '%s': %w", root, err)
+	}
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = realRoot
+	}
+
+	target := outputFile
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target = filepath.Clean(target)
+	parent := filepath.Dir(target)
+	if realParent, err := filepath.EvalSymlinks(parent); err == nil {
+		parent = realParent
+	} else {
+		return "", fmt.Errorf("directory of output file '%s' does not exist", outputFile)
+	}
+	target = filepath.Join(parent, filepath.Base(target))
+	if !policy.IsWithin(root, target) || target == root {
+		return "", fmt.Errorf("output file '%s' is outside the download directory '%s'", outputFile, root)
+	}
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("output file '%s' is a symbolic link; refusing to write through it", outputFile)
+	}
+	return target, nil
+}
+
+// client 返回这次调用使用的 HTTP 客户端，它在跟随每个重定向之前检查目标：
+// 从 https 降级到 http 的重定向总是被拒绝；ctx 中带有调用检查时（见 withRedirectCheck），
+// 重定向目标替换 url 参数后作为一次新的 wget 调用重新检查，被参数规则拒绝的主机无法通过重定向访问。
+func (t *WgetTool) client(ctx context.Context, rawArgs json.RawMessage) *http.Client {
+	base := t.Client
+	if base == nil {
+		base = http.DefaultClient
+	}
+	check, _ := ctx.Value(redirectCheckKey{}).(func(llm.ToolCall) error)
+	client := *base
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if prev := via[len(via)-1].URL; prev.Scheme == "https" && req.URL.Scheme != "https" {
+			return fmt.Errorf("refusing to follow redirect from %s to %s: downgrade from https to http", prev, req.URL)
+		}
+		if check != nil {
+			if err := check(t.redirectCall(rawArgs, req.URL)); err != nil {
+				return fmt.Errorf("refusing to follow redirect to %s: %w", req.URL, err)
+			}
+		}
+		if base.CheckRedirect != nil {
+			return base.CheckRedirect(req, via)
+		}
+		return nil
+	}
+	return &client
+}
+
+// redirectCall 返回把 rawArgs 中的 url 替换为重定向目标 u 之后的 wget 调用。
+func (t *WgetTool) redirectCall(rawArgs json.RawMessage, u *url.URL) llm.ToolCall {
+	var args map[string]any
+	if err := json.Unmarshal(rawArgs, &args); err != nil || args == nil {
+		args = make(map[string]any)
+	}
+	args["url"] = u.String()
+	data, _ := json.Marshal(args)
+	return llm.ToolCall{Type: "function", Function: llm.FunctionCall{Name: t.Name(), Arguments: string(data)}}
+}
+
+type redirectCheckKey struct{}
+
+// withRedirectCheck 返回一个携带调用检查的 ctx，wget 用它重新检查每个重定向目标。check 为 nil 时原样返回 ctx。
+func withRedirectCheck(ctx context.Context, check func(llm.ToolCall) error) context.Context {
+	if check == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, redirectCheckKey{}, check)
+}
+
+func (t *WgetTool) maxBytes() int64 {
+	if t.MaxBytes > 0 {
+		return t.MaxBytes
+	}
+	return DefaultMaxDownloadBytes
+}
@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+	"github.com/DoraZa/mini-agent/internal/policy"
+)
+
+// newWgetServer 创建一个返回 body 的测试服务器。streamed 为 true 时先刷新一部分内容，
+// 使响应以分块编码发送、没有 Content-Length。
+func newWgetServer(t *testing.T, body string, streamed bool) (*httptest.Server, *[]string) {
+	t.Helper()
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if streamed {
+			half := len(body) / 2
+			w.Write([]byte(body[:half]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(body[half:]))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &methods
+}
+
+func runWget(t *testing.T, tool *WgetTool, args map[string]any) (string, error) {
+	t.Helper()
+	raw, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tool.Execute(context.Background(), raw)
+	return result.Output, err
+}
+
+// dirEntries 返回目录中的文件名，用于确认失败的下载没有留下文件。
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestWgetSizeLimit(t *testing.T) {
+	body := strings.Repeat("x", 2048)
+	tests := []struct {
+		name     string
+		streamed bool
+		wantErr  string
+	}{
+		{name: "content-length", streamed: false, wantErr: "Content-Length"},
+		{name: "streamed body", streamed: true, wantErr: "exceeds the limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newWgetServer(t, body, tt.streamed)
+			dir := t.TempDir()
+			tool := &WgetTool{DownloadDir: dir, MaxBytes: 1024, Client: srv.Client()}
+
+			_, err := runWget(t, tool, map[string]any{"url": srv.URL + "/big.txt"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+			if names := dirEntries(t, dir); len(names) != 0 {
+				t.Errorf("download directory contains %v after a refused download", names)
+			}
+		})
+	}
+}
+
+func TestWgetRefusesOverwrite(t *testing.T) {
+	srv, _ := newWgetServer(t, "new content", false)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(target, []byte("old content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &WgetTool{DownloadDir: dir, Client: srv.Client()}
+
+	_, err := runWget(t, tool, map[string]any{"url": srv.URL + "/file.txt"})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("error = %v, want an 'already exists' error", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "old content" {
+		t.Errorf("existing file was modified: %q", data)
+	}
+
+	if _, err := runWget(t, tool, map[string]any{"url": srv.URL + "/file.txt", "overwrite": true}); err != nil {
+		t.Fatalf("download with overwrite failed: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new content" {
+		t.Errorf("file content = %q after overwrite, want %q", data, "new content")
+	}
+}
+
+func TestWgetRejectsEscapes(t *testing.T) {
+	srv, methods := newWgetServer(t, "payload", false)
+	base := t.TempDir()
+	dir := filepath.Join(base, "downloads")
+	outside := filepath.Join(base, "outside")
+	for _, d := range []string{dir, outside} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "victim"), filepath.Join(dir, "victim")); err != nil {
+		t.Fatal(err)
+	}
+	tool := &WgetTool{DownloadDir: dir, Client: srv.Client()}
+
+	tests := []struct {
+		name       string
+		outputFile string
+		wantErr    string
+	}{
+		{name: "dot-dot", outputFile: "../outside/file", wantErr: "outside the download directory"},
+		{name: "absolute path", outputFile: filepath.Join(outside, "file"), wantErr: "outside the download directory"},
+		{name: "symlinked directory", outputFile: "escape/file", wantErr: "outside the download directory"},
+		{name: "symlinked file", outputFile: "victim", wantErr: "symbolic link"},
+		{name: "download directory itself", outputFile: ".", wantErr: "outside the download directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runWget(t, tool, map[string]any{"url": srv.URL + "/file", "output_file": tt.outputFile})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+	if names := dirEntries(t, outside); len(names) != 0 {
+		t.Errorf("files were written outside the download directory: %v", names)
+	}
+	if len(*methods) != 0 {
+		t.Errorf("requests were sent for refused targets: %v", *methods)
+	}
+}
+
+func TestWgetHeadOnly(t *testing.T) {
+	srv, methods := newWgetServer(t, strings.Repeat("x", 2048), false)
+	dir := t.TempDir()
+	tool := &WgetTool{DownloadDir: dir, MaxBytes: 1024, Client: srv.Client()}
+
+	output, err := runWget(t, tool, map[string]any{"url": srv.URL + "/big.txt", "head_only": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Status: 200 OK", "Content-Type: text/plain", "(2048 bytes)", "downloading it would be refused"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+	if len(*methods) != 1 || (*methods)[0] != http.MethodHead {
+		t.Errorf("requests = %v, want a single HEAD", *methods)
+	}
+	if names := dirEntries(t, dir); len(names) != 0 {
+		t.Errorf("head_only wrote files: %v", names)
+	}
+}
+
+func TestWgetReportsSHA256(t *testing.T) {
+	body := "hello, mini-agent\n"
+	srv, _ := newWgetServer(t, body, true)
+	dir := t.TempDir()
+	tool := &WgetTool{DownloadDir: dir, Client: srv.Client()}
+
+	output, err := runWget(t, tool, map[string]any{"url": srv.URL + "/dir/hello.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(body))
+	target := filepath.Join(dir, "hello.txt")
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		target = filepath.Join(real, "hello.txt")
+	}
+	for _, want := range []string{
+		"SHA-256: " + hex.EncodeToString(sum[:]),
+		"Content-Type: text/plain\n",
+		"(18 bytes)",
+		"to " + target + "\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != body {
+		t.Errorf("saved file = %q, %v; want %q", data, err, body)
+	}
+	if names := dirEntries(t, dir); len(names) != 1 {
+		t.Errorf("download directory contains %v, want only hello.txt", names)
+	}
+}
+
+func TestWgetRedirects(t *testing.T) {
+	target, methods := newWgetServer(t, "payload", false)
+	targetURL, err := url.Parse(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same-host":
+			// 端口不同但主机相同，不受按主机名限制的规则影响
+			http.Redirect(w, r, target.URL+"/file.txt", http.StatusFound)
+		case "/cross-host":
+			http.Redirect(w, r, "http://localhost:"+targetURL.Port()+"/file.txt", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}
+	plain := httptest.NewServer(http.HandlerFunc(redirect))
+	t.Cleanup(plain.Close)
+	// 降级到 http 的重定向来自 https 服务器；它的客户端信任测试证书，也能访问 http 服务器
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/file.txt", http.StatusFound)
+	}))
+	t.Cleanup(secure.Close)
+	rules, err := policy.NewRuleSet([]policy.ToolRule{{Name: "no-localhost", Tool: "wget", Effect: policy.EffectDeny,
+		Args: policy.Conditions{"url": {Hosts: []string{"localhost"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     map[string]any
+		wantErr  string
+		wantSent bool
+	}{
+		{name: "same host", args: map[string]any{"url": plain.URL + "/same-host"}, wantSent: true},
+		{name: "host denied by a rule", args: map[string]any{"url": plain.URL + "/cross-host"}, wantErr: "policy rule"},
+		{name: "head_only host denied by a rule", args: map[string]any{"url": plain.URL + "/cross-host", "head_only": true}, wantErr: "policy rule"},
+		{name: "https to http", args: map[string]any{"url": secure.URL + "/file.txt"}, wantErr: "downgrade from https to http"},
+		{name: "too many redirects", args: map[string]any{"url": plain.URL + "/loop"}, wantErr: "stopped after 10 redirects"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*methods = nil
+			dir := t.TempDir()
+			registry := NewRegistry()
+			registry.Register(&WgetTool{DownloadDir: dir, Client: secure.Client()})
+			toolSet := &ToolSet{Registry: registry, Rules: rules}
+			tt.args["output_file"] = "out.txt"
+			raw, err := json.Marshal(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = toolSet.Execute(context.Background(), llm.ToolCall{Type: "function", Function: llm.FunctionCall{Name: "wget", Arguments: string(raw)}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data, err := os.ReadFile(filepath.Join(dir, "out.txt")); err != nil || string(data) != "payload" {
+					t.Errorf("saved file = %q, %v; want the redirect target", data, err)
+				}
+			} else {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				if names := dirEntries(t, dir); len(names) != 0 {
+					t.Errorf("a refused redirect left files: %v", names)
+				}
+			}
+			if sent := len(*methods) > 0; sent != tt.wantSent {
+				t.Errorf("requests to the redirect target = %v, want sent %v", *methods, tt.wantSent)
+			}
+		})
+	}
+}
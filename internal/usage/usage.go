@@ -0,0 +1,107 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// Price 定义了一个模型的计费标准，单位为每百万 token 的价格。
+type Price struct {
+	Input       float64 // 未命中缓存的输入 token 单价
+	CachedInput float64 // 命中提示词缓存的输入 token 单价，为 0 时按 Input 计费
+	Output      float64 // 输出 token 单价
+}
+
+// Cost 计算给定用量在该价格下的费用。
+func (p Price) Cost(u llm.Usage) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	uncached := u.PromptTokens - u.CachedTokens
+	return (float64(uncached)*p.Input +
+		float64(u.CachedTokens)*cachedPrice +
+		float64(u.CompletionTokens)*p.Output) / 1_000_000
+}
+
+// PriceTable 是模型名称到计费标准的映射。
+type PriceTable map[string]Price
+
+// Totals 是一段时间内（一个步骤、一个任务或整个会话）的累计用量与费用。
+type Totals struct {
+	Requests int       // LLM 请求次数
+	Usage    llm.Usage // 累计 token 用量
+	Cost     float64   // 累计费用
+	Unpriced int       // 因模型不在价格表中而未计费的请求次数
+}
+
+// add 将一次请求的用量累加到 Totals 中。
+func (t *Totals) add(u llm.Usage, cost float64, priced bool) {
+	t.Requests++
+	t.Usage = t.Usage.Add(u)
+	t.Cost += cost
+	if !priced {
+		t.Unpriced++
+	}
+}
+
+// String 返回一行可读的用量摘要。
+func (t Totals) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d requests, %d tokens (prompt %d, cached %d, completion %d)",
+		t.Requests, t.Usage.TotalTokens, t.Usage.PromptTokens, t.Usage.CachedTokens, t.Usage.CompletionTokens)
+	fmt.Fprintf(&b, ", cost $%.4f", t.Cost)
+	if t.Unpriced > 0 {
+		fmt.Fprintf(&b, " (%d requests unpriced)", t.Unpriced)
+	}
+	return b.String()
+}
+
+// Tracker 按任务和会话两个维度累计 token 用量与费用，可在多个 goroutine 中安全使用。
+type Tracker struct {
+	mu      sync.Mutex
+	prices  PriceTable
+	task    Totals
+	session Totals
+}
+
+// NewTracker 创建一个使用给定价格表计费的 Tracker。
+func NewTracker(prices PriceTable) *Tracker {
+	return &Tracker{prices: prices}
+}
+
+// StartTask 开始统计一个新的用户任务，清零任务级别的累计值。
+func (t *Tracker) StartTask() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.task = Totals{}
+}
+
+// Record 记录一次 LLM 请求的用量，并返回该请求的费用。
+func (t *Tracker) Record(model string, u llm.Usage) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	price, priced := t.prices[model]
+	cost := price.Cost(u)
+	t.task.add(u, cost, priced)
+	t.session.add(u, cost, priced)
+	return cost
+}
+
+// Task 返回当前（或最近一个）任务的累计值。
+func (t *Tracker) Task() Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.task
+}
+
+// Session 返回整个会话的累计值。
+func (t *Tracker) Session() Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.session
+}
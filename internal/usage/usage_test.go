@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/DoraZa/mini-agent/internal/llm"
+)
+
+// almostEqual 比较两个费用，忽略浮点运算的误差。
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-12
+}
+
+func TestPriceCost(t *testing.T) {
+	tests := []struct {
+		name  string
+		price Price
+		usage llm.Usage
+		want  float64
+	}{
+		{
+			name:  "no cache",
+			price: Price{Input: 2, Output: 8},
+			usage: llm.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000},
+			want:  2 + 4,
+		},
+		{
+			name:  "cached tokens at the cached price",
+			price: Price{Input: 2, CachedInput: 0.5, Output: 8},
+			usage: llm.Usage{PromptTokens: 1_000_000, CachedTokens: 400_000, CompletionTokens: 100_000},
+			want:  0.6*2 + 0.4*0.5 + 0.1*8,
+		},
+		{
+			name:  "cached tokens without a cached price",
+			price: Price{Input: 2, Output: 8},
+			usage: llm.Usage{PromptTokens: 1_000_000, CachedTokens: 400_000},
+			want:  2,
+		},
+		{
+			name:  "empty usage",
+			price: Price{Input: 2, Output: 8},
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.Cost(tt.usage); !almostEqual(got, tt.want) {
+				t.Errorf("Cost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(PriceTable{"priced": {Input: 1, CachedInput: 0.25, Output: 4}})
+
+	// 第一个任务有两个步骤，其中一个步骤使用了不在价格表中的模型
+	tracker.StartTask()
+	step1 := tracker.Record("priced", llm.Usage{PromptTokens: 1000, CachedTokens: 600, CompletionTokens: 100, TotalTokens: 1100})
+	if want := (400*1 + 600*0.25 + 100*4) / 1_000_000.0; !almostEqual(step1, want) {
+		t.Errorf("first step cost = %v, want %v", step1, want)
+	}
+	if cost := tracker.Record("unknown", llm.Usage{PromptTokens: 500, CompletionTokens: 50, TotalTokens: 550}); cost != 0 {
+		t.Errorf("unpriced step cost = %v, want 0", cost)
+	}
+	first := tracker.Task()
+	if want := (llm.Usage{PromptTokens: 1500, CachedTokens: 600, CompletionTokens: 150, TotalTokens: 1650}); first.Usage != want {
+		t.Errorf("task usage = %+v, want %+v", first.Usage, want)
+	}
+	if first.Requests != 2 || first.Unpriced != 1 || !almostEqual(first.Cost, step1) {
+		t.Errorf("task totals = %+v, want 2 requests, 1 unpriced and cost %v", first, step1)
+	}
+
+	// 新任务重新计数，会话继续累计
+	tracker.StartTask()
+	step3 := tracker.Record("priced", llm.Usage{PromptTokens: 2000, CompletionTokens: 200, TotalTokens: 2200})
+	second := tracker.Task()
+	if second.Requests != 1 || second.Unpriced != 0 || second.Usage.TotalTokens != 2200 || !almostEqual(second.Cost, step3) {
+		t.Errorf("second task totals = %+v", second)
+	}
+	session := tracker.Session()
+	if want := (llm.Usage{PromptTokens: 3500, CachedTokens: 600, CompletionTokens: 350, TotalTokens: 3850}); session.Usage != want {
+		t.Errorf("session usage = %+v, want %+v", session.Usage, want)
+	}
+	if session.Requests != 3 || session.Unpriced != 1 || !almostEqual(session.Cost, step1+step3) {
+		t.Errorf("session totals = %+v, want 3 requests, 1 unpriced and cost %v", session, step1+step3)
+	}
+}
+
+func TestTotalsString(t *testing.T) {
+	totals := Totals{Requests: 2, Usage: llm.Usage{PromptTokens: 1500, CachedTokens: 600, CompletionTokens: 150, TotalTokens: 1650}, Cost: 0.0012, Unpriced: 1}
+	want := "2 requests, 1650 tokens (prompt 1500, cached 600, completion 150), cost $0.0012 (1 requests unpriced)"
+	if got := totals.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := (Totals{}).String(); strings.Contains(got, "unpriced") {
+		t.Errorf("String() = %q, want no unpriced note", got)
+	}
+}